@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"scheduler/models"
+	"scheduler/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// backupConfig comes from BACKUP_DIR, BACKUP_SCHEDULE ("every 6h", "at 03:00" or a cron expression like "0 3 * * 1-5") and BACKUP_KEEP
+type backupConfig struct {
+	dir      string
+	schedule string
+	keep     int
+}
+
+func getBackupConfig() backupConfig {
+	config := backupConfig{dir: os.Getenv("BACKUP_DIR"), schedule: os.Getenv("BACKUP_SCHEDULE"), keep: 7}
+	if config.schedule == "" {
+		config.schedule = "at 03:00"
+	}
+	if keep, err := strconv.Atoi(os.Getenv("BACKUP_KEEP")); err == nil && keep > 0 {
+		config.keep = keep
+	}
+	return config
+}
+
+// startBackups snapshots the store on the backup schedule, if a backup directory is set
+func startBackups(store models.TaskStore, config backupConfig) {
+	if config.dir == "" {
+		return
+	}
+	if _, err := nextBackup(config.schedule); err != nil {
+		log.Fatal().Err(err).Msg("Invalid BACKUP_SCHEDULE")
+	}
+	log.Info().Str("dir", config.dir).Str("schedule", config.schedule).Int("keep", config.keep).Msg("Backups enabled")
+
+	go func() {
+		for {
+			wait, _ := nextBackup(config.schedule)
+			time.Sleep(wait)
+			if err := backupStore(store, config); err != nil {
+				log.Error().Err(err).Str("dir", config.dir).Msg("Backup failed")
+			}
+		}
+	}()
+}
+
+// nextBackup is the time until the next backup, backups at a time of day run tomorrow once the time has passed
+func nextBackup(schedule string) (time.Duration, error) {
+	daily := strings.HasPrefix(schedule, "at ")
+	if !daily && !strings.HasPrefix(schedule, "every ") {
+		cron, err := utils.ParseCron(schedule)
+		if err != nil {
+			return 0, fmt.Errorf("backups run \"every <duration>\", \"at HH:MM\" or on a cron expression: %w", err)
+		}
+		now := time.Now()
+		next := cron.Next(now)
+		if next.IsZero() {
+			return 0, fmt.Errorf("backup cron expression never matches, found <%s>", schedule)
+		}
+		return next.Sub(now), nil
+	}
+
+	wait, err := utils.ParseDuration(schedule)
+	if err != nil {
+		return 0, err
+	}
+	if daily && wait <= 0 {
+		wait += 24 * time.Hour
+	}
+	if wait <= 0 {
+		return 0, fmt.Errorf("backup interval has to be positive, found <%s>", schedule)
+	}
+	return wait, nil
+}
+
+// backupStore writes a snapshot and deletes the oldest ones beyond the number to keep
+func backupStore(store models.TaskStore, config backupConfig) error {
+	backup, err := models.NewBackup(store)
+	if err != nil {
+		return err
+	}
+	path, err := models.WriteBackup(config.dir, backup)
+	if err != nil {
+		return err
+	}
+	removed, err := models.RotateBackups(config.dir, config.keep)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("path", path).Int("schedules", len(backup.Schedules)).Int("rotated", len(removed)).Msg("Wrote backup")
+	return nil
+}
+
+const commandUsage = `Usage: scheduler [command]
+
+Without a command the server is started.
+
+Commands:
+  backup              write a backup of the store to BACKUP_DIR now
+  restore FILE|DIR    load a backup into the store selected by STORE and STORE_PATH
+  migrate             apply the pending MongoDB migrations, the server does this on start
+
+Run "scheduler <command> -h" for the flags of a command.
+`
+
+// runCommand runs the maintenance commands of the server binary and returns the exit code
+func runCommand(name string, args []string) int {
+	switch name {
+	case "backup":
+		return backupCommand(args)
+	case "restore":
+		return restoreCommand(args)
+	case "migrate":
+		return migrateCommand(args)
+	default:
+		fmt.Fprint(os.Stderr, commandUsage)
+		return 2
+	}
+}
+
+func backupCommand(args []string) int {
+	config := getBackupConfig()
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	flags.StringVar(&config.dir, "dir", config.dir, "backup directory, $BACKUP_DIR")
+	flags.IntVar(&config.keep, "keep", config.keep, "number of backups to keep, $BACKUP_KEEP")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if config.dir == "" {
+		fmt.Fprintln(os.Stderr, "backup: missing directory, set --dir or $BACKUP_DIR")
+		return 2
+	}
+
+	store, closeStore, err := openStore(false)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not open task store")
+		return 1
+	}
+	defer closeStore()
+
+	if err := backupStore(store, config); err != nil {
+		log.Error().Err(err).Msg("Backup failed")
+		return 1
+	}
+	return 0
+}
+
+// restoreCommand makes the schedules of a backup what they were at the time of the backup. Given a directory,
+// it picks the newest backup, or the newest one taken at or before --at for a point in time restore.
+func restoreCommand(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	at := flags.String("at", "", "RFC 3339 time like 2024-05-01T12:00:00Z, restores the newest backup of the directory taken at or before it")
+	dryRun := flags.Bool("dry-run", false, "only show what the backup contains")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: scheduler restore [flags] FILE|DIR")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not read the backup")
+		return 1
+	}
+	if info.IsDir() {
+		pointInTime := time.Now()
+		if *at != "" {
+			if pointInTime, err = time.Parse(time.RFC3339, *at); err != nil {
+				log.Error().Err(err).Msg("Invalid --at")
+				return 2
+			}
+		}
+		file, err := models.FindBackup(path, pointInTime)
+		if err != nil {
+			log.Error().Err(err).Str("dir", path).Time("at", pointInTime).Msg("Could not find a backup")
+			return 1
+		}
+		path = file.Path
+	}
+
+	backup, err := models.ReadBackup(path)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not read the backup")
+		return 1
+	}
+	log.Info().Str("path", path).Time("createdAt", backup.CreatedAt).Msg("Backup")
+	for _, schedule := range backup.Schedules {
+		log.Info().Str("owner", schedule.Owner).Int("tasks", len(schedule.Tasks)).Int("routines", len(schedule.Routines)).
+			Int("history", len(schedule.History)).Int("trash", len(schedule.Trash)).Int("audit", len(schedule.Audit)).Msg(" - schedule")
+	}
+	if *dryRun {
+		return 0
+	}
+
+	store, closeStore, err := openStore(false)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not open task store")
+		return 1
+	}
+	defer closeStore()
+
+	if err := models.RestoreBackup(store, backup); err != nil {
+		log.Error().Err(err).Msg("Restore failed")
+		return 1
+	}
+	log.Info().Int("schedules", len(backup.Schedules)).Msg("Restored backup")
+	return 0
+}
+
+// migrateCommand applies the pending migrations of the MongoDB store, or only shows them with --dry-run.
+// The other stores migrate themselves when they are opened.
+func migrateCommand(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only show the pending migrations and their plans, $MIGRATIONS=dry-run")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if !getStoreConfig().IsMongo() {
+		log.Info().Msg("Only the mongo store has migrations to run, the sqlite store migrates itself when it's opened")
+		return 0
+	}
+
+	client, err := connectToMongo()
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to MongoDB")
+		return 1
+	}
+	defer client.Disconnect(context.Background())
+
+	if *dryRun {
+		if _, err := (models.MigrationDBModel{Client: client}).DryRun(); err != nil {
+			log.Error().Err(err).Msg("Could not plan migrations")
+			return 1
+		}
+		return 0
+	}
+	if err := migrateDatabase(client); err != nil {
+		log.Error().Err(err).Msg("Migration failed")
+		return 1
+	}
+	return 0
+}
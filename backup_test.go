@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"scheduler/models"
+	"testing"
+	"time"
+)
+
+func TestNextBackup_Cron(t *testing.T) {
+	wait, err := nextBackup("*/5 * * * *")
+	if err != nil || wait <= 0 || wait > 5*time.Minute {
+		t.Errorf("Wait of a cron schedule was incorrect, got: %v %v, want: at most %v.", wait, err, 5*time.Minute)
+	}
+	for _, schedule := range []string{"0 0 30 2 *", "daily", "* * *"} {
+		if _, err := nextBackup(schedule); err == nil {
+			t.Errorf("nextBackup of <%s> was incorrect, got: no error, want: an error.", schedule)
+		}
+	}
+}
+
+func TestBackupCommand_MigrationsDryRun(t *testing.T) {
+	// the dry run of the server's migrations must not turn a backup into a silent no-op
+	t.Setenv("MIGRATIONS", "dry-run")
+	t.Setenv("STORE", "sqlite")
+	t.Setenv("STORE_PATH", filepath.Join(t.TempDir(), "schedules.db"))
+	dir := t.TempDir()
+
+	if code := runCommand("backup", []string{"--dir", dir}); code != 0 {
+		t.Fatalf("Exit code of the backup was incorrect, got: %v, want: %v.", code, 0)
+	}
+	if files, err := models.ListBackups(dir); err != nil || len(files) != 1 {
+		t.Errorf("Backups were incorrect, got: %v %v, want: 1 backup.", files, err)
+	}
+}
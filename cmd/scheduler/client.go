@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"scheduler/models"
+	"strings"
+)
+
+// Client talks to the JSON API of a scheduler server
+type Client struct {
+	BaseUrl string
+	Token   string
+	// Schedule is the team id to work on, empty for the personal schedule
+	Schedule string
+	http     *http.Client
+}
+
+func NewClient(baseUrl string, token string, schedule string) *Client {
+	return &Client{
+		BaseUrl:  strings.TrimSuffix(baseUrl, "/"),
+		Token:    token,
+		Schedule: schedule,
+		http:     &http.Client{},
+	}
+}
+
+// ApiError is an error object answered by the server
+type ApiError struct {
+	Status int
+	models.ApiError
+}
+
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("%s (%d %s)", e.Message, e.Status, e.Code)
+}
+
+func (client *Client) newRequest(method string, path string, query url.Values, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	endpoint := client.BaseUrl + "/api/v1" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+client.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if client.Schedule != "" {
+		req.Header.Set("X-Schedule", client.Schedule)
+	}
+	return req, nil
+}
+
+// do sends a request and decodes the response into out, unless out is nil
+func (client *Client) do(method string, path string, query url.Values, body any, out any) error {
+	req, err := client.newRequest(method, path, query, body)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return decodeError(res)
+	}
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func decodeError(res *http.Response) error {
+	apiErr := &ApiError{Status: res.StatusCode}
+	errorResponse := models.ApiErrorResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&errorResponse); err != nil || errorResponse.Error == nil {
+		apiErr.Code = "unknown"
+		apiErr.Message = http.StatusText(res.StatusCode)
+		return apiErr
+	}
+	apiErr.ApiError = *errorResponse.Error
+	return apiErr
+}
+
+// ListTasks fetches every page of the filtered task list
+func (client *Client) ListTasks(filter url.Values) ([]*models.TaskResource, error) {
+	var tasks []*models.TaskResource
+	for offset := 0; ; {
+		query := url.Values{}
+		for key, values := range filter {
+			query[key] = values
+		}
+		query.Set("offset", fmt.Sprint(offset))
+		query.Set("limit", fmt.Sprint(models.MaxPageLimit))
+
+		page := models.ApiPage[*models.TaskResource]{}
+		if err := client.do(http.MethodGet, "/tasks", query, nil, &page); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, page.Data...)
+
+		offset += len(page.Data)
+		if len(page.Data) == 0 || int64(offset) >= page.Pagination.Total {
+			return tasks, nil
+		}
+	}
+}
+
+func (client *Client) CreateTask(request *models.TaskRequest) (*models.TaskResource, error) {
+	task := &models.TaskResource{}
+	return task, client.do(http.MethodPost, "/tasks", nil, request, task)
+}
+
+func (client *Client) DeleteTask(id string) error {
+	return client.do(http.MethodDelete, "/tasks/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// TaskAction posts one of the task actions like "activate", the body is optional
+func (client *Client) TaskAction(id string, action string, body any) (*models.TaskResource, error) {
+	task := &models.TaskResource{}
+	if action == "done" {
+		task = nil
+	}
+	err := client.do(http.MethodPost, "/tasks/"+url.PathEscape(id)+"/"+action, nil, body, task)
+	return task, err
+}
+
+// ExportSchedule downloads the schedule in the format json or yaml
+func (client *Client) ExportSchedule(format string, history bool) ([]byte, error) {
+	query := url.Values{"format": {format}}
+	if history {
+		query.Set("history", "true")
+	}
+	req, err := client.newRequest(http.MethodGet, "/export", query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return nil, decodeError(res)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// ImportSchedule uploads an export as it is. A rejected import returns its report together with the error.
+func (client *Client) ImportSchedule(data []byte, format string, mode string, dryRun bool) (*models.ImportReport, error) {
+	query := url.Values{"mode": {mode}}
+	if dryRun {
+		query.Set("dryRun", "true")
+	}
+	req, err := client.newRequest(http.MethodPost, "/import", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Type", "application/"+format)
+
+	res, err := client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		errorResponse := models.ImportErrorResponse{}
+		body, _ := io.ReadAll(res.Body)
+		if json.Unmarshal(body, &errorResponse) != nil || errorResponse.Error == nil {
+			return nil, &ApiError{Status: res.StatusCode, ApiError: models.ApiError{Code: "unknown", Message: http.StatusText(res.StatusCode)}}
+		}
+		return errorResponse.Report, &ApiError{Status: res.StatusCode, ApiError: *errorResponse.Error}
+	}
+	report := &models.ImportReport{}
+	return report, json.NewDecoder(res.Body).Decode(report)
+}
+
+// StreamEvent is a server sent event of the alert stream
+type StreamEvent struct {
+	Name string
+	Data models.AlertEventResource
+}
+
+// WatchAlerts reads the alert stream and calls handle for every event until the connection ends
+func (client *Client) WatchAlerts(handle func(event *StreamEvent) error) error {
+	req, err := client.newRequest(http.MethodGet, "/alerts", nil, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	res, err := client.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return decodeError(res)
+	}
+	return readEvents(res.Body, handle)
+}
+
+// readEvents parses a server sent event stream, events without a JSON payload are skipped
+func readEvents(reader io.Reader, handle func(event *StreamEvent) error) error {
+	scanner := bufio.NewScanner(reader)
+	event := &StreamEvent{}
+	var data strings.Builder
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() > 0 && json.Unmarshal([]byte(data.String()), &event.Data) == nil {
+				if err := handle(event); err != nil {
+					return err
+				}
+			}
+			event = &StreamEvent{}
+			data.Reset()
+		case strings.HasPrefix(line, "event:"):
+			event.Name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"scheduler/models"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func (app *App) List(args []string) error {
+	flags := commandFlags(app, "ls", "")
+	tag := flags.String("tag", "", "only tasks with the tag")
+	state := flags.String("state", "", "only active, inactive or paused tasks")
+	trigger := flags.String("trigger", "", "only popup, audio or webhook tasks")
+	sort := flags.String("sort", "", "order by remaining, name or priority")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	filter := url.Values{}
+	for key, value := range map[string]string{"tag": *tag, "state": *state, "trigger": *trigger, "sort": *sort} {
+		if value != "" {
+			filter.Set(key, value)
+		}
+	}
+
+	tasks, err := app.client.ListTasks(filter)
+	if err != nil {
+		return err
+	}
+	if app.json {
+		return app.printJSON(tasks)
+	}
+	app.printTasks(tasks)
+	return nil
+}
+
+func (app *App) Add(args []string) error {
+	flags := commandFlags(app, "add", "NAME SCHEDULE")
+	trigger := flags.String("trigger", string(models.Popup), "popup, audio or webhook")
+	tags := flags.String("tags", "", "comma separated tags")
+	priority := flags.String("priority", "normal", "low, normal or high")
+	activate := flags.Bool("activate", false, "start the timer right away")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return errUsage
+	}
+
+	taskPriority, err := parsePriority(*priority)
+	if err != nil {
+		return err
+	}
+
+	request := &models.TaskRequest{
+		Name: flags.Arg(0),
+		// the schedule may be given unquoted, like: add Tea in 4min
+		Schedule: strings.Join(flags.Args()[1:], " "),
+		Trigger:  models.TaskTrigger(*trigger),
+		Priority: taskPriority,
+	}
+	if *tags != "" {
+		request.Tags = strings.Split(*tags, ",")
+	}
+
+	task, err := app.client.CreateTask(request)
+	if err != nil {
+		return err
+	}
+	if *activate {
+		if task, err = app.client.TaskAction(task.Id, "activate", nil); err != nil {
+			return err
+		}
+	}
+
+	if app.json {
+		return app.printJSON(task)
+	}
+	app.printTasks([]*models.TaskResource{task})
+	return nil
+}
+
+func (app *App) Remove(args []string) error {
+	flags := commandFlags(app, "rm", "TASK...")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	tasks, err := app.resolveTasks(flags.Args())
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if err := app.client.DeleteTask(task.Id); err != nil {
+			return fmt.Errorf("%s: %w", task.Name, err)
+		}
+		if !app.json {
+			fmt.Fprintf(app.stdout, "Deleted %s\n", task.Name)
+		}
+	}
+	return nil
+}
+
+func (app *App) Snooze(args []string) error {
+	flags := commandFlags(app, "snooze", "TASK")
+	duration := flags.String("for", "", "duration like 10min, 5min by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	tasks, err := app.resolveTasks(flags.Args())
+	if err != nil {
+		return err
+	}
+	task, err := app.client.TaskAction(tasks[0].Id, "snooze", &models.SnoozeRequest{Duration: *duration})
+	if err != nil {
+		return err
+	}
+
+	if app.json {
+		return app.printJSON(task)
+	}
+	app.printTasks([]*models.TaskResource{task})
+	return nil
+}
+
+// actionCommand creates the commands that post an action without options to every given task
+func (app *App) actionCommand(action string) func(args []string) error {
+	return func(args []string) error {
+		flags := commandFlags(app, action, "TASK...")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			flags.Usage()
+			return errUsage
+		}
+
+		tasks, err := app.resolveTasks(flags.Args())
+		if err != nil {
+			return err
+		}
+
+		var updated []*models.TaskResource
+		for _, task := range tasks {
+			result, err := app.client.TaskAction(task.Id, action, nil)
+			if err != nil {
+				return fmt.Errorf("%s: %w", task.Name, err)
+			}
+			if result != nil {
+				updated = append(updated, result)
+			} else if !app.json {
+				fmt.Fprintf(app.stdout, "Marked %s as done\n", task.Name)
+			}
+		}
+
+		if len(updated) == 0 {
+			return nil
+		}
+		if app.json {
+			return app.printJSON(updated)
+		}
+		app.printTasks(updated)
+		return nil
+	}
+}
+
+// Watch prints the alerts of all schedules of the user and rings the terminal bell for each
+func (app *App) Watch(args []string) error {
+	flags := commandFlags(app, "watch", "")
+	quiet := flags.Bool("quiet", false, "don't ring the bell")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return app.client.WatchAlerts(func(event *StreamEvent) error {
+		if event.Name != "task-alert" || event.Data.Task == nil {
+			return nil
+		}
+		if app.json {
+			return app.printJSON(event.Data)
+		}
+
+		bell := "\a"
+		if *quiet {
+			bell = ""
+		}
+		task := event.Data.Task
+		fmt.Fprintf(app.stdout, "%s%s  %s (%s, %s)\n", bell, time.Now().Format("15:04:05"), task.Name, task.Schedule, task.Trigger)
+		return nil
+	})
+}
+
+// Export writes the schedule to a file or stdout, the format follows the file extension unless it's given
+func (app *App) Export(args []string) error {
+	flags := commandFlags(app, "export", "")
+	format := flags.String("format", "", "json or yaml")
+	history := flags.Bool("history", false, "include the occurrences of the tasks")
+	output := flags.String("o", "", "file to write instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = models.ExportFormat(*output)
+	}
+
+	data, err := app.client.ExportSchedule(models.ExportFormat(*format), *history)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = app.stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0o600)
+}
+
+// Import uploads an export and prints what it changed, or would change on a dry run
+func (app *App) Import(args []string) error {
+	flags := commandFlags(app, "import", "FILE")
+	format := flags.String("format", "", "json or yaml, by default the file extension")
+	mode := flags.String("mode", models.ImportMerge, "merge adds what's missing, replace makes the schedule exactly the file")
+	dryRun := flags.Bool("dry-run", false, "only show the changes")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	name := flags.Arg(0)
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(app.stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = name
+	}
+
+	report, err := app.client.ImportSchedule(data, models.ExportFormat(*format), *mode, *dryRun)
+	if report != nil {
+		if app.json {
+			if jsonErr := app.printJSON(report); jsonErr != nil {
+				return jsonErr
+			}
+		} else {
+			app.printImportReport(report)
+		}
+	}
+	return err
+}
+
+func (app *App) printImportReport(report *models.ImportReport) {
+	for _, issue := range report.Errors {
+		fmt.Fprintf(app.stdout, "Invalid %s (%s): %s\n", issue.Name, issue.Id, issue.Message)
+	}
+	for _, issue := range report.Conflicts {
+		fmt.Fprintf(app.stdout, "Conflict %s (%s): %s\n", issue.Name, issue.Id, issue.Message)
+	}
+	if !report.Ok() {
+		return
+	}
+
+	summary := fmt.Sprintf("%d created, %d updated, %d unchanged, %d removed",
+		len(report.Created), len(report.Updated), len(report.Unchanged), len(report.Removed))
+	if report.DryRun {
+		summary += " (dry run, nothing was changed)"
+	}
+	fmt.Fprintln(app.stdout, summary)
+}
+
+// resolveTasks finds the tasks given by id or by name
+func (app *App) resolveTasks(refs []string) ([]*models.TaskResource, error) {
+	tasks, err := app.client.ListTasks(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make([]*models.TaskResource, 0, len(refs))
+	for _, ref := range refs {
+		task, err := findTask(tasks, ref)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, task)
+	}
+	return resolved, nil
+}
+
+func findTask(tasks []*models.TaskResource, ref string) (*models.TaskResource, error) {
+	var byName []*models.TaskResource
+	for _, task := range tasks {
+		if task.Id == ref {
+			return task, nil
+		}
+		if strings.EqualFold(task.Name, ref) {
+			byName = append(byName, task)
+		}
+	}
+
+	switch len(byName) {
+	case 0:
+		return nil, fmt.Errorf("no task %q", ref)
+	case 1:
+		return byName[0], nil
+	default:
+		return nil, fmt.Errorf("%d tasks are named %q, use the id", len(byName), ref)
+	}
+}
+
+func parsePriority(value string) (models.TaskPriority, error) {
+	switch value {
+	case "low":
+		return models.PriorityLow, nil
+	case "normal", "":
+		return models.PriorityNormal, nil
+	case "high":
+		return models.PriorityHigh, nil
+	default:
+		return 0, fmt.Errorf("invalid priority %q, use low, normal or high", value)
+	}
+}
+
+func (app *App) printJSON(value any) error {
+	encoder := json.NewEncoder(app.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func (app *App) printTasks(tasks []*models.TaskResource) {
+	writer := tabwriter.NewWriter(app.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tNAME\tSCHEDULE\tTRIGGER\tSTATE\tREMAINING\tPRIORITY\tTAGS")
+	for _, task := range tasks {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			task.Id, task.Name, task.Schedule, task.Trigger, taskState(task), remaining(task), task.Priority, strings.Join(task.Tags, ","))
+	}
+	writer.Flush()
+}
+
+func taskState(task *models.TaskResource) string {
+	switch {
+	case task.Active:
+		return models.TaskStateActive
+	case task.Paused:
+		return models.TaskStatePaused
+	default:
+		return models.TaskStateInactive
+	}
+}
+
+func remaining(task *models.TaskResource) string {
+	if task.RemainingSeconds == nil {
+		return "-"
+	}
+	return (time.Duration(*task.RemainingSeconds) * time.Second).String()
+}
@@ -0,0 +1,134 @@
+// Command scheduler manages the tasks of a scheduler server from the terminal.
+//
+// It talks to the JSON API with a personal API token, created on the tokens page of the server:
+//
+//	export SCHEDULER_URL=https://scheduler.example.com SCHEDULER_TOKEN=sch_...
+//	scheduler ls
+//	scheduler add --trigger audio --tags kitchen Tea "in 4min"
+//	scheduler watch
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const usage = `Usage: scheduler [flags] <command> [arguments]
+
+Commands:
+  ls                         list the tasks
+  add NAME SCHEDULE          add a task, e.g. add Tea "in 4min"
+  activate TASK...           start the timers of tasks
+  deactivate TASK...         stop the timers of tasks
+  rm TASK...                 delete tasks
+  snooze TASK                let a task fire again later
+  done TASK...               mark the alerts of tasks as done
+  watch                      print alerts as they fire
+  export                     save the tasks and routines as JSON or YAML
+  import FILE                merge or replace the schedule with an export, - reads stdin
+
+TASK is the id or the name of a task. Run "scheduler <command> -h" for the flags of a command.
+
+Flags:
+`
+
+// App holds the global flags and the output streams of a command run
+type App struct {
+	client *Client
+	json   bool
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("scheduler", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	server := flags.String("server", envOr("SCHEDULER_URL", "http://localhost:3000"), "server URL, $SCHEDULER_URL")
+	token := flags.String("token", os.Getenv("SCHEDULER_TOKEN"), "personal API token, $SCHEDULER_TOKEN")
+	schedule := flags.String("schedule", os.Getenv("SCHEDULER_SCHEDULE"), "team id to work on instead of the personal schedule, $SCHEDULER_SCHEDULE")
+	jsonOutput := flags.Bool("json", false, "print JSON instead of tables")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	if *token == "" {
+		fmt.Fprintln(stderr, "scheduler: missing API token, set --token or $SCHEDULER_TOKEN")
+		return 2
+	}
+
+	app := &App{
+		client: NewClient(*server, *token, *schedule),
+		json:   *jsonOutput,
+		stdin:  os.Stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}
+
+	commands := map[string]func(args []string) error{
+		"ls":         app.List,
+		"add":        app.Add,
+		"activate":   app.actionCommand("activate"),
+		"deactivate": app.actionCommand("deactivate"),
+		"rm":         app.Remove,
+		"snooze":     app.Snooze,
+		"done":       app.actionCommand("done"),
+		"watch":      app.Watch,
+		"export":     app.Export,
+		"import":     app.Import,
+	}
+
+	name := flags.Arg(0)
+	command, exists := commands[name]
+	if !exists {
+		fmt.Fprintf(stderr, "scheduler: unknown command %q\n", name)
+		flags.Usage()
+		return 2
+	}
+
+	if err := command(flags.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) || errors.Is(err, errUsage) {
+			return 2
+		}
+		fmt.Fprintf(stderr, "scheduler %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// errUsage is returned by commands after printing their usage
+var errUsage = errors.New("usage")
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func commandFlags(app *App, name string, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(app.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(app.stderr, "Usage: scheduler %s %s\n", name, strings.TrimSpace("[flags] "+arguments))
+		flags.PrintDefaults()
+	}
+	return flags
+}
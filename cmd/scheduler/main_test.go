@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"scheduler/models"
+	"strings"
+	"testing"
+)
+
+// newTestServer fakes the API with a single task and records the requests it gets
+func newTestServer(t *testing.T, requests *[]string) *httptest.Server {
+	task := models.NewTaskResource(&models.Task{Id: "t1", Name: "Tea", Schedule: "in 4min", Trigger: models.Audio})
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer sch_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(models.ApiErrorResponse{Error: &models.ApiError{Code: "unauthorized", Message: "Unauthorized"}})
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/tasks":
+			_ = json.NewEncoder(w).Encode(models.Paginate([]*models.TaskResource{task}, models.PageParams{Limit: 200}))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/tasks":
+			request := models.TaskRequest{}
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			created := &models.Task{Id: "t2"}
+			request.Apply(created)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(models.NewTaskResource(created))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/export":
+			*requests = append(*requests, "format="+r.URL.Query().Get("format"))
+			_, _ = w.Write([]byte("version: 1\ntasks: []\n"))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/import":
+			*requests = append(*requests, r.Header.Get("Content-Type"))
+			report := &models.ImportReport{Mode: r.URL.Query().Get("mode"), DryRun: r.URL.Query().Get("dryRun") == "true", Created: []string{"t2"}}
+			if report.Mode == models.ImportMerge {
+				report.Conflicts = []*models.ImportIssue{{Id: "t1", Name: "Tea", Message: "a different task <Tea> has this id"}}
+				w.WriteHeader(http.StatusConflict)
+				_ = json.NewEncoder(w).Encode(models.ImportErrorResponse{
+					ApiErrorResponse: models.ApiErrorResponse{Error: &models.ApiError{Code: "conflict", Message: "conflict"}}, Report: report})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(report)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(models.ApiErrorResponse{Error: &models.ApiError{Code: "not_found", Message: "task not found"}})
+		}
+	}))
+}
+
+func TestRun(t *testing.T) {
+	var requests []string
+	server := newTestServer(t, &requests)
+	defer server.Close()
+
+	tests := []struct {
+		args     []string
+		code     int
+		stdout   string
+		requests []string
+	}{
+		{[]string{"ls"}, 0, "Tea", []string{"GET /api/v1/tasks"}},
+		{[]string{"--json", "ls"}, 0, `"name": "Tea"`, []string{"GET /api/v1/tasks"}},
+		{[]string{"add", "--trigger", "audio", "Coffee", "in", "10min"}, 0, "in 10min", []string{"POST /api/v1/tasks"}},
+		{[]string{"rm", "tea"}, 0, "Deleted Tea", []string{"GET /api/v1/tasks", "DELETE /api/v1/tasks/t1"}},
+		{[]string{"rm", "Cake"}, 1, "", []string{"GET /api/v1/tasks"}},
+		{[]string{"snooze", "t1"}, 1, "", []string{"GET /api/v1/tasks", "POST /api/v1/tasks/t1/snooze"}},
+		{[]string{"add", "Coffee"}, 2, "", nil},
+		{[]string{"brew"}, 2, "", nil},
+	}
+
+	for _, test := range tests {
+		requests = nil
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		args := append([]string{"--server", server.URL, "--token", "sch_test"}, test.args...)
+
+		if code := run(args, stdout, stderr); code != test.code {
+			t.Errorf("run(%v) exit code was incorrect, got: %v (%s), want: %v.", test.args, code, stderr.String(), test.code)
+		}
+		if !strings.Contains(stdout.String(), test.stdout) {
+			t.Errorf("run(%v) output was incorrect, got: %q, want it to contain: %q.", test.args, stdout.String(), test.stdout)
+		}
+		if strings.Join(requests, ", ") != strings.Join(test.requests, ", ") {
+			t.Errorf("run(%v) requests were incorrect, got: %v, want: %v.", test.args, requests, test.requests)
+		}
+	}
+}
+
+func TestRun_ApiError(t *testing.T) {
+	var requests []string
+	server := newTestServer(t, &requests)
+	defer server.Close()
+
+	stderr := &bytes.Buffer{}
+	code := run([]string{"--server", server.URL, "--token", "sch_wrong", "ls"}, &bytes.Buffer{}, stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "401 unauthorized") {
+		t.Errorf("run with invalid token was incorrect, got: %v %q, want: 1 and the error object.", code, stderr.String())
+	}
+}
+
+func TestRun_ExportImport(t *testing.T) {
+	var requests []string
+	server := newTestServer(t, &requests)
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "schedule.yaml")
+	tests := []struct {
+		args     []string
+		code     int
+		stdout   string
+		requests []string
+	}{
+		{[]string{"export"}, 0, "version: 1", []string{"GET /api/v1/export", "format=json"}},
+		{[]string{"export", "-o", file}, 0, "", []string{"GET /api/v1/export", "format=yaml"}},
+		{[]string{"import", "--mode", "replace", "--dry-run", file}, 0, "1 created, 0 updated, 0 unchanged, 0 removed (dry run",
+			[]string{"POST /api/v1/import", "application/yaml"}},
+		{[]string{"import", file}, 1, "Conflict Tea (t1)", []string{"POST /api/v1/import", "application/yaml"}},
+		{[]string{"import", filepath.Join(t.TempDir(), "missing.json")}, 1, "", nil},
+		{[]string{"import"}, 2, "", nil},
+	}
+
+	for _, test := range tests {
+		requests = nil
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		args := append([]string{"--server", server.URL, "--token", "sch_test"}, test.args...)
+
+		if code := run(args, stdout, stderr); code != test.code {
+			t.Errorf("run(%v) exit code was incorrect, got: %v (%s), want: %v.", test.args, code, stderr.String(), test.code)
+		}
+		if !strings.Contains(stdout.String(), test.stdout) {
+			t.Errorf("run(%v) output was incorrect, got: %q, want it to contain: %q.", test.args, stdout.String(), test.stdout)
+		}
+		if strings.Join(requests, ", ") != strings.Join(test.requests, ", ") {
+			t.Errorf("run(%v) requests were incorrect, got: %v, want: %v.", test.args, requests, test.requests)
+		}
+	}
+
+	if data, err := os.ReadFile(file); err != nil || !strings.HasPrefix(string(data), "version: 1") {
+		t.Errorf("Exported file was incorrect, got: %q %v, want: the YAML of the server.", data, err)
+	}
+}
+
+func TestReadEvents(t *testing.T) {
+	stream := "event:tasks-update\ndata:{\"schedule\":\"u1\"}\n\n" +
+		"event:task-alert\ndata:{\"schedule\":\"team\",\"task\":{\"id\":\"t1\",\"name\":\"Tea\"}}\n\n" +
+		"event:message\ndata:not json\n\n"
+
+	var events []*StreamEvent
+	err := readEvents(strings.NewReader(stream), func(event *StreamEvent) error {
+		events = append(events, event)
+		return nil
+	})
+
+	if err != nil || len(events) != 2 {
+		t.Fatalf("readEvents was incorrect, got: %v events, %v, want: 2 events.", len(events), err)
+	}
+	if events[1].Name != "task-alert" || events[1].Data.Schedule != "team" || events[1].Data.Task.Name != "Tea" {
+		t.Errorf("readEvents alert was incorrect, got: %+v.", events[1])
+	}
+}
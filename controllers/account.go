@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"scheduler/models"
+	"strings"
+)
+
+func (ac *AuthController) GetAccount(c *gin.Context) {
+	c.HTML(http.StatusOK, "pages/account", models.AccountPageData{PageData: GetPageData(c)})
+}
+
+// UpdateWebhook sets the personal webhook the alerts of the user are posted to
+func (ac *AuthController) UpdateWebhook(c *gin.Context) {
+	formData := &models.WebhookFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+
+	user := CurrentUser(c)
+	pageData := models.AccountPageData{PageData: GetPageData(c)}
+
+	webhookUrl := strings.TrimSpace(formData.WebhookUrl)
+	if webhookUrl != "" {
+		if err := ValidateWebhookUrl(webhookUrl); err != nil {
+			pageData.Error = err.Error()
+			c.HTML(http.StatusOK, "account/webhook", pageData)
+			return
+		}
+	}
+
+	if err := ac.userDBM.UpdateWebhookUrl(user.Id, webhookUrl); err != nil {
+		LogError(err, "Could not update webhook", c)
+		pageData.Error = "FAILED TO SAVE WEBHOOK"
+		c.HTML(http.StatusOK, "account/webhook", pageData)
+		return
+	}
+	user.WebhookUrl = webhookUrl
+
+	log.Info().Str("user", user.Name).Msg("Updated personal webhook")
+	pageData.Saved = true
+	c.HTML(http.StatusOK, "account/webhook", pageData)
+}
@@ -0,0 +1,530 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"scheduler/models"
+	"strings"
+)
+
+// apiPathPrefix marks the JSON API, errors of its requests are answered with error objects instead of HTML
+const apiPathPrefix = "/api/"
+
+func isApiRequest(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, apiPathPrefix)
+}
+
+// RegisterApiRoutes adds the /api/v1 endpoints to the groups of the token scopes they need.
+// They are documented in static/openapi.json, keep both in sync.
+func (tc *TaskController) RegisterApiRoutes(read gin.IRouter, trigger gin.IRouter, manage gin.IRouter) {
+	readApi := read.Group("/api/v1")
+	triggerApi := trigger.Group("/api/v1")
+	manageApi := manage.Group("/api/v1")
+
+	readApi.GET("/tasks", tc.ApiListTasks)
+	manageApi.POST("/tasks", tc.ApiCreateTask)
+	readApi.GET("/tasks/:id", tc.ApiGetTask)
+	manageApi.PUT("/tasks/:id", tc.ApiUpdateTask)
+	manageApi.DELETE("/tasks/:id", tc.ApiDeleteTask)
+	triggerApi.POST("/tasks/:id/activate", tc.ApiActivateTask)
+	triggerApi.POST("/tasks/:id/deactivate", tc.ApiDeactivateTask)
+	triggerApi.POST("/tasks/:id/pause", tc.ApiPauseTask)
+	triggerApi.POST("/tasks/:id/resume", tc.ApiResumeTask)
+	triggerApi.POST("/tasks/:id/snooze", tc.ApiSnoozeTask)
+	triggerApi.POST("/tasks/:id/done", tc.ApiTaskDone)
+	readApi.GET("/tasks/:id/history", tc.ApiTaskHistory)
+	readApi.GET("/alerts", StreamHeadersMiddleware(), tc.ApiWatchAlerts)
+	readApi.GET("/trash", tc.ApiListTrash)
+	manageApi.POST("/trash/:id/restore", tc.ApiRestoreTask)
+	readApi.GET("/audit", tc.ApiAuditLog)
+	readApi.GET("/export", tc.ApiExportSchedule)
+	manageApi.POST("/import", tc.ApiImportSchedule)
+	manageApi.POST("/config/diff", tc.ApiConfigDiff)
+}
+
+// maxImportSize limits the body of an import
+const maxImportSize = 5 << 20
+
+// GetApiDocs renders the reference of the JSON API from the OpenAPI document
+func GetApiDocs(c *gin.Context) {
+	c.HTML(http.StatusOK, "pages/api-docs", nil)
+}
+
+// abortRequest stops the request with the status, API requests get an error object on top
+func abortRequest(c *gin.Context, status int) {
+	if isApiRequest(c) {
+		apiError(c, status, errorCode(status), http.StatusText(status))
+		return
+	}
+	c.AbortWithStatus(status)
+}
+
+func apiError(c *gin.Context, status int, code string, message string) {
+	c.AbortWithStatusJSON(status, models.ApiErrorResponse{Error: &models.ApiError{Code: code, Message: message}})
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusPreconditionFailed:
+		return "precondition_failed"
+	default:
+		return "internal_error"
+	}
+}
+
+// apiStatus maps the errors of the schedule and task operations to response statuses
+func apiStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, errTaskNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errTaskModified):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, errTaskState):
+		return http.StatusConflict
+	default:
+		return scheduleErrorStatus(err)
+	}
+}
+
+// apiFail answers with the error object of err, internal errors are logged and not revealed
+func apiFail(c *gin.Context, err error) {
+	status := apiStatus(err)
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		LogError(err, "API request failed", c)
+		message = "something went wrong, try again later"
+	}
+	apiError(c, status, errorCode(status), message)
+}
+
+// apiAuthorizeSchedule is authorizeSchedule answering with an error object
+func (tc *TaskController) apiAuthorizeSchedule(c *gin.Context, role models.TeamRole) (*ScheduleAccess, bool) {
+	access, err := tc.scheduleAccess(c, role)
+	if err != nil {
+		apiFail(c, err)
+		return nil, false
+	}
+	return access, true
+}
+
+func renderTask(c *gin.Context, status int, task *models.Task) {
+	c.Header("ETag", models.ETag(task))
+	c.JSON(status, models.NewTaskResource(task))
+}
+
+// ApiListTasks lists the tasks of the schedule, filtered and sorted like the task table, one page at a time
+func (tc *TaskController) ApiListTasks(c *gin.Context) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	filter := &models.TaskFilter{}
+	pageParams := &models.PageParams{}
+	if err := c.ShouldBindQuery(filter); err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if err := c.ShouldBindQuery(pageParams); err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	pageParams.Normalize()
+
+	tasks, err := tc.getTasks(access.Owner)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+	tasks = models.FilterTasks(tasks, filter)
+	resources := make([]*models.TaskResource, 0, len(tasks))
+	for _, task := range tasks {
+		resources = append(resources, models.NewTaskResource(task))
+	}
+
+	page := models.Paginate(resources, *pageParams)
+	etag := models.ETag(page)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Header("ETag", etag)
+	c.JSON(http.StatusOK, page)
+}
+
+func (tc *TaskController) ApiGetTask(c *gin.Context) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+	task, err := tc.findTask(access.Owner, c.Param("id"))
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	if c.GetHeader("If-None-Match") == models.ETag(task) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	renderTask(c, http.StatusOK, task)
+}
+
+func (tc *TaskController) ApiCreateTask(c *gin.Context) {
+	request := &models.TaskRequest{}
+	if err := c.ShouldBindJSON(request); err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	task, err := tc.createTask(access, requestActor(c), request)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	c.Header("Location", "/api/v1/tasks/"+task.Id)
+	renderTask(c, http.StatusCreated, task)
+}
+
+func (tc *TaskController) ApiUpdateTask(c *gin.Context) {
+	request := &models.TaskRequest{}
+	if err := c.ShouldBindJSON(request); err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	task, err := tc.replaceTask(access, requestActor(c), c.Param("id"), c.GetHeader("If-Match"), request)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+	renderTask(c, http.StatusOK, task)
+}
+
+func (tc *TaskController) ApiDeleteTask(c *gin.Context) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	if err := tc.deleteTask(access.Owner, requestActor(c), c.Param("id"), c.GetHeader("If-Match")); err != nil {
+		apiFail(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (tc *TaskController) ApiActivateTask(c *gin.Context) {
+	tc.apiChangeTask(c, models.AuditActivate, activateTask)
+}
+
+func (tc *TaskController) ApiDeactivateTask(c *gin.Context) {
+	tc.apiChangeTask(c, models.AuditDeactivate, deactivateTask)
+}
+
+func (tc *TaskController) ApiPauseTask(c *gin.Context) {
+	tc.apiChangeTask(c, models.AuditPause, pauseTask)
+}
+
+func (tc *TaskController) ApiResumeTask(c *gin.Context) {
+	tc.apiChangeTask(c, models.AuditResume, resumeTask)
+}
+
+// ApiSnoozeTask lets the task fire again after the requested duration
+func (tc *TaskController) ApiSnoozeTask(c *gin.Context) {
+	request := &models.SnoozeRequest{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(request); err != nil {
+			apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+	}
+	duration, err := parseSnooze(request.Duration)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	task, err := tc.snoozeTask(access.Owner, requestActor(c), c.Param("id"), c.GetHeader("If-Match"), duration)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+	renderTask(c, http.StatusOK, task)
+}
+
+func (tc *TaskController) ApiTaskDone(c *gin.Context) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	if err := tc.markTaskDone(access.Owner, requestActor(c), c.Param("id")); err != nil {
+		apiFail(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ApiTaskHistory lists the occurrences of a task, newest first
+func (tc *TaskController) ApiTaskHistory(c *gin.Context) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+	task, err := tc.findTask(access.Owner, c.Param("id"))
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	pageParams := &models.PageParams{}
+	if err := c.ShouldBindQuery(pageParams); err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	pageParams.Normalize()
+
+	occurrences, total, err := tc.store.GetOccurrences(access.Owner, task.Id, pageParams.Offset, pageParams.Limit)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiPage[*models.TaskOccurrence]{
+		Data:       occurrences,
+		Pagination: models.Pagination{Offset: pageParams.Offset, Limit: pageParams.Limit, Total: total},
+	})
+}
+
+// ApiListTrash lists the deleted tasks of the schedule, most recently deleted first
+func (tc *TaskController) ApiListTrash(c *gin.Context) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	pageParams := &models.PageParams{}
+	if err := c.ShouldBindQuery(pageParams); err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	pageParams.Normalize()
+
+	trash, err := tc.store.GetTrash(access.Owner)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+	resources := make([]*models.DeletedTaskResource, 0, len(trash))
+	for _, deleted := range trash {
+		resources = append(resources, models.NewDeletedTaskResource(deleted))
+	}
+
+	c.JSON(http.StatusOK, models.Paginate(resources, *pageParams))
+}
+
+// ApiRestoreTask takes a task back from the trash, it fails with a conflict while another task has its id
+func (tc *TaskController) ApiRestoreTask(c *gin.Context) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	task, err := tc.restoreTask(access.Owner, requestActor(c), c.Param("id"))
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+	renderTask(c, http.StatusOK, task)
+}
+
+// ApiExportSchedule downloads the schedule as JSON or YAML file
+func (tc *TaskController) ApiExportSchedule(c *gin.Context) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+	params := &models.ExportParams{}
+	if err := c.ShouldBindQuery(params); err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	format := models.ExportFormat(params.Format)
+
+	export, err := tc.exportSchedule(access.Owner, params.History)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+	data, err := export.Encode(format)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	contentType := "application/json"
+	if format == models.ExportYAML {
+		contentType = "application/yaml"
+	}
+	c.Header("Content-Disposition", `attachment; filename="schedule.`+format+`"`)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ApiImportSchedule merges a JSON or YAML schedule into the schedule or replaces it. The format follows the
+// Content-Type. Rejected imports are answered with the error object and the report of the problems.
+func (tc *TaskController) ApiImportSchedule(c *gin.Context) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	params := &models.ImportParams{Mode: models.ImportMerge}
+	if err := c.ShouldBindQuery(params); err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	if err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	export, err := models.DecodeScheduleExport(data, models.ExportFormat(c.ContentType()))
+	if err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	report, err := tc.importSchedule(access.Owner, access.Team, requestActor(c), export, params.Mode, params.DryRun)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	switch {
+	case len(report.Errors) > 0:
+		c.AbortWithStatusJSON(http.StatusBadRequest, models.ImportErrorResponse{
+			ApiErrorResponse: models.ApiErrorResponse{Error: &models.ApiError{Code: "invalid_request", Message: "the import has invalid tasks"}},
+			Report:           report,
+		})
+	case len(report.Conflicts) > 0:
+		c.AbortWithStatusJSON(http.StatusConflict, models.ImportErrorResponse{
+			ApiErrorResponse: models.ApiErrorResponse{Error: &models.ApiError{Code: "conflict", Message: "the import conflicts with existing tasks"}},
+			Report:           report,
+		})
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+func (tc *TaskController) apiChangeTask(c *gin.Context, action models.AuditAction, change taskChange) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	task, err := tc.changeTask(access.Owner, requestActor(c), c.Param("id"), c.GetHeader("If-Match"), action, change)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+	renderTask(c, http.StatusOK, task)
+}
+
+// ApiWatchAlerts streams the alerts and task updates of all schedules of the user as server sent events with JSON data.
+// It's the counterpart of /stream, whose events carry rendered HTML.
+func (tc *TaskController) ApiWatchAlerts(c *gin.Context) {
+	clientChan, unsubscribe := tc.sc.Subscribe(CurrentUser(c).Id, tc.ScheduleOwners(c))
+	defer unsubscribe()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-clientChan:
+			if !ok {
+				return false
+			}
+			switch event.Type {
+			case EVENT_TASK_ALERT:
+				if task, isTask := event.Message.(*models.Task); isTask {
+					c.SSEvent("task-alert", models.AlertEventResource{Schedule: event.Owner, Task: models.NewTaskResource(task)})
+				}
+			case EVENT_TASKS_UPDATE:
+				c.SSEvent("tasks-update", models.AlertEventResource{Schedule: event.Owner})
+			}
+			return true
+		}
+	})
+}
+
+// ApiAuditLog lists the audit entries of the schedule, newest first, optionally filtered by task, action, user and time
+func (tc *TaskController) ApiAuditLog(c *gin.Context) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	params := &models.AuditFilterParams{}
+	pageParams := &models.PageParams{}
+	if err := c.ShouldBindQuery(params); err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if err := c.ShouldBindQuery(pageParams); err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	pageParams.Normalize()
+
+	filter, err := params.Filter()
+	if err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	entries, total, err := tc.store.GetAuditEntries(access.Owner, filter, pageParams.Offset, pageParams.Limit)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiPage[*models.AuditEntry]{
+		Data:       entries,
+		Pagination: models.Pagination{Offset: pageParams.Offset, Limit: pageParams.Limit, Total: total},
+	})
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"scheduler/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAbortRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := func(c *gin.Context) { abortRequest(c, http.StatusForbidden) }
+	router.GET("/tasks", handler)
+	router.GET("/api/v1/tasks", handler)
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	if res.Code != http.StatusForbidden || res.Body.Len() != 0 {
+		t.Errorf("abortRequest(/tasks) was incorrect, got: %v %q, want: %v without body.", res.Code, res.Body.String(), http.StatusForbidden)
+	}
+
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/v1/tasks", nil))
+	body := models.ApiErrorResponse{}
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil || body.Error == nil {
+		t.Fatalf("abortRequest(/api/v1/tasks) body was incorrect, got: %q, want an error object.", res.Body.String())
+	}
+	if res.Code != http.StatusForbidden || body.Error.Code != "forbidden" {
+		t.Errorf("abortRequest(/api/v1/tasks) was incorrect, got: %v %v, want: %v %v.", res.Code, body.Error.Code, http.StatusForbidden, "forbidden")
+	}
+}
+
+func TestMatchesETag(t *testing.T) {
+	task := &models.Task{Id: "1", Name: "Tea", Schedule: "in 5min"}
+
+	tests := []struct {
+		etags string
+		want  bool
+	}{
+		{"", true},
+		{"*", true},
+		{models.ETag(task), true},
+		{`"stale", ` + models.ETag(task), true},
+		{`"stale"`, false},
+	}
+
+	for _, test := range tests {
+		if got := matchesETag(test.etags, task); got != test.want {
+			t.Errorf("matchesETag(%q) was incorrect, got: %v, want: %v.", test.etags, got, test.want)
+		}
+	}
+}
+
+func TestApiStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: name is required", errInvalidRequest), http.StatusBadRequest},
+		{errTaskNotFound, http.StatusNotFound},
+		{errScheduleNotFound, http.StatusNotFound},
+		{errScheduleForbidden, http.StatusForbidden},
+		{errTaskModified, http.StatusPreconditionFailed},
+		{fmt.Errorf("%w: only paused tasks can be resumed", errTaskState), http.StatusConflict},
+		{errors.New("connection lost"), http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		if got := apiStatus(test.err); got != test.want {
+			t.Errorf("apiStatus(%v) was incorrect, got: %v, want: %v.", test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"scheduler/models"
+	"slices"
+)
+
+// ClaimTask assigns a task of a team schedule to the requesting member alone, it's offered on the alert.
+// Viewers may claim too, they are the ones receiving the alert.
+func (tc *TaskController) ClaimTask(c *gin.Context) {
+	author, ok := tc.authorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	tc.assignTask(c, author, []string{CurrentUser(c).Id}, false)
+}
+
+// AssignTask reassigns a task of a team schedule from its alert, the new assignees are alerted right away
+func (tc *TaskController) AssignTask(c *gin.Context) {
+	formData := &models.AssignTaskFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	tc.assignTask(c, author, formData.Assignees, true)
+}
+
+func (tc *TaskController) assignTask(c *gin.Context, author string, userIds []string, alertAssignees bool) {
+	team := currentSchedule(c).Team
+	if team == nil {
+		c.String(http.StatusBadRequest, "")
+		return
+	}
+
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
+		return
+	}
+	task := scheduler.FindTask(c.Param("id"))
+	if task == nil {
+		c.String(http.StatusNotFound, "")
+		return
+	}
+
+	assignees, err := models.NewTaskAssignees(team, userIds)
+	if err != nil {
+		LogError(err, "Invalid task assignees", c)
+		c.String(http.StatusBadRequest, "")
+		return
+	}
+	before := *task
+	task.Assignees = assignees
+
+	updatedSchedule, err := tc.store.UpdateTask(author, task)
+	if err != nil {
+		LogError(err, "Could not assign task", c)
+		c.String(http.StatusInternalServerError, "")
+		return
+	}
+	task = updatedSchedule.FindTask(task.Id)
+	tc.recordAudit(author, requestActor(c), models.AuditAssign, &before, task)
+
+	// the running timer alerts whom it was registered for
+	if task.IsActive() {
+		tc.RegisterTaskSchedule(author, task)
+	}
+
+	user := CurrentUser(c)
+	log.Info().Str("task", task.Name).Strs("assignees", task.AssigneeIds()).Str("user", user.Name).Msg("Assigned task")
+
+	if alertAssignees {
+		recipients := slices.DeleteFunc(task.AssigneeIds(), func(userId string) bool { return userId == user.Id })
+		if len(recipients) > 0 {
+			tc.sendAlert(author, task, recipients)
+		}
+	}
+
+	tc.sc.Message <- &Event{
+		Message: nil,
+		Type:    EVENT_TASKS_UPDATE,
+		Owner:   author,
+	}
+
+	c.HTML(http.StatusOK, "alerts/assignment", tc.alertData(user, author, task))
+}
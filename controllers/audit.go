@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"net/http"
+	"scheduler/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// configActor makes the changes of the watched schedule config
+var configActor = &models.AuditActor{Name: "schedule config", Source: models.AuditSourceConfig}
+
+// requestActor is the user of the request, API requests name their token and browsers their user agent
+func requestActor(c *gin.Context) *models.AuditActor {
+	actor := &models.AuditActor{Source: models.AuditSourceWeb, Client: c.Request.UserAgent(), Ip: c.ClientIP()}
+	if isApiRequest(c) {
+		actor.Source = models.AuditSourceApi
+	}
+	if token := currentToken(c); token != nil {
+		actor.Client = "token " + token.Name
+	}
+	if user := CurrentUser(c); user != nil {
+		actor.UserId = user.Id
+		actor.Name = user.Name
+	}
+	return actor
+}
+
+// recordAudit adds an entry to the audit log of the schedule, failures are logged but don't undo the change
+func (tc *TaskController) recordAudit(owner string, actor *models.AuditActor, action models.AuditAction, before *models.Task, after *models.Task) {
+	if err := tc.store.InsertAuditEntry(models.NewAuditEntry(owner, actor, action, before, after)); err != nil {
+		log.Warn().Err(err).Str("owner", owner).Str("action", string(action)).Msg("Could not record audit entry")
+	}
+}
+
+// taskSnapshots copies the tasks by id, to audit changes made to the tasks in place
+func taskSnapshots(tasks []*models.Task) map[string]*models.Task {
+	snapshots := make(map[string]*models.Task, len(tasks))
+	for _, task := range tasks {
+		snapshot := *task
+		snapshots[task.Id] = &snapshot
+	}
+	return snapshots
+}
+
+// auditTasks records the change of each task from its snapshot for the user of the request
+func (tc *TaskController) auditTasks(c *gin.Context, owner string, action models.AuditAction, before map[string]*models.Task, tasks []*models.Task) {
+	actor := requestActor(c)
+	for _, task := range tasks {
+		tc.recordAudit(owner, actor, action, before[task.Id], task)
+	}
+}
+
+// GetAudit renders the audit log of the schedule, filtered and one page at a time
+func (tc *TaskController) GetAudit(c *gin.Context) {
+	author, ok := tc.authorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	params := &models.AuditFilterParams{}
+	pageParams := &models.PageParams{}
+	_ = c.ShouldBindQuery(params)
+	_ = c.ShouldBindQuery(pageParams)
+	pageParams.Normalize()
+
+	filter, err := params.Filter()
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	entries, total, err := tc.store.GetAuditEntries(author, filter, pageParams.Offset, pageParams.Limit)
+	if err != nil {
+		LogError(err, "Could not read audit log", c)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	pageData := &models.AuditPageData{
+		PageData:   GetPageData(c),
+		Entries:    make([]*models.AuditEntryVM, 0, len(entries)),
+		Filter:     params,
+		Actions:    models.AuditActions,
+		Team:       currentSchedule(c).Team,
+		Pagination: models.Pagination{Offset: pageParams.Offset, Limit: pageParams.Limit, Total: total},
+	}
+	for _, entry := range entries {
+		pageData.Entries = append(pageData.Entries, &models.AuditEntryVM{AuditEntry: entry})
+	}
+	c.HTML(http.StatusOK, "pages/audit", pageData)
+}
+
+// ExportAudit downloads all audit entries of the filter as JSON, like the API but without pages
+func (tc *TaskController) ExportAudit(c *gin.Context) {
+	author, ok := tc.authorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	params := &models.AuditFilterParams{}
+	_ = c.ShouldBindQuery(params)
+	filter, err := params.Filter()
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries := []*models.AuditEntry{}
+	for offset := 0; ; offset += models.MaxPageLimit {
+		page, total, err := tc.store.GetAuditEntries(author, filter, offset, models.MaxPageLimit)
+		if err != nil {
+			LogError(err, "Could not read audit log", c)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		entries = append(entries, page...)
+		if len(page) == 0 || int64(offset+len(page)) >= total {
+			break
+		}
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="audit.json"`)
+	c.JSON(http.StatusOK, entries)
+}
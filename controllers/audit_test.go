@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"scheduler/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestChangeTask_Audit(t *testing.T) {
+	store := models.NewMemoryStore()
+	tc := NewTaskController(NewStreamController(), nil, store, store, store)
+
+	activatedTime := time.Now()
+	task := &models.Task{Id: "deploy", Name: "Deploy", Schedule: "in 5min", Trigger: models.Popup, ActivatedTime: &activatedTime}
+	if _, err := store.InsertTask("team", task); err != nil {
+		t.Fatalf("Inserting a task failed: %v", err)
+	}
+
+	actor := &models.AuditActor{UserId: "u1", Name: "ann", Source: models.AuditSourceApi, Client: "token ci", Ip: "10.0.0.1"}
+	if _, err := tc.changeTask("team", actor, "deploy", "", models.AuditDeactivate, deactivateTask); err != nil {
+		t.Fatalf("Deactivating the task failed: %v", err)
+	}
+
+	entries, total, err := store.GetAuditEntries("team", &models.AuditFilter{Action: models.AuditDeactivate}, 0, 10)
+	if err != nil || total != 1 {
+		t.Fatalf("Audit entries were incorrect, got: %v %v, want: %v.", total, err, 1)
+	}
+	if entries[0].Actor.UserId != "u1" || entries[0].TaskId != "deploy" {
+		t.Errorf("Audit entry was incorrect, got: %v %v, want: %v %v.", entries[0].Actor.UserId, entries[0].TaskId, "u1", "deploy")
+	}
+	if len(entries[0].Changes) != 1 || entries[0].Changes[0].Field != "activatedTime" || entries[0].Changes[0].After != nil {
+		t.Errorf("Audit changes were incorrect, got: %v, want: %v.", entries[0].Changes, "activatedTime unset")
+	}
+}
+
+func TestRequestActor_ForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if err := router.SetTrustedProxies(nil); err != nil {
+		t.Fatalf("Setting trusted proxies failed: %v", err)
+	}
+	var actor *models.AuditActor
+	router.GET("/", func(c *gin.Context) {
+		c.Set(userContextKey, &models.User{Id: "u1", Name: "ann"})
+		actor = requestActor(c)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.10:4711"
+	req.Header.Set("X-Forwarded-For", "203.0.113.99")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if actor == nil || actor.Ip != "192.0.2.10" {
+		t.Errorf("Actor IP was incorrect, got: %v, want: %v.", actor, "192.0.2.10")
+	}
+}
@@ -0,0 +1,368 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"net/url"
+	"os"
+	"scheduler/models"
+	"scheduler/utils"
+	"strings"
+)
+
+const (
+	userContextKey    = "user"
+	sessionContextKey = "session"
+	tokenContextKey   = "apiToken"
+	sessionCookieName = "scheduler_session"
+	csrfHeaderName    = "X-CSRF-Token"
+	csrfFormField     = "csrf-token"
+	minPasswordLength = 8
+)
+
+type AuthController struct {
+	userDBM    models.UserStore
+	sessionDBM models.SessionStore
+	tokenDBM   models.TokenStore
+	// userHeader is the header of a trusted authenticating proxy, empty if sessions are the only way in
+	userHeader string
+	// oidcProvider is the name of the single sign-on provider shown on the login page, see NewOIDCController
+	oidcProvider string
+}
+
+func NewAuthController(userDBM models.UserStore, sessionDBM models.SessionStore, tokenDBM models.TokenStore) *AuthController {
+	userHeader := os.Getenv("AUTH_USER_HEADER")
+	if userHeader != "" {
+		log.Warn().Str("header", userHeader).Msg("Trusting the proxy user header, make sure the app is only reachable through the proxy")
+	}
+
+	return &AuthController{
+		userDBM:    userDBM,
+		sessionDBM: sessionDBM,
+		tokenDBM:   tokenDBM,
+		userHeader: userHeader,
+	}
+}
+
+func registrationEnabled() bool {
+	return os.Getenv("REGISTRATION_ENABLED") != "false"
+}
+
+func (ac *AuthController) loginPageData(name string, error string) models.AuthPageData {
+	return models.AuthPageData{
+		Name:                name,
+		Error:               error,
+		RegistrationEnabled: registrationEnabled(),
+		OIDCProvider:        ac.oidcProvider,
+	}
+}
+
+func (ac *AuthController) GetLogin(c *gin.Context) {
+	c.HTML(http.StatusOK, "pages/login", ac.loginPageData("", ""))
+}
+
+func (ac *AuthController) Login(c *gin.Context) {
+	formData := &models.AuthFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+
+	user, err := models.Authenticate(ac.userDBM, formData.Name, formData.Password)
+	if err != nil {
+		log.Info().Str("user", formData.Name).Msg("Failed login")
+		c.HTML(http.StatusUnauthorized, "pages/login", ac.loginPageData(formData.Name, "Invalid name or password"))
+		return
+	}
+
+	if err := ac.startSession(c, user); err != nil {
+		LogError(err, "Could not create session", c)
+		c.HTML(http.StatusInternalServerError, "pages/login", ac.loginPageData(formData.Name, "Could not log in, please try again"))
+		return
+	}
+
+	log.Info().Str("user", user.Name).Msg("Logged in")
+	c.Redirect(http.StatusSeeOther, "/tasks")
+}
+
+func (ac *AuthController) GetRegister(c *gin.Context) {
+	if !registrationEnabled() {
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
+	c.HTML(http.StatusOK, "pages/register", models.AuthPageData{RegistrationEnabled: true})
+}
+
+// Register creates a password account and logs it in.
+// The name configured in LEGACY_SCHEDULE_OWNER takes over the schedule from before user accounts existed.
+func (ac *AuthController) Register(c *gin.Context) {
+	if !registrationEnabled() {
+		c.String(http.StatusForbidden, "")
+		return
+	}
+
+	formData := &models.AuthFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+
+	name := strings.TrimSpace(formData.Name)
+	pageData := models.AuthPageData{Name: name, RegistrationEnabled: true}
+
+	switch {
+	case name == "":
+		pageData.Error = "Name is required"
+	case len(formData.Password) < minPasswordLength:
+		pageData.Error = "Password needs at least 8 characters"
+	case formData.Password != formData.PasswordConfirm:
+		pageData.Error = "Passwords do not match"
+	}
+	if pageData.Error != "" {
+		c.HTML(http.StatusBadRequest, "pages/register", pageData)
+		return
+	}
+
+	claimLegacy := name == os.Getenv("LEGACY_SCHEDULE_OWNER")
+	user, err := models.RegisterUser(ac.userDBM, name, formData.Password, claimLegacy)
+	if err != nil {
+		if errors.Is(err, models.ErrUserExists) {
+			pageData.Error = "Name is already taken"
+			c.HTML(http.StatusConflict, "pages/register", pageData)
+			return
+		}
+		LogError(err, "Could not register user", c)
+		pageData.Error = "Could not register, please try again"
+		c.HTML(http.StatusInternalServerError, "pages/register", pageData)
+		return
+	}
+
+	if err := ac.startSession(c, user); err != nil {
+		LogError(err, "Could not create session", c)
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
+
+	log.Info().Str("user", user.Name).Msg("Registered user")
+	c.Redirect(http.StatusSeeOther, "/tasks")
+}
+
+func (ac *AuthController) Logout(c *gin.Context) {
+	if token, err := c.Cookie(sessionCookieName); err == nil {
+		_ = ac.sessionDBM.DeleteSession(token)
+	}
+	ac.setSessionCookie(c, "", -1)
+
+	c.Redirect(http.StatusSeeOther, "/login")
+}
+
+func (ac *AuthController) startSession(c *gin.Context, user *models.User) error {
+	token := utils.RandomToken()
+	_, err := ac.sessionDBM.CreateSession(user.Id, token, utils.RandomToken())
+	if err != nil {
+		return err
+	}
+
+	ac.setSessionCookie(c, token, int(models.SessionDuration.Seconds()))
+	return nil
+}
+
+func (ac *AuthController) setSessionCookie(c *gin.Context, token string, maxAge int) {
+	secure := c.Request.TLS != nil || os.Getenv("APP_ENV") == "production"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookieName, token, maxAge, "/", "", secure, true)
+}
+
+// AuthMiddleware resolves the user of the request and stores it in the context, see CurrentUser.
+// The user comes from an API token sent as "Authorization: Bearer", the header of a trusted proxy (AUTH_USER_HEADER)
+// if configured, or the session cookie.
+// Anonymous page loads are redirected to the login, everything else gets a 401.
+func (ac *AuthController) AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := ac.authenticate(c)
+		if err != nil {
+			LogError(err, "Could not resolve user", c)
+			abortRequest(c, http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			rejectAnonymous(c)
+			return
+		}
+
+		c.Set(userContextKey, user)
+		c.Next()
+	}
+}
+
+func (ac *AuthController) authenticate(c *gin.Context) (*models.User, error) {
+	if plainToken, ok := bearerToken(c.GetHeader("Authorization")); ok {
+		user, token, err := ac.authenticateToken(plainToken)
+		if err != nil {
+			return nil, nil
+		}
+
+		c.Set(tokenContextKey, token)
+		return user, nil
+	}
+
+	if ac.userHeader != "" {
+		userName := strings.TrimSpace(c.GetHeader(ac.userHeader))
+		if userName != "" {
+			return models.FindOrCreateUser(ac.userDBM, userName)
+		}
+	}
+
+	token, err := c.Cookie(sessionCookieName)
+	if err != nil || token == "" {
+		return nil, nil
+	}
+
+	session, err := ac.sessionDBM.GetSession(token)
+	if err != nil {
+		return nil, nil
+	}
+
+	user, err := ac.userDBM.GetUserById(session.UserId)
+	if err != nil {
+		return nil, nil
+	}
+
+	c.Set(sessionContextKey, session)
+	return user, nil
+}
+
+// authenticateToken resolves a personal API token to its user
+func (ac *AuthController) authenticateToken(plainToken string) (*models.User, *models.ApiToken, error) {
+	token, err := ac.tokenDBM.GetToken(plainToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := ac.userDBM.GetUserById(token.UserId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, token, nil
+}
+
+// bearerToken extracts the token of an Authorization header value
+func bearerToken(authorization string) (string, bool) {
+	scheme, token, found := strings.Cut(authorization, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func rejectAnonymous(c *gin.Context) {
+	if c.GetHeader("HX-Request") != "" {
+		c.Header("HX-Redirect", "/login")
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if c.Request.Method == http.MethodGet && strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.Redirect(http.StatusSeeOther, "/login")
+		c.Abort()
+		return
+	}
+	abortRequest(c, http.StatusUnauthorized)
+}
+
+// CSRFMiddleware protects state changing requests. Session users have to send the token of their session,
+// which the pages hand to htmx as X-CSRF-Token header. Requests authenticated by the trusted proxy carry
+// no session, for them the Origin has to match the host. API tokens are never sent by the browser on its own,
+// requests authenticated by one need no protection.
+func CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if currentToken(c) != nil {
+			c.Next()
+			return
+		}
+
+		if session := currentSession(c); session != nil {
+			token := c.GetHeader(csrfHeaderName)
+			if token == "" {
+				token = c.PostForm(csrfFormField)
+			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(session.CsrfToken)) != 1 {
+				log.Warn().Str("path", c.Request.URL.Path).Msg("Rejected request with invalid CSRF token")
+				abortRequest(c, http.StatusForbidden)
+				return
+			}
+		} else if !isSameOrigin(c) {
+			log.Warn().Str("path", c.Request.URL.Path).Msg("Rejected cross origin request")
+			abortRequest(c, http.StatusForbidden)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func isSameOrigin(c *gin.Context) bool {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return c.GetHeader("Sec-Fetch-Site") == "same-origin"
+	}
+	originUrl, err := url.Parse(origin)
+	return err == nil && originUrl.Host == c.Request.Host
+}
+
+// RequireScope limits requests authenticated by an API token to the token's scopes.
+// Browser sessions and proxy users are not limited.
+func RequireScope(scope models.TokenScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := currentToken(c); token != nil && !token.HasScope(scope) {
+			log.Warn().Str("path", c.Request.URL.Path).Str("scope", string(scope)).Msg("Rejected API token without scope")
+			abortRequest(c, http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// DenyApiTokens keeps API tokens away from endpoints only a person should use, like creating more tokens
+func DenyApiTokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if currentToken(c) != nil {
+			abortRequest(c, http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentUser returns the user resolved by the AuthMiddleware
+func CurrentUser(c *gin.Context) *models.User {
+	return c.MustGet(userContextKey).(*models.User)
+}
+
+func currentSession(c *gin.Context) *models.Session {
+	if session, exists := c.Get(sessionContextKey); exists {
+		return session.(*models.Session)
+	}
+	return nil
+}
+
+func currentToken(c *gin.Context) *models.ApiToken {
+	if token, exists := c.Get(tokenContextKey); exists {
+		return token.(*models.ApiToken)
+	}
+	return nil
+}
+
+// GetPageData collects what every full page needs for the logged-in user
+func GetPageData(c *gin.Context) models.PageData {
+	pageData := models.PageData{User: CurrentUser(c)}
+	if session := currentSession(c); session != nil {
+		pageData.CsrfToken = session.CsrfToken
+	}
+	return pageData
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"scheduler/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCSRFTestRouter(session *models.Session) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if session != nil {
+			c.Set(sessionContextKey, session)
+		}
+	}, CSRFMiddleware())
+	handler := func(c *gin.Context) { c.String(http.StatusOK, "") }
+	router.GET("/tasks", handler)
+	router.PUT("/tasks/activate", handler)
+	return router
+}
+
+func TestCSRFMiddleware_Session(t *testing.T) {
+	router := newCSRFTestRouter(&models.Session{CsrfToken: "secret"})
+
+	tests := []struct {
+		method string
+		token  string
+		want   int
+	}{
+		{http.MethodGet, "", http.StatusOK},
+		{http.MethodPut, "", http.StatusForbidden},
+		{http.MethodPut, "wrong", http.StatusForbidden},
+		{http.MethodPut, "secret", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		path := "/tasks"
+		if test.method == http.MethodPut {
+			path = "/tasks/activate"
+		}
+		req := httptest.NewRequest(test.method, path, nil)
+		if test.token != "" {
+			req.Header.Set(csrfHeaderName, test.token)
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+
+		if res.Code != test.want {
+			t.Errorf("%s with token <%s>: status was incorrect, got: %d, want: %d.", test.method, test.token, res.Code, test.want)
+		}
+	}
+}
+
+func TestCSRFMiddleware_ProxyUserOrigin(t *testing.T) {
+	router := newCSRFTestRouter(nil)
+
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{"", http.StatusForbidden},
+		{"https://evil.example", http.StatusForbidden},
+		{"http://scheduler.example", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPut, "http://scheduler.example/tasks/activate", nil)
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+
+		if res.Code != test.want {
+			t.Errorf("Origin <%s>: status was incorrect, got: %d, want: %d.", test.origin, res.Code, test.want)
+		}
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		token *models.ApiToken
+		want  int
+	}{
+		{nil, http.StatusOK},
+		{&models.ApiToken{Scopes: []models.TokenScope{models.ScopeRead}}, http.StatusForbidden},
+		{&models.ApiToken{Scopes: []models.TokenScope{models.ScopeTrigger}}, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			if test.token != nil {
+				c.Set(tokenContextKey, test.token)
+			}
+		}, CSRFMiddleware())
+		router.PUT("/tasks/activate", RequireScope(models.ScopeTrigger), func(c *gin.Context) { c.String(http.StatusOK, "") })
+
+		req := httptest.NewRequest(http.MethodPut, "/tasks/activate", nil)
+		if test.token == nil {
+			req.Header.Set("Sec-Fetch-Site", "same-origin")
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+
+		if res.Code != test.want {
+			t.Errorf("Token %v: status was incorrect, got: %d, want: %d.", test.token, res.Code, test.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"scheduler/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// entityTagContextKey holds the entity tag a handler derived from the state its page is rendered from
+const entityTagContextKey = "entityTag"
+
+// serverStarted versions the entity tags, a new build may render the same state differently
+var serverStarted = time.Now()
+
+// setEntityTag makes the page cacheable by ConditionalGetMiddleware. The tag is derived from the tasks and the
+// other state the page is rendered from, not from the page itself. The remaining times of running tasks are a
+// snapshot on the page, a cached page shows them at most a minute old.
+func setEntityTag(c *gin.Context, tasks []*models.Task, state ...any) {
+	var minute time.Time
+	for _, task := range tasks {
+		if task.IsActive() {
+			minute = time.Now().Truncate(time.Minute)
+			break
+		}
+	}
+
+	taskTags := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		taskTags = append(taskTags, models.ETag(task))
+	}
+	c.Set(entityTagContextKey, models.ETag([]any{serverStarted, minute, taskTags, state}))
+}
+
+// bufferedWriter holds back the response body, so the handler's response can be replaced by a 304
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// WriteHeaderNow is deferred until the body is complete
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+// ConditionalGetMiddleware makes side effect free pages cacheable by the browser if their handler sets an entity tag
+// with setEntityTag. Browsers have to revalidate every time, a matching If-None-Match is answered with 304 Not Modified.
+func ConditionalGetMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		tag := c.GetString(entityTagContextKey)
+		if writer.Status() != http.StatusOK || tag == "" {
+			c.Writer.WriteHeader(writer.Status())
+			_, _ = c.Writer.Write(writer.body.Bytes())
+			return
+		}
+
+		// weak, the same state renders to an equivalent but not byte-identical page
+		etag := "W/" + tag
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "private, no-cache")
+
+		if c.GetHeader("If-None-Match") == etag {
+			c.Writer.WriteHeader(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return
+		}
+		_, _ = c.Writer.Write(writer.body.Bytes())
+	}
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"scheduler/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConditionalGetMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tasks := []*models.Task{{Id: "tea", Name: "Tea", Schedule: "in 5min", Trigger: models.Popup}}
+	router := gin.New()
+	router.GET("/tasks", ConditionalGetMiddleware(), func(c *gin.Context) {
+		setEntityTag(c, tasks, "ann")
+		// the rendered page changes on every request, its tag only with the tasks
+		c.String(http.StatusOK, "<p>%s at %s</p>", tasks[0].Name, time.Now())
+	})
+	router.GET("/untagged", ConditionalGetMiddleware(), func(c *gin.Context) { c.String(http.StatusOK, "untagged") })
+	router.GET("/missing", ConditionalGetMiddleware(), func(c *gin.Context) {
+		setEntityTag(c, tasks, "ann")
+		c.String(http.StatusNotFound, "missing")
+	})
+
+	get := func(path string, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	res := get("/tasks", "")
+	etag := res.Header().Get("ETag")
+	if res.Code != http.StatusOK || res.Body.Len() == 0 || etag == "" {
+		t.Fatalf("First response was incorrect, got: %v %q %q, want: %v with an ETag.", res.Code, res.Body.String(), etag, http.StatusOK)
+	}
+
+	res = get("/tasks", etag)
+	if res.Code != http.StatusNotModified || res.Body.Len() != 0 {
+		t.Errorf("Revalidated response was incorrect, got: %v %q, want: %v without body.", res.Code, res.Body.String(), http.StatusNotModified)
+	}
+
+	tasks = []*models.Task{{Id: "tea", Name: "Coffee", Schedule: "in 5min", Trigger: models.Popup}}
+	res = get("/tasks", etag)
+	if res.Code != http.StatusOK || res.Header().Get("ETag") == etag {
+		t.Errorf("Response for changed tasks was incorrect, got: %v %q, want: %v with a new ETag.", res.Code, res.Header().Get("ETag"), http.StatusOK)
+	}
+
+	res = get("/untagged", "")
+	if res.Code != http.StatusOK || res.Body.String() != "untagged" || res.Header().Get("ETag") != "" {
+		t.Errorf("Untagged response was incorrect, got: %v %q %q, want: %v %q without ETag.", res.Code, res.Body.String(), res.Header().Get("ETag"), http.StatusOK, "untagged")
+	}
+
+	res = get("/missing", "")
+	if res.Code != http.StatusNotFound || res.Body.String() != "missing" || res.Header().Get("ETag") != "" {
+		t.Errorf("Error response was incorrect, got: %v %q %q, want: %v %q without ETag.", res.Code, res.Body.String(), res.Header().Get("ETag"), http.StatusNotFound, "missing")
+	}
+}
+
+func TestSetEntityTag_RunningTask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	activatedTime := time.Now()
+	running := []*models.Task{{Id: "tea", Name: "Tea", Schedule: "in 5min", Trigger: models.Popup, ActivatedTime: &activatedTime}}
+
+	tag := func() string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		setEntityTag(c, running, "ann")
+		return c.GetString(entityTagContextKey)
+	}
+
+	// the remaining time ticks every second, the tag stays within the minute
+	first := tag()
+	time.Sleep(10 * time.Millisecond)
+	second := tag()
+	if first != second && time.Now().Truncate(time.Minute).Equal(activatedTime.Truncate(time.Minute)) {
+		t.Errorf("Tag of a running task was incorrect, got: %v and %v, want: the same tag within a minute.", first, second)
+	}
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"scheduler/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// configWatcher polls the file of declared schedules, see WatchConfig
+type configWatcher struct {
+	path string
+	// hash is the content of the last applied file, an unchanged file isn't reconciled again
+	hash string
+}
+
+// WatchConfig loads the schedules declared in the file and reloads them whenever the file changes.
+// Edits made in the app to declared schedules last until the next change of the file.
+// The first load has to succeed, later invalid versions of the file are logged and skipped.
+func (tc *TaskController) WatchConfig(path string, interval time.Duration) error {
+	tc.config = &configWatcher{path: path}
+	if err := tc.reloadConfig(); err != nil {
+		return err
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			if err := tc.reloadConfig(); err != nil {
+				log.Error().Err(err).Str("path", path).Msg("Could not reload the schedule config")
+			}
+		}
+	}()
+	return nil
+}
+
+func (tc *TaskController) reloadConfig() error {
+	data, err := os.ReadFile(tc.config.path)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if hash == tc.config.hash {
+		return nil
+	}
+
+	config, err := models.DecodeScheduleConfig(data, models.ExportFormat(tc.config.path))
+	if err != nil {
+		// retrying the same content won't help, wait for the next change
+		tc.config.hash = hash
+		return err
+	}
+	if err := tc.reconcileConfig(config); err != nil {
+		return err
+	}
+
+	tc.config.hash = hash
+	log.Info().Str("path", tc.config.path).Int("schedules", len(config.Schedules)).Msg("Loaded the schedule config")
+	return nil
+}
+
+// reconcileConfig replaces every declared schedule with its declaration. Schedules with invalid tasks are skipped
+// and reported, so one broken schedule doesn't hold back the others.
+func (tc *TaskController) reconcileConfig(config *models.ScheduleConfig) error {
+	for _, schedule := range config.Schedules {
+		// a declared schedule is the one of a team if there is a team with the owner as id
+		team, err := tc.teamDBM.GetTeam(schedule.Owner)
+		if errors.Is(err, models.ErrNotFound) {
+			team = nil
+		} else if err != nil {
+			return fmt.Errorf("schedule <%s>: %w", schedule.Owner, err)
+		}
+
+		report, err := tc.importSchedule(schedule.Owner, team, configActor, schedule.Export(), models.ImportReplace, false)
+		if err != nil {
+			return fmt.Errorf("schedule <%s>: %w", schedule.Owner, err)
+		}
+
+		for _, issue := range report.Errors {
+			log.Error().Str("author", schedule.Owner).Str("task", issue.Id).Msgf("Declared schedule was not applied: %s", issue.Message)
+		}
+		if !report.Applied || !schedule.Activate {
+			continue
+		}
+		for _, id := range append(append([]string{}, report.Created...), report.Updated...) {
+			if _, err := tc.changeTask(schedule.Owner, configActor, id, "", models.AuditActivate, activateTask); err != nil {
+				log.Error().Err(err).Str("author", schedule.Owner).Str("task", id).Msg("Could not activate declared task")
+			}
+		}
+	}
+	return nil
+}
+
+// ApiConfigDiff shows what loading the config would change in the schedule, without changing it.
+// The body is a config to try, without a body the watched file is compared.
+func (tc *TaskController) ApiConfigDiff(c *gin.Context) {
+	access, ok := tc.apiAuthorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	if err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	format := models.ExportFormat(c.ContentType())
+	if len(data) == 0 {
+		if tc.config == nil {
+			apiError(c, http.StatusNotFound, "not_found", "no config file is loaded, send one in the body")
+			return
+		}
+		if data, err = os.ReadFile(tc.config.path); err != nil {
+			apiFail(c, err)
+			return
+		}
+		format = models.ExportFormat(tc.config.path)
+	}
+
+	config, err := models.DecodeScheduleConfig(data, format)
+	if err != nil {
+		apiError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	schedule := config.Find(access.Owner)
+	if schedule == nil {
+		apiError(c, http.StatusNotFound, "not_found", "the config doesn't declare this schedule")
+		return
+	}
+
+	report, err := tc.importSchedule(access.Owner, access.Team, requestActor(c), schedule.Export(), models.ImportReplace, true)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, &models.ScheduleDiff{Owner: access.Owner, Report: report})
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+	"scheduler/models"
+	"testing"
+	"time"
+)
+
+func TestWatchConfig(t *testing.T) {
+	store := models.NewMemoryStore()
+	tc := NewTaskController(NewStreamController(), nil, store, store, store)
+
+	// the undeclared schedule has to stay untouched
+	if _, err := store.InsertTask("other", &models.Task{Id: "o1", Name: "Other", Schedule: "in 5min", Trigger: models.Popup}); err != nil {
+		t.Fatalf("Inserting a task failed: %v", err)
+	}
+	if _, err := store.InsertTask("team", &models.Task{Id: "manual", Name: "Manual", Schedule: "in 5min", Trigger: models.Popup}); err != nil {
+		t.Fatalf("Inserting a task failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "schedules.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Writing the config failed: %v", err)
+		}
+	}
+	write(`
+schedules:
+  - owner: team
+    activate: true
+    tasks:
+      - id: standup
+        name: Standup
+        schedule: at 09:30
+      - id: water
+        name: Water
+        schedule: every 1h
+`)
+	if err := tc.WatchConfig(path, time.Hour); err != nil {
+		t.Fatalf("Loading the config failed: %v", err)
+	}
+	defer func() {
+		for _, id := range []string{"standup", "water", "lunch"} {
+			tc.UnregisterTask("team", &models.Task{Id: id})
+		}
+	}()
+
+	team, _ := tc.readSchedulerData("team")
+	if len(team.Tasks) != 2 || team.FindTask("manual") != nil || !team.FindTask("water").IsActive() {
+		t.Errorf("Declared schedule was incorrect, got: %+v, want: the two active declared tasks.", team.Tasks)
+	}
+	if other, _ := tc.readSchedulerData("other"); len(other.Tasks) != 1 {
+		t.Errorf("Undeclared schedule was changed, got: %+v", other.Tasks)
+	}
+
+	// YAML files may be written as JSON as well
+	write(`{"schedules":[{"owner":"team","tasks":[{"id":"water","name":"Water","schedule":"every 1h"},{"id":"lunch","name":"Lunch","schedule":"at 12:00"}]}]}`)
+	if err := tc.reloadConfig(); err != nil {
+		t.Fatalf("Reloading the config failed: %v", err)
+	}
+	team, _ = tc.readSchedulerData("team")
+	if len(team.Tasks) != 2 || team.FindTask("standup") != nil || team.FindTask("lunch") == nil {
+		t.Errorf("Reloaded schedule was incorrect, got: %+v, want: water and lunch.", team.Tasks)
+	}
+	if !team.FindTask("water").IsActive() {
+		t.Errorf("Unchanged task lost its timer")
+	}
+
+	write(`schedules: [{owner: team, tasks: [{name: Nameless}]}]`)
+	if err := tc.reloadConfig(); err == nil {
+		t.Errorf("Invalid config was accepted")
+	}
+	if team, _ := tc.readSchedulerData("team"); len(team.Tasks) != 2 {
+		t.Errorf("Invalid config changed the schedule, got: %+v", team.Tasks)
+	}
+}
@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"scheduler/models"
+	"scheduler/rpc"
+)
+
+// grpcScopes are the token scopes of the RPCs, matching the scopes of the corresponding API routes
+var grpcScopes = map[string]models.TokenScope{
+	rpc.Scheduler_ListTasks_FullMethodName:      models.ScopeRead,
+	rpc.Scheduler_GetTask_FullMethodName:        models.ScopeRead,
+	rpc.Scheduler_CreateTask_FullMethodName:     models.ScopeManage,
+	rpc.Scheduler_UpdateTask_FullMethodName:     models.ScopeManage,
+	rpc.Scheduler_DeleteTask_FullMethodName:     models.ScopeManage,
+	rpc.Scheduler_ActivateTask_FullMethodName:   models.ScopeTrigger,
+	rpc.Scheduler_DeactivateTask_FullMethodName: models.ScopeTrigger,
+	rpc.Scheduler_PauseTask_FullMethodName:      models.ScopeTrigger,
+	rpc.Scheduler_ResumeTask_FullMethodName:     models.ScopeTrigger,
+	rpc.Scheduler_SnoozeTask_FullMethodName:     models.ScopeTrigger,
+	rpc.Scheduler_TaskDone_FullMethodName:       models.ScopeTrigger,
+	rpc.Scheduler_WatchAlerts_FullMethodName:    models.ScopeRead,
+}
+
+type grpcUserKey struct{}
+
+type grpcTokenKey struct{}
+
+// GrpcServer implements the gRPC service on top of the task operations of the JSON API
+type GrpcServer struct {
+	rpc.UnimplementedSchedulerServer
+	tc *TaskController
+	ac *AuthController
+	sc *StreamController
+}
+
+// NewGrpcServer creates the gRPC server, calls are authenticated with personal API tokens
+func NewGrpcServer(tc *TaskController, ac *AuthController, sc *StreamController) *grpc.Server {
+	gs := &GrpcServer{tc: tc, ac: ac, sc: sc}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := gs.authenticate(ctx, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := gs.authenticate(stream.Context(), info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+		}),
+	)
+	rpc.RegisterSchedulerServer(server, gs)
+	return server
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate resolves the bearer token of the call metadata and checks its scope for the method
+func (gs *GrpcServer) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	authorization := md.Get("authorization")
+	if len(authorization) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	plainToken, ok := bearerToken(authorization[0])
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	user, token, err := gs.ac.authenticateToken(plainToken)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	scope, exists := grpcScopes[method]
+	if !exists || !token.HasScope(scope) {
+		log.Warn().Str("method", method).Str("scope", string(scope)).Msg("Rejected API token without scope")
+		return nil, status.Error(codes.PermissionDenied, "token is missing the scope")
+	}
+
+	ctx = context.WithValue(ctx, grpcTokenKey{}, token)
+	return context.WithValue(ctx, grpcUserKey{}, user), nil
+}
+
+func grpcUser(ctx context.Context) *models.User {
+	return ctx.Value(grpcUserKey{}).(*models.User)
+}
+
+// grpcActor is the user of the call for the audit log, named by the token it authenticated with
+func grpcActor(ctx context.Context) *models.AuditActor {
+	user := grpcUser(ctx)
+	actor := &models.AuditActor{UserId: user.Id, Name: user.Name, Source: models.AuditSourceGrpc}
+	if token, ok := ctx.Value(grpcTokenKey{}).(*models.ApiToken); ok {
+		actor.Client = "token " + token.Name
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		actor.Ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(actor.Ip); err == nil {
+			actor.Ip = host
+		}
+	}
+	return actor
+}
+
+// grpcError maps the errors of the schedule and task operations to status codes, internal errors are not revealed
+func grpcError(err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, errInvalidRequest):
+		code = codes.InvalidArgument
+	case errors.Is(err, errTaskNotFound), errors.Is(err, errScheduleNotFound):
+		code = codes.NotFound
+	case errors.Is(err, errScheduleForbidden):
+		code = codes.PermissionDenied
+	case errors.Is(err, errTaskModified):
+		code = codes.Aborted
+	case errors.Is(err, errTaskState):
+		code = codes.FailedPrecondition
+	default:
+		log.Error().Err(err).Msg("gRPC call failed")
+		return status.Error(codes.Internal, "something went wrong, try again later")
+	}
+	return status.Error(code, err.Error())
+}
+
+func (gs *GrpcServer) schedule(ctx context.Context, scheduleId string, role models.TeamRole) (*ScheduleAccess, error) {
+	access, err := gs.tc.resolveSchedule(grpcUser(ctx), scheduleId, role)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return access, nil
+}
+
+func (gs *GrpcServer) ListTasks(ctx context.Context, req *rpc.ListTasksRequest) (*rpc.ListTasksResponse, error) {
+	access, err := gs.schedule(ctx, req.Schedule, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &models.TaskFilter{
+		Tag:      req.Tag,
+		Trigger:  models.TaskTrigger(req.Trigger),
+		State:    req.State,
+		Priority: req.Priority,
+		Sort:     req.Sort,
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	pageParams := models.PageParams{Offset: int(req.Offset), Limit: int(req.Limit)}
+	pageParams.Normalize()
+
+	tasks, err := gs.tc.getTasks(access.Owner)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	page := models.Paginate(models.FilterTasks(tasks, filter), pageParams)
+	response := &rpc.ListTasksResponse{
+		Offset: int32(page.Pagination.Offset),
+		Limit:  int32(page.Pagination.Limit),
+		Total:  page.Pagination.Total,
+	}
+	for _, task := range page.Data {
+		response.Tasks = append(response.Tasks, toRpcTask(task))
+	}
+	return response, nil
+}
+
+func (gs *GrpcServer) GetTask(ctx context.Context, req *rpc.GetTaskRequest) (*rpc.Task, error) {
+	access, err := gs.schedule(ctx, req.Schedule, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return toRpcResult(gs.tc.findTask(access.Owner, req.Id))
+}
+
+func (gs *GrpcServer) CreateTask(ctx context.Context, req *rpc.CreateTaskRequest) (*rpc.Task, error) {
+	access, err := gs.schedule(ctx, req.Schedule, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	return toRpcResult(gs.tc.createTask(access, grpcActor(ctx), toTaskRequest(req.Task)))
+}
+
+func (gs *GrpcServer) UpdateTask(ctx context.Context, req *rpc.UpdateTaskRequest) (*rpc.Task, error) {
+	access, err := gs.schedule(ctx, req.Schedule, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	return toRpcResult(gs.tc.replaceTask(access, grpcActor(ctx), req.Id, req.Etag, toTaskRequest(req.Task)))
+}
+
+func (gs *GrpcServer) DeleteTask(ctx context.Context, req *rpc.DeleteTaskRequest) (*rpc.DeleteTaskResponse, error) {
+	access, err := gs.schedule(ctx, req.Schedule, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if err := gs.tc.deleteTask(access.Owner, grpcActor(ctx), req.Id, req.Etag); err != nil {
+		return nil, grpcError(err)
+	}
+	return &rpc.DeleteTaskResponse{}, nil
+}
+
+func (gs *GrpcServer) ActivateTask(ctx context.Context, req *rpc.TaskActionRequest) (*rpc.Task, error) {
+	return gs.changeTask(ctx, req, models.AuditActivate, activateTask)
+}
+
+func (gs *GrpcServer) DeactivateTask(ctx context.Context, req *rpc.TaskActionRequest) (*rpc.Task, error) {
+	return gs.changeTask(ctx, req, models.AuditDeactivate, deactivateTask)
+}
+
+func (gs *GrpcServer) PauseTask(ctx context.Context, req *rpc.TaskActionRequest) (*rpc.Task, error) {
+	return gs.changeTask(ctx, req, models.AuditPause, pauseTask)
+}
+
+func (gs *GrpcServer) ResumeTask(ctx context.Context, req *rpc.TaskActionRequest) (*rpc.Task, error) {
+	return gs.changeTask(ctx, req, models.AuditResume, resumeTask)
+}
+
+func (gs *GrpcServer) SnoozeTask(ctx context.Context, req *rpc.SnoozeTaskRequest) (*rpc.Task, error) {
+	duration, err := parseSnooze(req.Duration)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	access, err := gs.schedule(ctx, req.Schedule, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	return toRpcResult(gs.tc.snoozeTask(access.Owner, grpcActor(ctx), req.Id, req.Etag, duration))
+}
+
+func (gs *GrpcServer) TaskDone(ctx context.Context, req *rpc.TaskDoneRequest) (*rpc.TaskDoneResponse, error) {
+	access, err := gs.schedule(ctx, req.Schedule, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if err := gs.tc.markTaskDone(access.Owner, grpcActor(ctx), req.Id); err != nil {
+		return nil, grpcError(err)
+	}
+	return &rpc.TaskDoneResponse{}, nil
+}
+
+// WatchAlerts registers a stream client like an SSE connection and forwards its events until the call ends
+func (gs *GrpcServer) WatchAlerts(_ *rpc.WatchAlertsRequest, stream rpc.Scheduler_WatchAlertsServer) error {
+	user := grpcUser(stream.Context())
+	owners, err := gs.tc.scheduleOwners(user.Id)
+	if err != nil {
+		log.Error().Err(err).Str("user", user.Name).Msg("Could not read teams")
+	}
+
+	clientChan, unsubscribe := gs.sc.Subscribe(user.Id, owners)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-clientChan:
+			if !ok {
+				return nil
+			}
+			alertEvent := toAlertEvent(event)
+			if alertEvent == nil {
+				continue
+			}
+			if err := stream.Send(alertEvent); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (gs *GrpcServer) changeTask(ctx context.Context, req *rpc.TaskActionRequest, action models.AuditAction, change taskChange) (*rpc.Task, error) {
+	access, err := gs.schedule(ctx, req.Schedule, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	return toRpcResult(gs.tc.changeTask(access.Owner, grpcActor(ctx), req.Id, req.Etag, action, change))
+}
+
+func toRpcResult(task *models.Task, err error) (*rpc.Task, error) {
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toRpcTask(task), nil
+}
+
+func toRpcTask(task *models.Task) *rpc.Task {
+	resource := models.NewTaskResource(task)
+	rpcTask := &rpc.Task{
+		Id:       task.Id,
+		Name:     task.Name,
+		Schedule: task.Schedule,
+		Trigger:  string(task.Trigger),
+		Tags:     task.Tags,
+		Priority: int32(task.Priority),
+		Active:   resource.Active,
+		Paused:   resource.Paused,
+		Etag:     models.ETag(task),
+	}
+	if task.ActivatedTime != nil {
+		rpcTask.ActivatedTime = task.ActivatedTime.Unix()
+	}
+	if resource.RemainingSeconds != nil {
+		rpcTask.RemainingSeconds = *resource.RemainingSeconds
+	}
+	if task.DependsOn != nil {
+		rpcTask.DependsOn = &rpc.TaskDependency{
+			TaskId: task.DependsOn.TaskId,
+			Event:  string(task.DependsOn.Event),
+			Delay:  task.DependsOn.Delay,
+		}
+	}
+	for _, assignee := range task.Assignees {
+		rpcTask.Assignees = append(rpcTask.Assignees, &rpc.TaskAssignee{UserId: assignee.UserId, Name: assignee.Name})
+	}
+	return rpcTask
+}
+
+func toTaskRequest(input *rpc.TaskInput) *models.TaskRequest {
+	if input == nil {
+		return &models.TaskRequest{}
+	}
+	request := &models.TaskRequest{
+		Name:      input.Name,
+		Schedule:  input.Schedule,
+		Trigger:   models.TaskTrigger(input.Trigger),
+		Tags:      input.Tags,
+		Priority:  models.TaskPriority(input.Priority),
+		Assignees: input.Assignees,
+	}
+	if input.DependsOn != nil {
+		request.DependsOn = &models.TaskDependency{
+			TaskId: input.DependsOn.TaskId,
+			Event:  models.DependencyEvent(input.DependsOn.Event),
+			Delay:  input.DependsOn.Delay,
+		}
+	}
+	return request
+}
+
+// toAlertEvent converts a stream event, nil for events without a counterpart
+func toAlertEvent(event *Event) *rpc.AlertEvent {
+	switch event.Type {
+	case EVENT_TASK_ALERT:
+		task, isTask := event.Message.(*models.Task)
+		if !isTask {
+			log.Error().Msg("Event and message type dont match")
+			return nil
+		}
+		return &rpc.AlertEvent{Type: rpc.AlertEvent_TASK_ALERT, Schedule: event.Owner, Task: toRpcTask(task)}
+	case EVENT_TASKS_UPDATE:
+		return &rpc.AlertEvent{Type: rpc.AlertEvent_TASKS_UPDATE, Schedule: event.Owner}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"scheduler/models"
+	"scheduler/rpc"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGrpcError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{fmt.Errorf("%w: name is required", errInvalidRequest), codes.InvalidArgument},
+		{errTaskNotFound, codes.NotFound},
+		{errScheduleNotFound, codes.NotFound},
+		{errScheduleForbidden, codes.PermissionDenied},
+		{errTaskModified, codes.Aborted},
+		{fmt.Errorf("%w: only active tasks can be paused", errTaskState), codes.FailedPrecondition},
+		{errors.New("connection lost"), codes.Internal},
+	}
+
+	for _, test := range tests {
+		if got := status.Code(grpcError(test.err)); got != test.want {
+			t.Errorf("grpcError(%v) was incorrect, got: %v, want: %v.", test.err, got, test.want)
+		}
+	}
+}
+
+func TestGrpcScopes(t *testing.T) {
+	for _, method := range rpc.Scheduler_ServiceDesc.Methods {
+		if _, exists := grpcScopes["/"+rpc.Scheduler_ServiceDesc.ServiceName+"/"+method.MethodName]; !exists {
+			t.Errorf("Scope of %s is missing", method.MethodName)
+		}
+	}
+	for _, stream := range rpc.Scheduler_ServiceDesc.Streams {
+		if _, exists := grpcScopes["/"+rpc.Scheduler_ServiceDesc.ServiceName+"/"+stream.StreamName]; !exists {
+			t.Errorf("Scope of %s is missing", stream.StreamName)
+		}
+	}
+}
+
+func TestToTaskRequest(t *testing.T) {
+	task := &models.Task{
+		Id:        "1",
+		Name:      "Tea",
+		Schedule:  "in 5min",
+		Trigger:   models.Audio,
+		Tags:      []string{"kitchen"},
+		Priority:  models.PriorityHigh,
+		DependsOn: &models.TaskDependency{TaskId: "2", Event: models.DependencyDone, Delay: "1min"},
+	}
+	rpcTask := toRpcTask(task)
+
+	request := toTaskRequest(&rpc.TaskInput{
+		Name:      rpcTask.Name,
+		Schedule:  rpcTask.Schedule,
+		Trigger:   rpcTask.Trigger,
+		Tags:      rpcTask.Tags,
+		Priority:  rpcTask.Priority,
+		DependsOn: rpcTask.DependsOn,
+	})
+	copied := &models.Task{Id: task.Id, DependsOn: request.DependsOn}
+	request.Apply(copied)
+
+	if models.ETag(copied) != rpcTask.Etag {
+		t.Errorf("toTaskRequest was incorrect, got: %+v, want: %+v.", copied, task)
+	}
+}
+
+func TestToAlertEvent(t *testing.T) {
+	task := &models.Task{Id: "1", Name: "Tea", Schedule: "in 5min", Trigger: models.Popup}
+
+	alert := toAlertEvent(&Event{Message: task, Type: EVENT_TASK_ALERT, Owner: "team"})
+	if alert.Type != rpc.AlertEvent_TASK_ALERT || alert.Schedule != "team" || alert.Task.Id != task.Id {
+		t.Errorf("toAlertEvent(alert) was incorrect, got: %v.", alert)
+	}
+
+	update := toAlertEvent(&Event{Type: EVENT_TASKS_UPDATE, Owner: "user"})
+	if update.Type != rpc.AlertEvent_TASKS_UPDATE || update.Schedule != "user" {
+		t.Errorf("toAlertEvent(update) was incorrect, got: %v.", update)
+	}
+
+	if message := toAlertEvent(&Event{Message: "hello", Type: 0}); message != nil {
+		t.Errorf("toAlertEvent(message) was incorrect, got: %v, want: nil.", message)
+	}
+}
+
+func TestGrpcServer_RequiresToken(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewGrpcServer(&TaskController{}, &AuthController{}, NewStreamController())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Dialing the server failed: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = rpc.NewSchedulerClient(conn).ListTasks(context.Background(), &rpc.ListTasksRequest{})
+	if got := status.Code(err); got != codes.Unauthenticated {
+		t.Errorf("ListTasks without token was incorrect, got: %v, want: %v.", got, codes.Unauthenticated)
+	}
+}
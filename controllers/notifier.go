@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"scheduler/models"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errWebhookAddress keeps users from making the server post to itself or its internal network
+var errWebhookAddress = errors.New("webhook must not point to a loopback, private, link-local or other non-public address")
+
+// nonPublicPrefixes are the address ranges webhooks must not reach: local, private, shared and reserved networks,
+// benchmarking, multicast and the IPv6 ranges that embed them
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// IPv4-compatible addresses, together with the unspecified and the loopback address
+	netip.MustParsePrefix("::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// nat64Prefix embeds IPv4 addresses in its last 32 bits, they are checked like IPv4 addresses
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// Notifier delivers a task alert to a user outside of the browser, e.g. while no page is open
+type Notifier interface {
+	Notify(user *models.User, alert *AlertNotification) error
+}
+
+// AlertNotification is the payload sent to personal notifiers
+type AlertNotification struct {
+	TaskId    string                 `json:"taskId"`
+	TaskName  string                 `json:"taskName"`
+	Schedule  string                 `json:"schedule"`
+	Trigger   models.TaskTrigger     `json:"trigger"`
+	Owner     string                 `json:"owner"`
+	Assignees []*models.TaskAssignee `json:"assignees,omitempty"`
+	FiredAt   time.Time              `json:"firedAt"`
+}
+
+func NewAlertNotification(owner string, task *models.Task) *AlertNotification {
+	return &AlertNotification{
+		TaskId:    task.Id,
+		TaskName:  task.Name,
+		Schedule:  task.Schedule,
+		Trigger:   task.Trigger,
+		Owner:     owner,
+		Assignees: task.Assignees,
+		FiredAt:   time.Now(),
+	}
+}
+
+// WebhookNotifier posts the alert as JSON to the personal webhook of the user, users without one are skipped
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+// NewWebhookNotifier posts directly, without the proxy of the environment, and only to public addresses.
+// The address is checked when connecting, so a host name that resolves differently later can't bypass it.
+func NewWebhookNotifier() *WebhookNotifier {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: webhookDialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &WebhookNotifier{client: &http.Client{Timeout: 10 * time.Second, Transport: transport}}
+}
+
+func webhookDialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if addr, err := netip.ParseAddr(host); err != nil || !isPublicAddress(addr) {
+		return fmt.Errorf("%w: %s", errWebhookAddress, host)
+	}
+	return nil
+}
+
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.WithZone("").Unmap()
+	if nat64Prefix.Contains(addr) {
+		embedded := addr.As16()
+		addr = netip.AddrFrom4([4]byte(embedded[12:]))
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (wn *WebhookNotifier) Notify(user *models.User, alert *AlertNotification) error {
+	if user.WebhookUrl == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	res, err := wn.client.Post(user.WebhookUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// ValidateWebhookUrl accepts absolute http and https URLs. Internal hosts given as address or localhost are rejected
+// right away, host names are checked by the notifier once they are resolved.
+func ValidateWebhookUrl(webhookUrl string) error {
+	parsedUrl, err := url.Parse(webhookUrl)
+	if err != nil {
+		return err
+	}
+	if (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		return fmt.Errorf("webhook url must be an absolute http or https url")
+	}
+
+	host := strings.ToLower(parsedUrl.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errWebhookAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublicAddress(addr) {
+		return errWebhookAddress
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"scheduler/models"
+	"testing"
+)
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	var received AlertNotification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	task := &models.Task{Id: "a", Name: "Stand-up", Schedule: "in 15min", Trigger: models.Popup}
+	// the test server is local, which the notifier of the server refuses
+	notifier := &WebhookNotifier{client: server.Client()}
+
+	if err := notifier.Notify(&models.User{Name: "ann", WebhookUrl: server.URL}, NewAlertNotification("team", task)); err != nil {
+		t.Fatalf("notify failed: %s", err)
+	}
+	if received.TaskId != "a" || received.Owner != "team" {
+		t.Errorf("Notification was incorrect, got: %+v, want task a of team.", received)
+	}
+
+	if err := notifier.Notify(&models.User{Name: "bob"}, NewAlertNotification("team", task)); err != nil {
+		t.Errorf("User without webhook should be skipped, got: %s", err)
+	}
+}
+
+func TestWebhookNotifier_InternalAddress(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	task := &models.Task{Id: "a", Name: "Stand-up", Schedule: "in 15min", Trigger: models.Popup}
+	err := NewWebhookNotifier().Notify(&models.User{Name: "ann", WebhookUrl: server.URL}, NewAlertNotification("team", task))
+	if !errors.Is(err, errWebhookAddress) || called {
+		t.Errorf("Notifying a local webhook was incorrect, got: %v %v, want: %v.", err, called, errWebhookAddress)
+	}
+}
+
+func TestValidateWebhookUrl(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://example.com/hook", true},
+		{"http://203.0.113.7/hook", true},
+		{"http://localhost:8080", false},
+		{"http://127.0.0.1:8080", false},
+		{"http://[::1]/hook", false},
+		{"http://10.1.2.3/hook", false},
+		{"http://192.168.0.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://0.0.0.0:3000", false},
+		{"http://100.64.0.1/hook", false},
+		{"http://[::ffff:10.0.0.1]/hook", false},
+		{"http://[64:ff9b::a9fe:a9fe]/latest/meta-data", false},
+		{"ftp://example.com", false},
+		{"/relative", false},
+	}
+
+	for _, test := range tests {
+		if err := ValidateWebhookUrl(test.url); (err == nil) != test.valid {
+			t.Errorf("Validation of <%s> was incorrect, got: %v, want valid: %t.", test.url, err, test.valid)
+		}
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"203.0.113.7", true},
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"64:ff9b::808:808", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.0.1", false},
+		{"169.254.169.254", false},
+		{"0.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"192.0.0.170", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"224.0.0.1", false},
+		{"239.255.255.250", false},
+		{"255.255.255.255", false},
+		{"::", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:100.64.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"64:ff9b::7f00:1", false},
+		{"64:ff9b::c0a8:1", false},
+		{"64:ff9b:1::a00:1", false},
+		{"fd00::1", false},
+		{"fe80::1%eth0", false},
+		{"ff02::1", false},
+	}
+
+	for _, test := range tests {
+		if public := isPublicAddress(netip.MustParseAddr(test.addr)); public != test.public {
+			t.Errorf("isPublicAddress of <%s> was incorrect, got: %t, want: %t.", test.addr, public, test.public)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+	"net/http"
+	"os"
+	"scheduler/models"
+	"scheduler/utils"
+	"strings"
+	"time"
+)
+
+const (
+	oidcCookieName = "scheduler_oidc"
+	oidcCookiePath = "/login/oidc"
+	oidcFlowMaxAge = 10 * time.Minute
+)
+
+var ErrOIDCNonce = errors.New("id token nonce does not match")
+
+// OIDCConfig configures the login with an OpenID Connect provider, see OIDCConfigFromEnv
+type OIDCConfig struct {
+	// ProviderName is shown on the login button
+	ProviderName string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	Scopes       []string
+	// UsernameClaim is the claim the user name is taken from, falling back to email and subject
+	UsernameClaim string
+}
+
+// OIDCConfigFromEnv reads the OIDC_* environment variables, it returns nil if no issuer is configured
+func OIDCConfigFromEnv() *OIDCConfig {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+
+	config := &OIDCConfig{
+		ProviderName:  os.Getenv("OIDC_PROVIDER_NAME"),
+		Issuer:        issuer,
+		ClientId:      os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectUrl:   os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:        []string{oidc.ScopeOpenID, "profile", "email"},
+		UsernameClaim: os.Getenv("OIDC_USERNAME_CLAIM"),
+	}
+	if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+		config.Scopes = strings.Fields(scopes)
+	}
+	if config.ProviderName == "" {
+		config.ProviderName = "SSO"
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "preferred_username"
+	}
+
+	return config
+}
+
+// OIDCIdentity is the verified identity of an id token, mapped to a user on login
+type OIDCIdentity struct {
+	// Subject is unique per provider, it's prefixed with the issuer
+	Subject string
+	Name    string
+}
+
+// oidcFlow is kept in a short-lived cookie between the redirect to the provider and the callback
+type oidcFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// OIDCController implements the authorization code flow with PKCE against an OpenID Connect provider.
+// Endpoints are discovered from the issuer and id tokens are validated against the provider's JWKS.
+type OIDCController struct {
+	config       *OIDCConfig
+	oauth2Config *oauth2.Config
+	verifier     *oidc.IDTokenVerifier
+	userDBM      models.UserStore
+	ac           *AuthController
+}
+
+func NewOIDCController(ctx context.Context, config *OIDCConfig, userDBM models.UserStore, ac *AuthController) (*OIDCController, error) {
+	provider, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+
+	log.Info().Str("issuer", config.Issuer).Msg("Discovered OIDC provider")
+	ac.oidcProvider = config.ProviderName
+
+	return &OIDCController{
+		config: config,
+		oauth2Config: &oauth2.Config{
+			ClientID:     config.ClientId,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectUrl,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       config.Scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientId}),
+		userDBM:  userDBM,
+		ac:       ac,
+	}, nil
+}
+
+// Login redirects to the provider, state, nonce and PKCE verifier are remembered in a cookie for the callback
+func (oc *OIDCController) Login(c *gin.Context) {
+	flow := &oidcFlow{
+		State:    utils.RandomToken(),
+		Nonce:    utils.RandomToken(),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	flowJson, _ := json.Marshal(flow)
+	secure := c.Request.TLS != nil || os.Getenv("APP_ENV") == "production"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcCookieName, base64.RawURLEncoding.EncodeToString(flowJson), int(oidcFlowMaxAge.Seconds()), oidcCookiePath, "", secure, true)
+
+	c.Redirect(http.StatusFound, oc.authCodeURL(flow))
+}
+
+func (oc *OIDCController) authCodeURL(flow *oidcFlow) string {
+	return oc.oauth2Config.AuthCodeURL(flow.State, oidc.Nonce(flow.Nonce), oauth2.S256ChallengeOption(flow.Verifier))
+}
+
+// Callback finishes the flow, maps the verified identity to a user and starts a session
+func (oc *OIDCController) Callback(c *gin.Context) {
+	flow, err := readOIDCFlow(c)
+	c.SetCookie(oidcCookieName, "", -1, oidcCookiePath, "", false, true)
+	if err != nil || c.Query("state") != flow.State {
+		log.Warn().Err(err).Msg("Rejected OIDC callback with invalid state")
+		oc.renderLoginError(c, http.StatusBadRequest, "Login expired, please try again")
+		return
+	}
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		log.Warn().Str("error", providerErr).Str("description", c.Query("error_description")).Msg("OIDC provider denied login")
+		oc.renderLoginError(c, http.StatusUnauthorized, "Login was denied by "+oc.config.ProviderName)
+		return
+	}
+
+	identity, err := oc.identify(c.Request.Context(), c.Query("code"), flow)
+	if err != nil {
+		LogError(err, "Could not verify OIDC login", c)
+		oc.renderLoginError(c, http.StatusUnauthorized, "Could not verify login with "+oc.config.ProviderName)
+		return
+	}
+
+	claimLegacy := identity.Name == os.Getenv("LEGACY_SCHEDULE_OWNER")
+	user, err := models.FindOrCreateOIDCUser(oc.userDBM, identity.Subject, identity.Name, claimLegacy)
+	if err != nil {
+		if errors.Is(err, models.ErrUserExists) {
+			oc.renderLoginError(c, http.StatusConflict, "The name "+identity.Name+" already belongs to a local account")
+			return
+		}
+		LogError(err, "Could not map OIDC user", c)
+		oc.renderLoginError(c, http.StatusInternalServerError, "Could not log in, please try again")
+		return
+	}
+
+	if err := oc.ac.startSession(c, user); err != nil {
+		LogError(err, "Could not create session", c)
+		oc.renderLoginError(c, http.StatusInternalServerError, "Could not log in, please try again")
+		return
+	}
+
+	log.Info().Str("user", user.Name).Msg("Logged in with OIDC")
+	c.Redirect(http.StatusSeeOther, "/tasks")
+}
+
+// identify exchanges the code with the PKCE verifier and returns the identity of the validated id token
+func (oc *OIDCController) identify(ctx context.Context, code string, flow *oidcFlow) (*OIDCIdentity, error) {
+	token, err := oc.oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("code exchange failed: %w", err)
+	}
+
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response contains no id_token")
+	}
+
+	idToken, err := oc.verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != flow.Nonce {
+		return nil, ErrOIDCNonce
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	return &OIDCIdentity{
+		Subject: idToken.Issuer + "|" + idToken.Subject,
+		Name:    usernameFromClaims(claims, oc.config.UsernameClaim, idToken.Subject),
+	}, nil
+}
+
+// usernameFromClaims maps the configured claim to the user name, falling back to the email and the subject
+func usernameFromClaims(claims map[string]interface{}, usernameClaim string, subject string) string {
+	for _, claim := range []string{usernameClaim, "email"} {
+		if value, ok := claims[claim].(string); ok && strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return subject
+}
+
+func readOIDCFlow(c *gin.Context) (*oidcFlow, error) {
+	cookie, err := c.Cookie(oidcCookieName)
+	if err != nil {
+		return nil, err
+	}
+	flowJson, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err != nil {
+		return nil, err
+	}
+
+	var flow oidcFlow
+	if err := json.Unmarshal(flowJson, &flow); err != nil {
+		return nil, err
+	}
+	if flow.State == "" {
+		return nil, errors.New("oidc flow has no state")
+	}
+	return &flow, nil
+}
+
+func (oc *OIDCController) renderLoginError(c *gin.Context, status int, message string) {
+	c.HTML(status, "pages/login", oc.ac.loginPageData("", message))
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+const mockClientId = "scheduler"
+
+// mockOIDCProvider is a minimal OpenID Connect provider serving discovery, JWKS and a token endpoint checking PKCE
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	// signingKey signs the id tokens, it's the published key unless a test swaps it
+	signingKey *rsa.PrivateKey
+	// challenge and nonce are remembered from the authorization request
+	challenge string
+	nonce     string
+	claims    map[string]interface{}
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &mockOIDCProvider{key: key, signingKey: key, claims: map[string]interface{}{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := provider.server.URL
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                issuer,
+			"authorization_endpoint":                issuer + "/authorize",
+			"token_endpoint":                        issuer + "/token",
+			"jwks_uri":                              issuer + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"}}}
+		_ = json.NewEncoder(w).Encode(jwks)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		verifierHash := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if r.PostFormValue("code") != "code" || base64.RawURLEncoding.EncodeToString(verifierHash[:]) != provider.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     provider.idToken(t),
+		})
+	})
+	provider.server = httptest.NewServer(mux)
+	t.Cleanup(provider.server.Close)
+
+	return provider
+}
+
+func (p *mockOIDCProvider) idToken(t *testing.T) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: p.signingKey}, (&jose.SignerOptions{}).WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.Claims{
+		Issuer:   p.server.URL,
+		Subject:  "subject-1",
+		Audience: jwt.Audience{mockClientId},
+		IssuedAt: jwt.NewNumericDate(time.Now()),
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	token, err := jwt.Signed(signer).Claims(claims).Claims(map[string]interface{}{"nonce": p.nonce}).Claims(p.claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// authorize runs the login redirect and returns the flow the callback would read from the cookie
+func (p *mockOIDCProvider) authorize(t *testing.T, oc *OIDCController) *oidcFlow {
+	gin.SetMode(gin.TestMode)
+	res := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(res)
+	c.Request = httptest.NewRequest(http.MethodGet, "/login/oidc", nil)
+	oc.Login(c)
+
+	if res.Code != http.StatusFound {
+		t.Fatalf("login status was incorrect, got: %d, want: %d.", res.Code, http.StatusFound)
+	}
+	redirect, err := url.Parse(res.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redirect.Query().Get("code_challenge_method") != "S256" {
+		t.Errorf("code challenge method was incorrect, got: %s, want: S256.", redirect.Query().Get("code_challenge_method"))
+	}
+	p.challenge = redirect.Query().Get("code_challenge")
+	p.nonce = redirect.Query().Get("nonce")
+
+	req := &http.Request{Header: http.Header{"Cookie": res.Header().Values("Set-Cookie")}}
+	c.Request = req
+	flow, err := readOIDCFlow(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flow.State != redirect.Query().Get("state") {
+		t.Errorf("state was incorrect, got: %s, want: %s.", redirect.Query().Get("state"), flow.State)
+	}
+	return flow
+}
+
+func newTestOIDCController(t *testing.T, provider *mockOIDCProvider) *OIDCController {
+	config := &OIDCConfig{
+		ProviderName:  "Mock",
+		Issuer:        provider.server.URL,
+		ClientId:      mockClientId,
+		RedirectUrl:   "http://scheduler.example/login/oidc/callback",
+		Scopes:        []string{"openid", "profile"},
+		UsernameClaim: "preferred_username",
+	}
+	oc, err := NewOIDCController(context.Background(), config, nil, &AuthController{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return oc
+}
+
+func TestOIDCController_Identify(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.claims["preferred_username"] = "drdreo"
+	oc := newTestOIDCController(t, provider)
+
+	flow := provider.authorize(t, oc)
+	identity, err := oc.identify(context.Background(), "code", flow)
+	if err != nil {
+		t.Fatalf("identify failed: %s", err)
+	}
+
+	if identity.Subject != provider.server.URL+"|subject-1" {
+		t.Errorf("subject was incorrect, got: %s, want: %s.", identity.Subject, provider.server.URL+"|subject-1")
+	}
+	if identity.Name != "drdreo" {
+		t.Errorf("name was incorrect, got: %s, want: drdreo.", identity.Name)
+	}
+}
+
+func TestOIDCController_IdentifyRejects(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	oc := newTestOIDCController(t, provider)
+
+	flow := provider.authorize(t, oc)
+	wrongVerifier := *flow
+	wrongVerifier.Verifier = "not-the-verifier-not-the-verifier-not-the-verifier"
+	if _, err := oc.identify(context.Background(), "code", &wrongVerifier); err == nil {
+		t.Errorf("identify with wrong PKCE verifier should fail")
+	}
+
+	wrongNonce := *flow
+	wrongNonce.Nonce = "other"
+	if _, err := oc.identify(context.Background(), "code", &wrongNonce); !errors.Is(err, ErrOIDCNonce) {
+		t.Errorf("identify with wrong nonce was incorrect, got: %v, want: %v.", err, ErrOIDCNonce)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.signingKey = otherKey
+	if _, err := oc.identify(context.Background(), "code", flow); err == nil {
+		t.Errorf("identify with id token signed by unknown key should fail")
+	}
+}
+
+func TestUsernameFromClaims(t *testing.T) {
+	tests := []struct {
+		claims map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{"preferred_username": "drdreo", "email": "drdreo@example.com"}, "drdreo"},
+		{map[string]interface{}{"preferred_username": " ", "email": "drdreo@example.com"}, "drdreo@example.com"},
+		{map[string]interface{}{}, "subject-1"},
+	}
+
+	for _, test := range tests {
+		got := usernameFromClaims(test.claims, "preferred_username", "subject-1")
+		if got != test.want {
+			t.Errorf("Username was incorrect, got: %s, want: %s.", got, test.want)
+		}
+	}
+}
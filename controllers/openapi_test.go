@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"scheduler/models"
+	"scheduler/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/gin-gonic/gin"
+)
+
+const openApiPath = "../static/openapi.json"
+
+func loadOpenApi(t *testing.T) (*openapi3.T, routers.Router) {
+	t.Helper()
+	doc, err := openapi3.NewLoader().LoadFromFile(openApiPath)
+	if err != nil {
+		t.Fatalf("Loading %s failed: %v", openApiPath, err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("Validating %s failed: %v", openApiPath, err)
+	}
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("Creating the router of %s failed: %v", openApiPath, err)
+	}
+	return doc, router
+}
+
+func TestOpenApi_Routes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	doc, specRouter := loadOpenApi(t)
+
+	router := gin.New()
+	(&TaskController{}).RegisterApiRoutes(router, router, router)
+
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		path := strings.ReplaceAll(route.Path, ":id", "{id}")
+		registered[route.Method+" "+path] = true
+
+		req := httptest.NewRequest(route.Method, strings.ReplaceAll(route.Path, ":id", "1"), nil)
+		if _, _, err := specRouter.FindRoute(req); err != nil {
+			t.Errorf("Route %s %s is missing in the spec: %v", route.Method, route.Path, err)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item.Operations() {
+			if !registered[method+" /api/v1"+path] {
+				t.Errorf("Operation %s %s of the spec has no route", method, path)
+			}
+		}
+	}
+}
+
+// TestOpenApi_Resources checks the JSON shapes that don't need a store against the schemas
+func TestOpenApi_Resources(t *testing.T) {
+	_, specRouter := loadOpenApi(t)
+
+	activatedTime := time.Now()
+	task := &models.Task{
+		Id:            "1",
+		Name:          "Tea",
+		Schedule:      "in 5min",
+		ActivatedTime: &activatedTime,
+		Trigger:       models.Audio,
+		DependsOn:     &models.TaskDependency{TaskId: "2", Event: models.DependencyDone, Delay: "1min"},
+		Tags:          []string{"kitchen"},
+		Priority:      models.PriorityHigh,
+		Assignees:     []*models.TaskAssignee{{UserId: "u1", Name: "Ann"}},
+	}
+	taskBody, _ := json.Marshal(models.NewTaskResource(task))
+	errorBody, _ := json.Marshal(models.ApiErrorResponse{Error: &models.ApiError{Code: errorCode(http.StatusNotFound), Message: "task not found"}})
+
+	tests := []struct {
+		path   string
+		status int
+		body   []byte
+	}{
+		{"/api/v1/tasks/1", http.StatusOK, taskBody},
+		{"/api/v1/tasks/1", http.StatusNotFound, errorBody},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		validateResponse(t, specRouter, req, test.status, http.Header{"Content-Type": {"application/json"}}, test.body)
+	}
+}
+
+func validateResponse(t *testing.T, specRouter routers.Router, req *http.Request, status int, header http.Header, body []byte) {
+	t.Helper()
+	route, pathParams, err := specRouter.FindRoute(req)
+	if err != nil {
+		t.Errorf("%s %s is missing in the spec: %v", req.Method, req.URL.Path, err)
+		return
+	}
+
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{Request: req, PathParams: pathParams, Route: route},
+		Status:                 status,
+		Header:                 header,
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+	}
+	input.SetBodyBytes(body)
+	if err := openapi3filter.ValidateResponse(context.Background(), input); err != nil {
+		t.Errorf("Response %d of %s %s doesn't match the spec: %v", status, req.Method, req.URL.Path, err)
+	}
+}
+
+// openApiValidator checks every request and response against the spec, requests outside the spec have to be rejected
+func openApiValidator(t *testing.T, specRouter routers.Router) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, pathParams, err := specRouter.FindRoute(c.Request)
+		if err != nil {
+			t.Errorf("%s %s is missing in the spec: %v", c.Request.Method, c.Request.URL.Path, err)
+			return
+		}
+
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		validationReq := c.Request.Clone(c.Request.Context())
+		validationReq.Body = io.NopCloser(bytes.NewReader(body))
+		err = openapi3filter.ValidateRequest(context.Background(), &openapi3filter.RequestValidationInput{
+			Request:    validationReq,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+		})
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// invalid requests are part of the checks, they only have to be rejected
+		if err != nil && c.Writer.Status() < http.StatusBadRequest {
+			t.Errorf("Request %s %s doesn't match the spec but was accepted: %v", c.Request.Method, c.Request.URL.Path, err)
+		}
+
+		validateResponse(t, specRouter, c.Request, c.Writer.Status(), c.Writer.Header(), recorder.body.Bytes())
+	}
+}
+
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// TestOpenApi_Harness runs the API on a memory store and validates every exchange with the spec.
+func TestOpenApi_Harness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, specRouter := loadOpenApi(t)
+
+	user := &models.User{Id: "openapi-" + utils.Uuid(), Name: "openapi"}
+
+	store := models.NewMemoryStore()
+	tc := NewTaskController(NewStreamController(), nil, store, store, store)
+
+	router := gin.New()
+	api := router.Group("", func(c *gin.Context) { c.Set(userContextKey, user) }, openApiValidator(t, specRouter))
+	tc.RegisterApiRoutes(api, api, api)
+
+	do := func(method string, path string, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	res := do(http.MethodPost, "/api/v1/tasks", `{"name":"Tea","schedule":"in 10min","trigger":"audio","tags":["kitchen"]}`)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("Creating a task was incorrect, got: %v, want: %v.", res.Code, http.StatusCreated)
+	}
+	created := models.TaskResource{}
+	_ = json.Unmarshal(res.Body.Bytes(), &created)
+	taskPath := "/api/v1/tasks/" + created.Id
+	etag := res.Header().Get("ETag")
+
+	steps := []struct {
+		method string
+		path   string
+		body   string
+		header []string
+		want   int
+	}{
+		{http.MethodPost, "/api/v1/tasks", `{"name":"","schedule":"in 10min","trigger":"audio"}`, nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/tasks?state=inactive&limit=10", "", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/tasks?priority=urgent", "", nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/tasks", `{"name":"Tea","schedule":"in 10min","trigger":"audio","priority":7}`, nil, http.StatusBadRequest},
+		{http.MethodGet, taskPath, "", nil, http.StatusOK},
+		{http.MethodGet, taskPath, "", []string{"If-None-Match", etag}, http.StatusNotModified},
+		{http.MethodGet, "/api/v1/tasks/missing", "", nil, http.StatusNotFound},
+		{http.MethodPut, taskPath, `{"name":"Green tea","schedule":"in 5min","trigger":"popup"}`, []string{"If-Match", etag}, http.StatusOK},
+		{http.MethodPut, taskPath, `{"name":"Black tea","schedule":"in 5min","trigger":"popup"}`, []string{"If-Match", etag}, http.StatusPreconditionFailed},
+		{http.MethodPost, taskPath + "/resume", "", nil, http.StatusConflict},
+		{http.MethodPost, taskPath + "/activate", "", nil, http.StatusOK},
+		{http.MethodPost, taskPath + "/pause", "", nil, http.StatusOK},
+		{http.MethodPost, taskPath + "/resume", "", nil, http.StatusOK},
+		{http.MethodPost, taskPath + "/snooze", `{"duration":"10min"}`, nil, http.StatusOK},
+		{http.MethodPost, taskPath + "/snooze", `{"duration":"soon"}`, nil, http.StatusBadRequest},
+		{http.MethodPost, taskPath + "/done", "", nil, http.StatusNoContent},
+		{http.MethodGet, taskPath + "/history", "", nil, http.StatusOK},
+		{http.MethodPost, taskPath + "/deactivate", "", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/export?history=true", "", nil, http.StatusOK},
+		{http.MethodPost, "/api/v1/import?dryRun=true", `{"tasks":[{"id":"imported","name":"Water","schedule":"every 1h"}]}`, nil, http.StatusOK},
+		{http.MethodPost, "/api/v1/import", `{"tasks":[{"id":"imported","name":"Water","schedule":"every 1h"}]}`, nil, http.StatusOK},
+		{http.MethodPost, "/api/v1/import", `{"tasks":[{"id":"imported","name":"Juice","schedule":"every 1h"}]}`, nil, http.StatusConflict},
+		{http.MethodPost, "/api/v1/import", `{"tasks":[{"name":"Juice","schedule":"soon"}]}`, nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/import?mode=append", `{"tasks":[]}`, nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/config/diff", `{"schedules":[{"owner":"` + user.Id + `","tasks":[{"id":"imported","name":"Water","schedule":"every 2h"}]}]}`, nil, http.StatusOK},
+		{http.MethodPost, "/api/v1/config/diff", `{"schedules":[{"owner":"` + user.Id + `","tasks":[{"name":"Water","schedule":"every 2h"}]}]}`, nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/config/diff", `{"schedules":[{"owner":"team","tasks":[]}]}`, nil, http.StatusNotFound},
+		{http.MethodPost, "/api/v1/config/diff", "", nil, http.StatusNotFound},
+		{http.MethodDelete, taskPath, "", nil, http.StatusNoContent},
+		{http.MethodDelete, taskPath, "", nil, http.StatusNotFound},
+		{http.MethodGet, "/api/v1/trash?limit=10", "", nil, http.StatusOK},
+		{http.MethodPost, "/api/v1/trash/" + created.Id + "/restore", "", nil, http.StatusOK},
+		{http.MethodPost, "/api/v1/trash/" + created.Id + "/restore", "", nil, http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/tasks/imported", "", nil, http.StatusNoContent},
+		{http.MethodPost, "/api/v1/import", `{"tasks":[{"id":"imported","name":"Juice","schedule":"every 1h"}]}`, nil, http.StatusOK},
+		{http.MethodPost, "/api/v1/trash/imported/restore", "", nil, http.StatusConflict},
+		{http.MethodGet, "/api/v1/audit?action=deactivate&taskId=" + created.Id, "", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/audit?since=2024-05-01&until=2024-05-31", "", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/audit?action=bogus", "", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/audit?since=yesterday", "", nil, http.StatusBadRequest},
+	}
+
+	for _, step := range steps {
+		if res := do(step.method, step.path, step.body, step.header...); res.Code != step.want {
+			t.Errorf("%s %s was incorrect, got: %v %s, want: %v.", step.method, step.path, res.Code, res.Body.String(), step.want)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"scheduler/models"
+	"scheduler/utils"
+	"strings"
+	"time"
+)
+
+// The task operations below are shared by the JSON API and the gRPC service, the transports only map their errors.
+
+var (
+	// errTaskNotFound is the error of the store, so tasks deleted between a read and a write are reported the same way
+	errTaskNotFound   = models.ErrTaskNotFound
+	errTaskModified   = errors.New("task was modified, fetch it again")
+	errInvalidRequest = errors.New("invalid request")
+	errTaskState      = errors.New("task state conflict")
+)
+
+// taskChange modifies a task in place, see changeTask
+type taskChange func(task *models.Task) error
+
+func activateTask(task *models.Task) error {
+	activatedTime := time.Now()
+	task.ActivatedTime = &activatedTime
+	task.PausedRemaining = nil
+	return nil
+}
+
+func deactivateTask(task *models.Task) error {
+	task.ActivatedTime = nil
+	task.PausedRemaining = nil
+	return nil
+}
+
+func pauseTask(task *models.Task) error {
+	if !task.Pause() {
+		return fmt.Errorf("%w: only active tasks can be paused", errTaskState)
+	}
+	return nil
+}
+
+func resumeTask(task *models.Task) error {
+	if !task.Resume() {
+		return fmt.Errorf("%w: only paused tasks can be resumed", errTaskState)
+	}
+	return nil
+}
+
+// parseSnooze parses a snooze duration like "10min", empty is the DefaultSnooze
+func parseSnooze(value string) (time.Duration, error) {
+	if value == "" {
+		return models.DefaultSnooze, nil
+	}
+	duration, err := utils.ParseDelay(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("%w: invalid snooze duration <%s>", errInvalidRequest, value)
+	}
+	return duration, nil
+}
+
+// matchesETag implements the optimistic concurrency of writes. A write carrying entity tags only succeeds if the task
+// is unchanged since the client read it. Writes without tags are unconditional.
+func matchesETag(etags string, task *models.Task) bool {
+	if etags == "" || etags == "*" {
+		return true
+	}
+	for _, etag := range strings.Split(etags, ",") {
+		if strings.TrimSpace(etag) == models.ETag(task) {
+			return true
+		}
+	}
+	return false
+}
+
+func (tc *TaskController) findTask(author string, taskId string) (*models.Task, error) {
+	scheduler, err := tc.readSchedulerData(author)
+	if err != nil {
+		return nil, err
+	}
+
+	task := scheduler.FindTask(taskId)
+	if task == nil {
+		return nil, errTaskNotFound
+	}
+	return task, nil
+}
+
+func (tc *TaskController) createTask(access *ScheduleAccess, actor *models.AuditActor, request *models.TaskRequest) (*models.Task, error) {
+	tc.apiMu.Lock()
+	defer tc.apiMu.Unlock()
+
+	task := &models.Task{Id: utils.Uuid()}
+	if err := tc.applyTaskRequest(access, request, task); err != nil {
+		return nil, err
+	}
+
+	if err := tc.insertNewTask(access.Owner, task); err != nil {
+		return nil, err
+	}
+	log.Info().Str("task", task.Name).Str("author", access.Owner).Msg("Added new task via API")
+	tc.recordAudit(access.Owner, actor, models.AuditCreate, nil, task)
+
+	tc.sendTasksUpdate(access.Owner)
+	return task, nil
+}
+
+// replaceTask replaces the editable fields of a task. Like the edit row, an active task whose schedule changed
+// starts counting from now.
+func (tc *TaskController) replaceTask(access *ScheduleAccess, actor *models.AuditActor, taskId string, etag string, request *models.TaskRequest) (*models.Task, error) {
+	tc.apiMu.Lock()
+	defer tc.apiMu.Unlock()
+
+	task, err := tc.findTask(access.Owner, taskId)
+	if err != nil {
+		return nil, err
+	}
+	if !matchesETag(etag, task) {
+		return nil, errTaskModified
+	}
+
+	editedTask := *task
+	if err := tc.applyTaskRequest(access, request, &editedTask); err != nil {
+		return nil, err
+	}
+
+	isActive := task.IsActive()
+	if editedTask.Schedule != task.Schedule {
+		if isActive {
+			activatedTime := time.Now()
+			editedTask.ActivatedTime = &activatedTime
+		}
+		if task.IsPaused() {
+			taskDuration, _ := utils.ParseDuration(editedTask.Schedule)
+			editedTask.PausedRemaining = &taskDuration
+		}
+	}
+
+	updatedTask, err := tc.saveTask(access.Owner, &editedTask)
+	if err != nil {
+		return nil, err
+	}
+	if isActive {
+		tc.RegisterTaskSchedule(access.Owner, updatedTask)
+	}
+	tc.recordAudit(access.Owner, actor, models.AuditEdit, task, updatedTask)
+
+	return updatedTask, nil
+}
+
+func (tc *TaskController) deleteTask(author string, actor *models.AuditActor, taskId string, etag string) error {
+	tc.apiMu.Lock()
+	defer tc.apiMu.Unlock()
+
+	task, err := tc.findTask(author, taskId)
+	if err != nil {
+		return err
+	}
+	if !matchesETag(etag, task) {
+		return errTaskModified
+	}
+
+	tc.UnregisterTask(author, task)
+	if _, err := tc.store.DeleteTasks(author, []string{task.Id}); err != nil {
+		return err
+	}
+	log.Info().Str("task", task.Name).Msg("Deleted task via API")
+	tc.recordAudit(author, actor, models.AuditDelete, task, nil)
+
+	tc.sendTasksUpdate(author)
+	return nil
+}
+
+// changeTask applies a state change to the task, saves it, brings its timer in line and audits it as the action
+func (tc *TaskController) changeTask(author string, actor *models.AuditActor, taskId string, etag string, action models.AuditAction, change taskChange) (*models.Task, error) {
+	tc.apiMu.Lock()
+	defer tc.apiMu.Unlock()
+
+	task, err := tc.findTask(author, taskId)
+	if err != nil {
+		return nil, err
+	}
+	if !matchesETag(etag, task) {
+		return nil, errTaskModified
+	}
+
+	before := *task
+	if err := change(task); err != nil {
+		return nil, err
+	}
+
+	updatedTask, err := tc.saveTask(author, task)
+	if err != nil {
+		return nil, err
+	}
+	if updatedTask.IsActive() {
+		tc.RegisterTaskSchedule(author, updatedTask)
+	} else {
+		tc.UnregisterTask(author, updatedTask)
+	}
+	tc.recordAudit(author, actor, action, &before, updatedTask)
+
+	return updatedTask, nil
+}
+
+// snoozeTask lets the task fire again after the duration
+func (tc *TaskController) snoozeTask(author string, actor *models.AuditActor, taskId string, etag string, duration time.Duration) (*models.Task, error) {
+	task, err := tc.changeTask(author, actor, taskId, etag, models.AuditSnooze, func(task *models.Task) error {
+		task.Snooze(duration)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tc.recordOccurrence(author, task, models.OccurrenceSnoozed, actor.UserId)
+	return task, nil
+}
+
+// markTaskDone marks the alert of the task as handled and starts the tasks chained to it being done
+func (tc *TaskController) markTaskDone(author string, actor *models.AuditActor, taskId string) error {
+	tc.apiMu.Lock()
+	defer tc.apiMu.Unlock()
+
+	task, err := tc.findTask(author, taskId)
+	if err != nil {
+		return err
+	}
+
+	tc.recordOccurrence(author, task, models.OccurrenceDone, actor.UserId)
+	tc.recordAudit(author, actor, models.AuditDone, task, task)
+	tc.startSuccessors(author, task.Id, models.DependencyDone)
+	tc.sendTasksUpdate(author)
+	return nil
+}
+
+// applyTaskRequest validates the request against the schedule and copies it onto the task
+func (tc *TaskController) applyTaskRequest(access *ScheduleAccess, request *models.TaskRequest, task *models.Task) error {
+	if err := request.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidRequest, err)
+	}
+	request.Apply(task)
+
+	task.DependsOn = nil
+	if request.DependsOn != nil {
+		dependency, err := models.NewTaskDependency(request.DependsOn.TaskId, request.DependsOn.Event, request.DependsOn.Delay)
+		if err == nil {
+			task.DependsOn = dependency
+			var tasks []*models.Task
+			if tasks, err = tc.getTasks(access.Owner); err != nil {
+				return err
+			}
+			err = models.ValidateDependency(tasks, task)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidRequest, err)
+		}
+	}
+
+	if access.Team != nil {
+		assignees, err := models.NewTaskAssignees(access.Team, request.Assignees)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidRequest, err)
+		}
+		task.Assignees = assignees
+	} else if len(request.Assignees) > 0 {
+		return fmt.Errorf("%w: only tasks of team schedules can be assigned", errInvalidRequest)
+	}
+
+	return nil
+}
+
+func (tc *TaskController) saveTask(author string, task *models.Task) (*models.Task, error) {
+	updatedSchedule, err := tc.store.UpdateTask(author, task)
+	if err != nil {
+		return nil, err
+	}
+
+	tc.sendTasksUpdate(author)
+	return updatedSchedule.FindTask(task.Id), nil
+}
+
+// exportSchedule collects the schedule of the owner, with the history of its tasks on request
+func (tc *TaskController) exportSchedule(owner string, withHistory bool) (*models.ScheduleExport, error) {
+	scheduler, err := tc.readSchedulerData(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []*models.TaskOccurrence
+	if withHistory {
+		for _, task := range scheduler.Tasks {
+			for offset := 0; ; offset += models.MaxPageLimit {
+				occurrences, total, err := tc.store.GetOccurrences(owner, task.Id, offset, models.MaxPageLimit)
+				if err != nil {
+					return nil, err
+				}
+				history = append(history, occurrences...)
+				if len(occurrences) == 0 || int64(offset+len(occurrences)) >= total {
+					break
+				}
+			}
+		}
+	}
+
+	return models.NewScheduleExport(scheduler, history), nil
+}
+
+// importSchedule applies an import planned by models.PlanImport, unless it's a dry run or the report has problems.
+// Team is the team owning the schedule, nil for a personal schedule.
+func (tc *TaskController) importSchedule(owner string, team *models.Team, actor *models.AuditActor, export *models.ScheduleExport, mode string, dryRun bool) (*models.ImportReport, error) {
+	tc.apiMu.Lock()
+	defer tc.apiMu.Unlock()
+
+	current, err := tc.readSchedulerData(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	report, planned, err := models.PlanImport(current, export, mode, team)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidRequest, err)
+	}
+	report.DryRun = dryRun
+	if planned == nil || dryRun {
+		return report, nil
+	}
+
+	switch mode {
+	case models.ImportReplace:
+		if err := models.ApplyReplaceImport(tc.store, report, planned); err != nil {
+			return nil, err
+		}
+		for _, task := range current.Tasks {
+			if kept := planned.FindTask(task.Id); kept == nil || !kept.IsActive() {
+				tc.UnregisterTask(owner, task)
+			}
+		}
+	case models.ImportMerge:
+		// the new tasks and routines are added one by one, like the forms do, so concurrent edits are kept
+		for _, id := range report.Created {
+			if _, err := tc.store.InsertTask(owner, planned.FindTask(id)); err != nil {
+				return nil, err
+			}
+		}
+		// merging appends the new routines after the current ones
+		for _, routine := range planned.Routines[len(current.Routines):] {
+			if _, err := tc.store.InsertRoutine(owner, routine); err != nil {
+				return nil, err
+			}
+		}
+	}
+	log.Info().Str("author", owner).Str("mode", mode).Int("created", len(report.Created)).
+		Int("updated", len(report.Updated)).Int("removed", len(report.Removed)).Msg("Imported schedule")
+
+	for _, id := range report.Created {
+		tc.recordAudit(owner, actor, models.AuditCreate, nil, planned.FindTask(id))
+	}
+	for _, id := range report.Updated {
+		tc.recordAudit(owner, actor, models.AuditEdit, current.FindTask(id), planned.FindTask(id))
+	}
+	for _, id := range report.Removed {
+		tc.recordAudit(owner, actor, models.AuditDelete, current.FindTask(id), nil)
+	}
+
+	report.Applied = true
+	tc.sendTasksUpdate(owner)
+	return report, nil
+}
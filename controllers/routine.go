@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"scheduler/models"
+	"scheduler/utils"
+	"time"
+)
+
+func (tc *TaskController) GetRoutines(c *gin.Context) {
+	author, ok := tc.authorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
+		return
+	}
+
+	c.HTML(http.StatusOK, "routines/list", models.RoutinesData{
+		Routines: scheduler.Routines,
+	})
+}
+
+func (tc *TaskController) NewRoutine(c *gin.Context) {
+	formData := &models.NewRoutineFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	log.Info().Str("routine", formData.Name).Msg("Adding new routine")
+
+	routineTasks, err := models.ParseRoutineTasks(formData.Tasks)
+	if err != nil {
+		LogError(err, "Failed to parse routine", c)
+		scheduler, ok := tc.readSchedule(c, author)
+		if !ok {
+			return
+		}
+		c.HTML(http.StatusOK, "routines/list", models.RoutinesData{
+			Routines: scheduler.Routines,
+			Error:    err.Error(),
+		})
+		return
+	}
+
+	routine := &models.Routine{Id: utils.Uuid(), Name: formData.Name, Tasks: routineTasks}
+
+	updatedSchedule, err := tc.store.InsertRoutine(author, routine)
+	if err != nil {
+		LogError(err, "Could not save routine", c)
+		scheduler, ok := tc.readSchedule(c, author)
+		if !ok {
+			return
+		}
+		c.HTML(http.StatusOK, "routines/list", models.RoutinesData{
+			Routines: scheduler.Routines,
+			Error:    "FAILED TO SAVE ROUTINE",
+		})
+		return
+	}
+
+	log.Info().Str("routine", formData.Name).Str("author", author).Msg("Added new routine")
+
+	c.HTML(http.StatusOK, "routines/list", models.RoutinesData{
+		Routines: updatedSchedule.Routines,
+	})
+}
+
+func (tc *TaskController) DeleteRoutine(c *gin.Context) {
+	routineId := c.Param("id")
+	log.Debug().Str("routineId", routineId).Msg("Deleting routine")
+
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	updatedSchedule, err := tc.store.DeleteRoutine(author, routineId)
+	if err != nil {
+		LogError(err, "Could not delete routine", c)
+		if updatedSchedule, ok = tc.readSchedule(c, author); !ok {
+			return
+		}
+	}
+
+	c.HTML(http.StatusOK, "routines/list", models.RoutinesData{
+		Routines: updatedSchedule.Routines,
+	})
+}
+
+// StartRoutine instantiates all member tasks of a routine in one action.
+// Member tasks that exist from an earlier start are brought back in line with the routine definition and re-activated,
+// missing ones are created. Each member is activated relative to the routine start by its offset.
+func (tc *TaskController) StartRoutine(c *gin.Context) {
+	routineId := c.Param("id")
+
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
+		return
+	}
+	routine := scheduler.FindRoutine(routineId)
+	if routine == nil {
+		c.String(http.StatusNotFound, "")
+		return
+	}
+
+	log.Info().Str("routine", routine.Name).Msg("Starting routine")
+
+	startTime := time.Now()
+	actor := requestActor(c)
+
+	for _, member := range routine.Tasks {
+		activatedTime := startTime.Add(member.GetOffset())
+
+		task := scheduler.FindTaskByName(routine.TaskName(member))
+		if task == nil {
+			task = member.ToNewTaskFormData(routine).ToTask()
+			task.ActivatedTime = &activatedTime
+
+			if err := tc.insertNewTask(author, task); err != nil {
+				LogError(err, "Could not create routine task", c)
+				continue
+			}
+			scheduler.Tasks = append(scheduler.Tasks, task)
+			tc.recordAudit(author, actor, models.AuditCreate, nil, task)
+		} else {
+			before := *task
+			task.Schedule = member.Schedule
+			task.Trigger = member.Trigger
+			task.ActivatedTime = &activatedTime
+			task.PausedRemaining = nil
+
+			if _, err := tc.store.UpdateTask(author, task); err != nil {
+				LogError(err, "Could not update routine task", c)
+				continue
+			}
+			tc.recordAudit(author, actor, models.AuditActivate, &before, task)
+		}
+
+		tc.RegisterTaskSchedule(author, task)
+	}
+
+	log.Info().Str("routine", routine.Name).Int("tasks", len(routine.Tasks)).Msg("Started routine")
+
+	tc.sc.Message <- &Event{
+		Message: nil,
+		Type:    EVENT_TASKS_UPDATE,
+		Owner:   author,
+	}
+
+	tc.renderTasksBody(c, scheduler.Tasks)
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"scheduler/models"
+)
+
+const (
+	scheduleContextKey = "schedule"
+	// scheduleHeaderName selects a team schedule for htmx requests, full page loads use the scheduleParam
+	scheduleHeaderName = "X-Schedule"
+	scheduleParam      = "schedule"
+)
+
+// ScheduleAccess is the schedule a request works on and the role the user has on it
+type ScheduleAccess struct {
+	// Owner is the Author of the schedule, the user id for the personal schedule or the team id
+	Owner string
+	// Team is nil for the personal schedule
+	Team *models.Team
+	Role models.TeamRole
+}
+
+var (
+	errScheduleNotFound  = errors.New("schedule not found")
+	errScheduleForbidden = errors.New("missing role on schedule")
+)
+
+// authorizeSchedule resolves the schedule selected by the request and checks the user has at least the role on it.
+// The personal schedule is selected if none is given, its owner has every permission.
+// On failure the response is written and ok is false.
+func (tc *TaskController) authorizeSchedule(c *gin.Context, role models.TeamRole) (owner string, ok bool) {
+	access, err := tc.scheduleAccess(c, role)
+	if err != nil {
+		c.String(scheduleErrorStatus(err), "")
+		return "", false
+	}
+	return access.Owner, true
+}
+
+func (tc *TaskController) scheduleAccess(c *gin.Context, role models.TeamRole) (*ScheduleAccess, error) {
+	scheduleId := c.GetHeader(scheduleHeaderName)
+	if scheduleId == "" {
+		scheduleId = c.Query(scheduleParam)
+	}
+
+	access, err := tc.resolveSchedule(CurrentUser(c), scheduleId, role)
+	if err != nil {
+		if scheduleErrorStatus(err) == http.StatusInternalServerError {
+			LogError(err, "Could not read team", c)
+		}
+		return nil, err
+	}
+
+	c.Set(scheduleContextKey, access)
+	return access, nil
+}
+
+// resolveSchedule is the transport independent part of scheduleAccess, scheduleId is empty for the personal schedule
+func (tc *TaskController) resolveSchedule(user *models.User, scheduleId string, role models.TeamRole) (*ScheduleAccess, error) {
+	access := &ScheduleAccess{Owner: user.Id, Role: models.RoleAdmin}
+	if scheduleId != "" && scheduleId != user.Id {
+		team, err := tc.teamDBM.GetTeam(scheduleId)
+		if err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				return nil, errScheduleNotFound
+			}
+			return nil, err
+		}
+		access = &ScheduleAccess{Owner: team.Id, Team: team, Role: team.Role(user.Id)}
+	}
+
+	if !access.Role.Includes(role) {
+		log.Warn().Str("user", user.Name).Str("schedule", access.Owner).Str("role", string(role)).Msg("Rejected request without team role")
+		if access.Role == "" {
+			// don't reveal teams to non-members
+			return nil, errScheduleNotFound
+		}
+		return nil, errScheduleForbidden
+	}
+
+	return access, nil
+}
+
+func scheduleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errScheduleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errScheduleForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func currentSchedule(c *gin.Context) *ScheduleAccess {
+	if access, exists := c.Get(scheduleContextKey); exists {
+		return access.(*ScheduleAccess)
+	}
+	return nil
+}
+
+// scheduleMembers returns the members of the authorized team schedule, nil for the personal schedule
+func scheduleMembers(c *gin.Context) []*models.TeamMember {
+	if access := currentSchedule(c); access != nil && access.Team != nil {
+		return access.Team.Members
+	}
+	return nil
+}
+
+// ScheduleOwners returns the owners of all schedules of the user, the personal one and those of the user's teams.
+// Stream connections are subscribed to them, so alerts of a team schedule reach every member.
+func (tc *TaskController) ScheduleOwners(c *gin.Context) []string {
+	owners, err := tc.scheduleOwners(CurrentUser(c).Id)
+	if err != nil {
+		LogError(err, "Could not read teams", c)
+	}
+	return owners
+}
+
+// scheduleOwners falls back to the personal schedule if the teams can't be read
+func (tc *TaskController) scheduleOwners(userId string) ([]string, error) {
+	owners := []string{userId}
+
+	teams, err := tc.teamDBM.GetTeamsByMember(userId)
+	if err != nil {
+		return owners, err
+	}
+	for _, team := range teams {
+		owners = append(owners, team.Id)
+	}
+
+	return owners, nil
+}
@@ -3,11 +3,13 @@ package controllers
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
+	"slices"
 )
 
 const (
-	EVENT_TASK_ALERT   = 1
-	EVENT_TASKS_UPDATE = 2
+	EVENT_TASK_ALERT    = 1
+	EVENT_TASKS_UPDATE  = 2
+	EVENT_TASKS_DELETED = 3
 )
 
 // New event messages are broadcast to all registered client connection channels
@@ -16,6 +18,26 @@ type ClientChan chan *Event
 type Event struct {
 	Message interface{}
 	Type    int
+	// Owner is the user or team owning the schedule of the event, only connections subscribed to it receive the event.
+	// Empty broadcasts to everyone.
+	Owner string
+	// Recipients further limits the event to the connections of these users, e.g. the assignees of an alerted task
+	Recipients []string
+}
+
+// StreamClient is a connection channel together with its user and the schedule owners it's subscribed to
+type StreamClient struct {
+	Channel ClientChan
+	UserId  string
+	Owners  []string
+}
+
+// receives tells if the event is meant for the client
+func (client *StreamClient) receives(event *Event) bool {
+	if event.Owner != "" && !slices.Contains(client.Owners, event.Owner) {
+		return false
+	}
+	return len(event.Recipients) == 0 || slices.Contains(event.Recipients, client.UserId)
 }
 
 type StreamController struct {
@@ -23,21 +45,21 @@ type StreamController struct {
 	Message chan *Event
 
 	// New client connections
-	NewClients chan ClientChan
+	NewClients chan *StreamClient
 
 	// Closed client connections
 	ClosedClients chan ClientChan
 
 	// Total client connections
-	TotalClients map[ClientChan]bool
+	TotalClients map[ClientChan]*StreamClient
 }
 
 func NewStreamController() (sc *StreamController) {
 	sc = &StreamController{
 		Message:       make(chan *Event),
-		NewClients:    make(chan ClientChan),
+		NewClients:    make(chan *StreamClient),
 		ClosedClients: make(chan ClientChan),
-		TotalClients:  make(map[ClientChan]bool),
+		TotalClients:  make(map[ClientChan]*StreamClient),
 	}
 
 	go sc.listen()
@@ -46,13 +68,13 @@ func NewStreamController() (sc *StreamController) {
 }
 
 // It Listens all incoming requests from clients.
-// Handles addition and removal of clients and broadcast messages to clients.
+// Handles addition and removal of clients and broadcast messages to the clients the event is meant for.
 func (sc *StreamController) listen() {
 	for {
 		select {
 		// Add new available client
 		case client := <-sc.NewClients:
-			sc.TotalClients[client] = true
+			sc.TotalClients[client.Channel] = client
 			log.Info().Msgf("Client added. %d registered clients", len(sc.TotalClients))
 
 		// Remove closed client
@@ -63,20 +85,23 @@ func (sc *StreamController) listen() {
 
 		// Broadcast message to client
 		case eventMsg := <-sc.Message:
-			for clientMessageChan := range sc.TotalClients {
-				clientMessageChan <- eventMsg
+			for clientMessageChan, client := range sc.TotalClients {
+				if client.receives(eventMsg) {
+					clientMessageChan <- eventMsg
+				}
 			}
 		}
 	}
 }
 
-func (sc *StreamController) ServeHTTP() gin.HandlerFunc {
+// ServeHTTP registers the connection for the events of the schedules returned by owners
+func (sc *StreamController) ServeHTTP(owners func(c *gin.Context) []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Initialize client channel
 		clientChan := make(ClientChan)
 
 		// Send new connection to event server
-		sc.NewClients <- clientChan
+		sc.NewClients <- &StreamClient{Channel: clientChan, UserId: CurrentUser(c).Id, Owners: owners(c)}
 
 		defer func() {
 			// Send closed connection to event server
@@ -89,6 +114,20 @@ func (sc *StreamController) ServeHTTP() gin.HandlerFunc {
 	}
 }
 
+// Subscribe registers a connection outside of ServeHTTP, like the API and gRPC alert streams.
+// The returned function unregisters it again.
+func (sc *StreamController) Subscribe(userId string, owners []string) (ClientChan, func()) {
+	clientChan := make(ClientChan)
+	sc.NewClients <- &StreamClient{Channel: clientChan, UserId: userId, Owners: owners}
+
+	return clientChan, func() {
+		// keep receiving until the channel is closed, the broadcast may be sending to it right now
+		go func() { sc.ClosedClients <- clientChan }()
+		for range clientChan {
+		}
+	}
+}
+
 func StreamHeadersMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Content-Type", "text/event-stream")
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreamController_BroadcastToOwners(t *testing.T) {
+	sc := NewStreamController()
+
+	member := make(ClientChan, 1)
+	outsider := make(ClientChan, 1)
+	sc.NewClients <- &StreamClient{Channel: member, Owners: []string{"ann", "team"}}
+	sc.NewClients <- &StreamClient{Channel: outsider, Owners: []string{"bob"}}
+
+	sc.Message <- &Event{Type: EVENT_TASKS_UPDATE, Owner: "team"}
+
+	select {
+	case <-member:
+	case <-time.After(time.Second):
+		t.Errorf("Team member did not receive the team event")
+	}
+	select {
+	case <-outsider:
+		t.Errorf("Outsider received the team event")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestStreamClient_ReceivesAssignedAlert(t *testing.T) {
+	assignee := &StreamClient{UserId: "ann", Owners: []string{"ann", "team"}}
+	member := &StreamClient{UserId: "bob", Owners: []string{"bob", "team"}}
+	event := &Event{Type: EVENT_TASK_ALERT, Owner: "team", Recipients: []string{"ann"}}
+
+	if !assignee.receives(event) {
+		t.Errorf("Assignee did not receive the alert")
+	}
+	if member.receives(event) {
+		t.Errorf("Member without assignment received the alert")
+	}
+
+	event.Recipients = nil
+	if !member.receives(event) {
+		t.Errorf("Member did not receive the alert of an unassigned task")
+	}
+}
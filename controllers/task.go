@@ -1,14 +1,14 @@
 package controllers
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"html/template"
 	"net/http"
-	"os"
 	"scheduler/models"
 	"scheduler/utils"
+	"sync"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -16,14 +16,25 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const useDB = true
-
 type TaskController struct {
 	template *template.Template
 	// ... add fields like database connection or services here.
-	sc           *StreamController
-	taskDBM      *models.TaskDBModel
+	sc *StreamController
+	// store holds the schedules and the history of fired and handled alerts
+	store   models.TaskStore
+	teamDBM models.TeamStore
+	userDBM models.UserStore
+	// notifiers deliver alerts to the personal channels of the alerted users
+	notifiers    []Notifier
 	taskRegistry map[string]*time.Timer
+	// registryMu guards taskRegistry, timers fire on their own goroutines
+	registryMu sync.Mutex
+	// apiMu serializes the conditional writes of the API, so an If-Match check and its write can't interleave
+	apiMu sync.Mutex
+	// config is the watched file of declared schedules, nil if there's none
+	config *configWatcher
+	// trashRetention is how long deleted tasks can be restored, see StartTrashPurge
+	trashRetention time.Duration
 }
 
 func LogError(err error, msg string, ctx *gin.Context) {
@@ -36,32 +47,82 @@ func LogError(err error, msg string, ctx *gin.Context) {
 	}
 }
 
-func NewTaskController(streamController *StreamController, template *template.Template, taskDBM *models.TaskDBModel) *TaskController {
+func NewTaskController(streamController *StreamController, template *template.Template, store models.TaskStore, teamDBM models.TeamStore, userDBM models.UserStore) *TaskController {
 	return &TaskController{
-		template:     template,
-		sc:           streamController,
-		taskDBM:      taskDBM,
-		taskRegistry: make(map[string]*time.Timer),
+		template:       template,
+		sc:             streamController,
+		store:          store,
+		teamDBM:        teamDBM,
+		userDBM:        userDBM,
+		notifiers:      []Notifier{NewWebhookNotifier()},
+		taskRegistry:   make(map[string]*time.Timer),
+		trashRetention: models.DefaultTrashRetention,
 	}
 }
 
 func (tc *TaskController) GetTasks(c *gin.Context) {
-	tasks := tc.getTasks()
-	checkExpiredTasks(tasks)
-	err := tc.writeTasksData(tasks)
-	if err != nil {
-		log.Fatal().Msg("Could not write tasks data")
+	author, ok := tc.authorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+	// expired tasks are inactive by their remaining time, the timers reset them in the store
+	tasks, ok := tc.readTasks(c, author)
+	if !ok {
+		return
 	}
 
-	viewTasks := models.GetViewTasks(tasks)
+	filter := &models.TaskFilter{}
+	_ = c.ShouldBindQuery(filter)
+	if err := filter.Validate(); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	teams, err := tc.teamDBM.GetTeamsByMember(CurrentUser(c).Id)
+	if err != nil {
+		LogError(err, "Could not read teams", c)
+	}
+	access := currentSchedule(c)
+	pageData := GetPageData(c)
+	setEntityTag(c, tasks, pageData, access, teams, c.Request.URL.RawQuery)
 
 	c.HTML(http.StatusOK, "pages/tasks", models.TasksPageData{
-		Tasks: viewTasks,
+		PageData: pageData,
+		Tasks:    models.GetFilteredViewTasks(tasks, filter),
+		Filter:   filter,
+		Tags:     models.CollectTags(tasks),
+		Team:     access.Team,
+		Teams:    teams,
+		CanEdit:  access.Role.Includes(models.RoleEditor),
 	})
 }
 
+// GetTasksBody renders the filtered task table body, it's refetched by the page on every tasks-update event
+func (tc *TaskController) GetTasksBody(c *gin.Context) {
+	author, ok := tc.authorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+	tasks, ok := tc.readTasks(c, author)
+	if !ok {
+		return
+	}
+	setEntityTag(c, tasks, author, c.Request.URL.RawQuery)
+	tc.renderTasksBody(c, tasks)
+}
+
 func (tc *TaskController) GetNewTaskForm(c *gin.Context) {
-	c.HTML(http.StatusOK, "tasks/new-form", gin.H{})
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	tasks, ok := tc.readTasks(c, author)
+	if !ok {
+		return
+	}
+	c.HTML(http.StatusOK, "tasks/new-form", models.NewTaskFormPageData{
+		Tasks: models.GetViewTasks(tasks),
+	})
 }
 
 func (tc *TaskController) NewTask(c *gin.Context) {
@@ -70,6 +131,14 @@ func (tc *TaskController) NewTask(c *gin.Context) {
 		return
 	}
 
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	if !formData.Priority.IsValid() {
+		c.String(http.StatusBadRequest, models.ErrInvalidPriority.Error())
+		return
+	}
 	log.Info().Str("task", formData.Name).Msg("Adding new task")
 
 	_, err := utils.ParseDuration(formData.Schedule)
@@ -79,16 +148,33 @@ func (tc *TaskController) NewTask(c *gin.Context) {
 		return
 	}
 
-	newTask := models.Task{Id: utils.Uuid(), Name: formData.Name, Schedule: formData.Schedule, Trigger: formData.Trigger}
+	newTask := formData.ToTask()
 
-	author := "1337"
-	err = tc.insertNewTask(author, &newTask)
+	tasks, ok := tc.readTasks(c, author)
+	if !ok {
+		return
+	}
+	newTask.DependsOn, err = models.NewTaskDependency(formData.DependsOn, formData.DependsOnEvent, formData.DependsOnDelay)
+	if err == nil {
+		err = models.ValidateDependency(tasks, newTask)
+	}
+	if err != nil {
+		LogError(err, "Invalid task dependency", c)
+		c.HTML(http.StatusOK, "response/new-task.html", gin.H{"Error": "INVALID DEPENDENCY"})
+		return
+	}
+
+	err = tc.insertNewTask(author, newTask)
 
 	log.Info().Str("task", formData.Name).Str("author", author).Msg("Added new task")
+	if err == nil {
+		tc.recordAudit(author, requestActor(c), models.AuditCreate, nil, newTask)
+	}
 
 	tc.sc.Message <- &Event{
 		Message: nil,
 		Type:    EVENT_TASKS_UPDATE,
+		Owner:   author,
 	}
 
 	if err != nil {
@@ -98,32 +184,168 @@ func (tc *TaskController) NewTask(c *gin.Context) {
 	c.HTML(http.StatusOK, "response/new-task.html", gin.H{"Name": formData.Name})
 }
 
-func (tc *TaskController) GetTasksUpdate() string {
-	tasks := tc.getTasks()
-	viewTasks := models.GetViewTasks(tasks)
-	taskListTpl, _ := utils.RenderTemplate(tc.template, "tasks/table-body", models.TasksUpdateData{
-		Tasks: viewTasks,
-	})
+func (tc *TaskController) GetTask(c *gin.Context) {
+	author, ok := tc.authorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
+		return
+	}
+	task := scheduler.FindTask(c.Param("id"))
+	if task == nil {
+		c.String(http.StatusNotFound, "")
+		return
+	}
 
-	return taskListTpl
+	c.HTML(http.StatusOK, "tasks/row", task.ToTaskVM())
 }
 
-func (tc *TaskController) RegisterAllTasksSchedules() {
-	scheduler := tc.readSchedulerData()
-	if scheduler == nil {
-		log.Info().Msg("Schedule not found. Skipping to register tasks")
+func (tc *TaskController) GetEditTaskForm(c *gin.Context) {
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
 		return
 	}
+	task := scheduler.FindTask(c.Param("id"))
+	if task == nil {
+		c.String(http.StatusNotFound, "")
+		return
+	}
+
+	c.HTML(http.StatusOK, "tasks/edit-row", models.EditTaskData{
+		Task:    task.ToTaskVM(),
+		Tasks:   models.GetViewTasks(scheduler.Tasks),
+		Members: scheduleMembers(c),
+	})
+}
 
-	checkExpiredTasks(scheduler.Tasks)
-	err := tc.writeTasksData(scheduler.Tasks)
+// UpdateTask edits name, schedule and trigger of an existing task while keeping its id.
+// An active task gets its timer re-registered, so a changed schedule starts counting from now.
+func (tc *TaskController) UpdateTask(c *gin.Context) {
+	formData := &models.EditTaskFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
+		return
+	}
+	task := scheduler.FindTask(c.Param("id"))
+	if task == nil {
+		c.String(http.StatusNotFound, "")
+		return
+	}
+
+	if !formData.Priority.IsValid() {
+		c.String(http.StatusBadRequest, models.ErrInvalidPriority.Error())
+		return
+	}
+
+	log.Info().Str("taskId", task.Id).Str("task", formData.Name).Msg("Updating task")
+
+	editedTask := *task
+	editedTask.Name = formData.Name
+	editedTask.Schedule = formData.Schedule
+	editedTask.Trigger = formData.Trigger
+	editedTask.Tags = models.ParseTags(formData.Tags)
+	editedTask.Priority = formData.Priority
+
+	editData := models.EditTaskData{Task: editedTask.ToTaskVM(), Tasks: models.GetViewTasks(scheduler.Tasks), Members: scheduleMembers(c)}
+
+	if _, err := utils.ParseDuration(formData.Schedule); err != nil {
+		LogError(err, "Failed to parse schedule", c)
+		editData.Error = "FAILED TO PARSE SCHEDULE"
+		c.HTML(http.StatusOK, "tasks/edit-row", editData)
+		return
+	}
+
+	dependency, err := models.NewTaskDependency(formData.DependsOn, formData.DependsOnEvent, formData.DependsOnDelay)
+	if err == nil {
+		editedTask.DependsOn = dependency
+		err = models.ValidateDependency(scheduler.Tasks, &editedTask)
+	}
 	if err != nil {
-		log.Error().Msg("Could not update tasks after checking expirey")
+		LogError(err, "Invalid task dependency", c)
+		editData.Error = "INVALID DEPENDENCY"
+		c.HTML(http.StatusOK, "tasks/edit-row", editData)
+		return
+	}
+
+	if team := currentSchedule(c).Team; team != nil {
+		editedTask.Assignees, err = models.NewTaskAssignees(team, formData.Assignees)
+		if err != nil {
+			LogError(err, "Invalid task assignees", c)
+			editData.Error = "INVALID ASSIGNEE"
+			c.HTML(http.StatusOK, "tasks/edit-row", editData)
+			return
+		}
+	}
+
+	isActive := task.IsActive()
+	if isActive && editedTask.Schedule != task.Schedule {
+		activatedTime := time.Now()
+		editedTask.ActivatedTime = &activatedTime
+	}
+	if task.IsPaused() && editedTask.Schedule != task.Schedule {
+		// the frozen remaining time belongs to the old schedule
+		taskDuration, _ := utils.ParseDuration(editedTask.Schedule)
+		editedTask.PausedRemaining = &taskDuration
+	}
+
+	updatedSchedule, err := tc.store.UpdateTask(author, &editedTask)
+	if err != nil {
+		LogError(err, "Could not update task", c)
+		editData.Error = "FAILED TO SAVE TASK"
+		c.HTML(http.StatusOK, "tasks/edit-row", editData)
+		return
+	}
+
+	if isActive {
+		tc.UnregisterTask(author, task)
+		tc.RegisterTaskSchedule(author, updatedSchedule.FindTask(editedTask.Id))
 	}
+	tc.recordAudit(author, requestActor(c), models.AuditEdit, task, updatedSchedule.FindTask(editedTask.Id))
 
-	for _, task := range scheduler.Tasks {
-		if task.IsActive() {
-			tc.RegisterTaskSchedule(task)
+	log.Info().Str("taskId", task.Id).Str("task", editedTask.Name).Msg("Updated task")
+
+	tc.sc.Message <- &Event{
+		Message: nil,
+		Type:    EVENT_TASKS_UPDATE,
+		Owner:   author,
+	}
+
+	tc.renderTasksBody(c, updatedSchedule.Tasks)
+}
+
+// RegisterAllTasksSchedules starts the live timers of the active tasks of every user
+func (tc *TaskController) RegisterAllTasksSchedules() {
+	schedules := tc.readAllSchedulerData()
+	if len(schedules) == 0 {
+		log.Info().Msg("No schedules found. Skipping to register tasks")
+		return
+	}
+
+	for _, scheduler := range schedules {
+		for _, task := range checkExpiredTasks(scheduler.Tasks) {
+			if err := tc.store.UpdateTaskActivatedTime(scheduler.Author, task); err != nil {
+				log.Error().Str("author", scheduler.Author).Msg("Could not update tasks after checking expirey")
+			}
+		}
+
+		for _, task := range scheduler.Tasks {
+			if task.IsActive() {
+				tc.RegisterTaskSchedule(scheduler.Author, task)
+			}
 		}
 	}
 }
@@ -140,55 +362,157 @@ func (tc *TaskController) RegisterRefreshInterval() {
 	}()
 }
 
-func (tc *TaskController) RegisterTaskSchedule(task *models.Task) {
+// RegisterTaskSchedule starts the live timer of an active task. The timer fires after the remaining time,
+// so tasks that were activated earlier (e.g. before a restart or an edit) keep their target time.
+// Timers are scoped to the author, the alert is only sent to the assignees of the task or, if it has none,
+// to everybody on the author's schedule.
+func (tc *TaskController) RegisterTaskSchedule(author string, task *models.Task) {
 	taskDuration, _ := utils.ParseDuration(task.Schedule)
+	if remainingTime := task.GetRemainingTime(); remainingTime != nil {
+		taskDuration = *remainingTime
+	}
 	log.Debug().Str("task", task.Name).Dur("duration", taskDuration).Msg("Register task")
 
 	timer := time.AfterFunc(taskDuration, func() {
 		log.Debug().Str("task", task.Name).Msg("Task expired ")
-		tc.sc.Message <- &Event{
-			Message: task,
-			Type:    EVENT_TASK_ALERT,
-		}
+		tc.sendAlert(author, task, task.AssigneeIds())
+		tc.recordOccurrence(author, task, models.OccurrenceFired, "")
 
 		isRepetitive := utils.IsRepetitiveSchedule(task.Schedule)
 		if isRepetitive {
-			tc.ResetTask(task)
+			tc.ResetTask(author, task)
 		} else {
-			tc.UnregisterTask(task)
+			tc.UnregisterTask(author, task)
+			tc.expireTask(author, task)
 		}
+
+		tc.startSuccessors(author, task.Id, models.DependencyFired)
 	})
 
-	tc.taskRegistry[task.Id] = timer
+	tc.registryMu.Lock()
+	defer tc.registryMu.Unlock()
+
+	key := registryKey(author, task.Id)
+	if previousTimer, exists := tc.taskRegistry[key]; exists {
+		previousTimer.Stop()
+	}
+	tc.taskRegistry[key] = timer
 }
 
-func (tc *TaskController) UnregisterTask(task *models.Task) {
+// sendAlert sends the alert of a task to the stream connections and personal notifiers of the recipients.
+// Without recipients everybody on the schedule is alerted.
+func (tc *TaskController) sendAlert(author string, task *models.Task, recipients []string) {
+	tc.sc.Message <- &Event{
+		Message:    task,
+		Type:       EVENT_TASK_ALERT,
+		Owner:      author,
+		Recipients: recipients,
+	}
+
+	go tc.notify(author, task, recipients)
+}
+
+func (tc *TaskController) notify(author string, task *models.Task, recipients []string) {
+	if len(recipients) == 0 {
+		recipients = []string{author}
+		if team, err := tc.teamDBM.GetTeam(author); err == nil {
+			recipients = team.MemberIds()
+		}
+	}
+
+	alert := NewAlertNotification(author, task)
+	for _, userId := range recipients {
+		user, err := tc.userDBM.GetUserById(userId)
+		if err != nil {
+			continue
+		}
+		for _, notifier := range tc.notifiers {
+			if err := notifier.Notify(user, alert); err != nil {
+				log.Warn().Err(err).Str("user", user.Name).Str("task", task.Name).Msg("Could not notify user")
+			}
+		}
+	}
+}
+
+func (tc *TaskController) UnregisterTask(author string, task *models.Task) {
 	log.Debug().Str("task", task.Name).Msg("Unregistering task")
 
-	if timer, exists := tc.taskRegistry[task.Id]; exists {
+	tc.registryMu.Lock()
+	defer tc.registryMu.Unlock()
+
+	key := registryKey(author, task.Id)
+	if timer, exists := tc.taskRegistry[key]; exists {
 		timer.Stop()
-		delete(tc.taskRegistry, task.Id)
+		delete(tc.taskRegistry, key)
 		log.Info().Str("task", task.Name).Msg("Unregistered task")
 	}
 }
 
-func (tc *TaskController) ResetTask(task *models.Task) {
+// expireTask resets the activated time of a task whose timer ran out, so the store doesn't keep it as running
+func (tc *TaskController) expireTask(author string, task *models.Task) {
+	expired := *task
+	expired.ActivatedTime = nil
+	if err := tc.store.UpdateTaskActivatedTime(author, &expired); err != nil && !errors.Is(err, models.ErrTaskNotFound) {
+		log.Error().Err(err).Str("task", task.Name).Msg("Could not reset expired task")
+	}
+}
+
+func (tc *TaskController) ResetTask(author string, task *models.Task) {
 	log.Debug().Str("task", task.Name).Msg("Resetting task")
 
-	if timer, exists := tc.taskRegistry[task.Id]; exists {
+	tc.registryMu.Lock()
+	defer tc.registryMu.Unlock()
+
+	if timer, exists := tc.taskRegistry[registryKey(author, task.Id)]; exists {
 		newActivatedTime := time.Now()
 		task.ActivatedTime = &newActivatedTime
 		taskDuration, _ := utils.ParseDuration(task.Schedule)
 		timer.Reset(taskDuration)
 
-		err := tc.taskDBM.UpdateTaskActivatedTime(task)
+		err := tc.store.UpdateTaskActivatedTime(author, task)
 		if err == nil {
 			log.Info().Str("task", task.Name).Msg("Reset task")
 		}
 	}
 }
 
-func (tc *TaskController) GetAlertTpl(task *models.Task) string {
+// startSuccessors activates all tasks chained to the given task for the event.
+// A delay is applied by moving the activated time into the future, so it survives restarts.
+func (tc *TaskController) startSuccessors(author string, taskId string, event models.DependencyEvent) {
+	scheduler, err := tc.readSchedulerData(author)
+	if err != nil {
+		log.Error().Err(err).Str("task", taskId).Msg("Could not read the successors of a task")
+		return
+	}
+
+	successors := models.GetSuccessors(scheduler.Tasks, taskId, event)
+	if len(successors) == 0 {
+		return
+	}
+
+	for _, successor := range successors {
+		activatedTime := time.Now().Add(successor.DependsOn.GetDelay())
+		successor.ActivatedTime = &activatedTime
+		successor.PausedRemaining = nil
+
+		if _, err := tc.store.UpdateTask(author, successor); err != nil {
+			log.Error().Err(err).Str("task", successor.Name).Msg("Could not start successor task")
+			continue
+		}
+
+		tc.RegisterTaskSchedule(author, successor)
+		log.Info().Str("task", successor.Name).Str("predecessor", taskId).Str("event", string(event)).Msg("Started successor task")
+	}
+
+	tc.sc.Message <- &Event{
+		Message: nil,
+		Type:    EVENT_TASKS_UPDATE,
+		Owner:   author,
+	}
+}
+
+// GetAlertTpl renders the alert of a task for the user, owner is the owner of the task's schedule the alert actions go to
+func (tc *TaskController) GetAlertTpl(user *models.User, owner string, task *models.Task) string {
 	var tplName string
 	if task.Trigger == "popup" {
 		tplName = "alerts/popup"
@@ -196,33 +520,51 @@ func (tc *TaskController) GetAlertTpl(task *models.Task) string {
 		tplName = "alerts/audio"
 	}
 
-	alertTpl, _ := utils.RenderTemplate(tc.template, tplName, models.AlertPopupData{
-		Task: task.ToTaskVM(),
-	})
+	alertTpl, _ := utils.RenderTemplate(tc.template, tplName, tc.alertData(user, owner, task))
 
 	return alertTpl
 }
 
+func (tc *TaskController) alertData(user *models.User, owner string, task *models.Task) models.AlertPopupData {
+	alertData := models.AlertPopupData{
+		Task:     task.ToTaskVM(),
+		Schedule: owner,
+		UserId:   user.Id,
+	}
+	if owner != user.Id {
+		if team, err := tc.teamDBM.GetTeam(owner); err == nil {
+			alertData.Members = team.Members
+		}
+	}
+	return alertData
+}
+
 func (tc *TaskController) TasksActivate(c *gin.Context) {
 	formData := &models.ActivateTaskFormData{}
 	if err := c.Bind(formData); err != nil {
 		return
 	}
 
-	scheduler := tc.readSchedulerData()
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
+		return
+	}
+	before := taskSnapshots(scheduler.Tasks)
 
 	activatedTime := time.Now()
-	tc.updateTaskActivationByIds(scheduler.Tasks, formData.TaskIds, &activatedTime)
+	affectedTasks := tc.updateTaskActivationByIds(author, scheduler.Tasks, formData.TaskIds, &activatedTime)
 
-	err := tc.writeSchedulerData(scheduler)
-	if err != nil {
+	if err := tc.updateTasks(author, affectedTasks); err != nil {
 		LogError(err, "Could not write scheduler data", c)
+	} else {
+		tc.auditTasks(c, author, models.AuditActivate, before, affectedTasks)
 	}
 
-	viewTasks := models.GetViewTasks(scheduler.Tasks)
-	c.HTML(http.StatusOK, "tasks/table-body", models.TasksUpdateData{
-		Tasks: viewTasks,
-	})
+	tc.renderTasksBody(c, scheduler.Tasks)
 }
 
 func (tc *TaskController) TasksDeactivate(c *gin.Context) {
@@ -231,20 +573,97 @@ func (tc *TaskController) TasksDeactivate(c *gin.Context) {
 		return
 	}
 
-	scheduler := tc.readSchedulerData()
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
+		return
+	}
+	before := taskSnapshots(scheduler.Tasks)
 
-	tc.updateTaskActivationByIds(scheduler.Tasks, formData.TaskIds, nil)
+	affectedTasks := tc.updateTaskActivationByIds(author, scheduler.Tasks, formData.TaskIds, nil)
 
-	err := tc.writeSchedulerData(scheduler)
-	if err != nil {
+	if err := tc.updateTasks(author, affectedTasks); err != nil {
 		LogError(err, "Could not write scheduler data", c)
+	} else {
+		tc.auditTasks(c, author, models.AuditDeactivate, before, affectedTasks)
 	}
 
-	viewTasks := models.GetViewTasks(scheduler.Tasks)
+	tc.renderTasksBody(c, scheduler.Tasks)
+}
 
-	c.HTML(http.StatusOK, "tasks/table-body", models.TasksUpdateData{
-		Tasks: viewTasks,
-	})
+// TasksPause freezes the remaining time of the selected active tasks and stops their timers
+func (tc *TaskController) TasksPause(c *gin.Context) {
+	formData := &models.PauseTaskFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+	log.Debug().Strs("taskIds", formData.TaskIds).Msg("Pausing tasks")
+
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
+		return
+	}
+	before := taskSnapshots(scheduler.Tasks)
+
+	var changedTasks []*models.Task
+	for _, taskId := range formData.TaskIds {
+		task := scheduler.FindTask(taskId)
+		if task != nil && task.Pause() {
+			tc.UnregisterTask(author, task)
+			changedTasks = append(changedTasks, task)
+		}
+	}
+
+	if err := tc.updateTasks(author, changedTasks); err != nil {
+		LogError(err, "Could not write scheduler data", c)
+	} else {
+		tc.auditTasks(c, author, models.AuditPause, before, changedTasks)
+	}
+
+	tc.renderTasksBody(c, scheduler.Tasks)
+}
+
+// TasksResume continues the selected paused tasks from their frozen remaining time
+func (tc *TaskController) TasksResume(c *gin.Context) {
+	formData := &models.PauseTaskFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+	log.Debug().Strs("taskIds", formData.TaskIds).Msg("Resuming tasks")
+
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
+		return
+	}
+	before := taskSnapshots(scheduler.Tasks)
+
+	var changedTasks []*models.Task
+	for _, taskId := range formData.TaskIds {
+		task := scheduler.FindTask(taskId)
+		if task != nil && task.Resume() {
+			tc.RegisterTaskSchedule(author, task)
+			changedTasks = append(changedTasks, task)
+		}
+	}
+
+	if err := tc.updateTasks(author, changedTasks); err != nil {
+		LogError(err, "Could not write scheduler data", c)
+	} else {
+		tc.auditTasks(c, author, models.AuditResume, before, changedTasks)
+	}
+
+	tc.renderTasksBody(c, scheduler.Tasks)
 }
 
 func (tc *TaskController) TasksDelete(c *gin.Context) {
@@ -254,92 +673,174 @@ func (tc *TaskController) TasksDelete(c *gin.Context) {
 	}
 	log.Debug().Strs("taskIds", formData.TaskIds).Msg("Deleting tasks")
 
-	scheduler := tc.readSchedulerData()
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
+		return
+	}
 
-	tc.updateTaskActivationByIds(scheduler.Tasks, formData.TaskIds, nil)
+	// the tasks go to the trash as they are, so an undo picks up their timers where they were
+	deleted := make([]*models.Task, 0, len(formData.TaskIds))
+	for _, taskId := range formData.TaskIds {
+		if task := scheduler.FindTask(taskId); task != nil {
+			tc.UnregisterTask(author, task)
+			deleted = append(deleted, task)
+		}
+	}
 
-	updatedSchedule, err := tc.taskDBM.DeleteTasks(formData.TaskIds)
+	updatedSchedule, err := tc.store.DeleteTasks(author, formData.TaskIds)
 	if err != nil {
 		LogError(err, "Could not write scheduler data", c)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
 	}
 	log.Info().Strs("taskIds", formData.TaskIds).Msg("Deleted tasks")
+	actor := requestActor(c)
+	for _, task := range deleted {
+		tc.recordAudit(author, actor, models.AuditDelete, task, nil)
+	}
 
-	viewTasks := models.GetViewTasks(updatedSchedule.Tasks)
-
-	c.HTML(http.StatusOK, "tasks/table-body", models.TasksUpdateData{
-		Tasks: viewTasks,
-	})
+	if len(deleted) > 0 {
+		tc.sendTasksDeleted(author, deleted, CurrentUser(c).Id)
+		tc.sendTasksUpdate(author)
+	}
+	tc.renderTasksBody(c, updatedSchedule.Tasks)
 }
 
+// TaskDone acknowledges an alert and starts the tasks chained to the task being done.
+// Viewers of a team schedule receive the alert too, they can dismiss it but don't start anything.
 func (tc *TaskController) TaskDone(c *gin.Context) {
 	taskId := c.Param("id")
 	log.Debug().Str("taskId", taskId).Msg("Task done")
 
-	tc.sc.Message <- &Event{
-		Message: nil,
-		Type:    EVENT_TASKS_UPDATE,
+	author, ok := tc.authorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+	if !currentSchedule(c).Role.Includes(models.RoleEditor) {
+		c.String(http.StatusOK, "")
+		return
+	}
+	// the alert of a deleted task is dismissed all the same
+	if err := tc.markTaskDone(author, requestActor(c), taskId); err != nil && !errors.Is(err, errTaskNotFound) {
+		LogError(err, "Could not mark task as done", c)
+		c.String(http.StatusInternalServerError, "")
+		return
 	}
 	c.String(http.StatusOK, "")
 }
 
+// TaskSnooze lets the task of an alert fire again after the DefaultSnooze
 func (tc *TaskController) TaskSnooze(c *gin.Context) {
 	taskId := c.Param("id")
 	log.Debug().Str("taskId", taskId).Msg("Task snoozing")
 
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	if _, err := tc.snoozeTask(author, requestActor(c), taskId, "", models.DefaultSnooze); err != nil {
+		if errors.Is(err, errTaskNotFound) {
+			c.String(http.StatusNotFound, "")
+			return
+		}
+		LogError(err, "Could not snooze task", c)
+		c.String(http.StatusInternalServerError, "")
+		return
+	}
+	c.String(http.StatusOK, "")
 }
 
-func (tc *TaskController) readSchedulerData() *models.Scheduler {
-	var scheduler models.Scheduler
+// recordOccurrence adds an entry to the history of the task, failures are logged but don't affect the alert
+func (tc *TaskController) recordOccurrence(author string, task *models.Task, event models.OccurrenceEvent, userId string) {
+	if err := tc.store.InsertOccurrence(models.NewTaskOccurrence(author, task, event, userId)); err != nil {
+		log.Warn().Err(err).Str("task", task.Name).Str("event", string(event)).Msg("Could not record task occurrence")
+	}
+}
 
-	if useDB {
-		scheduler, err := tc.taskDBM.GetScheduleByAuthor()
-		if err != nil {
-			log.Error().Msg("Could not query schedule")
-		}
-		return scheduler
-	} else {
-		if err := utils.ParseJSONFile("schedule.json", &scheduler); err != nil {
-			log.Fatal().Err(err)
-		}
+func (tc *TaskController) sendTasksUpdate(author string) {
+	tc.sc.Message <- &Event{
+		Message: nil,
+		Type:    EVENT_TASKS_UPDATE,
+		Owner:   author,
 	}
+}
 
-	return &scheduler
+// renderTasksBody renders the task table body with the filter of the requesting page applied
+func (tc *TaskController) renderTasksBody(c *gin.Context, tasks []*models.Task) {
+	filter := &models.TaskFilter{}
+	_ = c.ShouldBind(filter)
+	if err := filter.Validate(); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.HTML(http.StatusOK, "tasks/table-body", models.TasksUpdateData{
+		Tasks: models.GetFilteredViewTasks(tasks, filter),
+	})
 }
 
-func (tc *TaskController) writeSchedulerData(scheduler *models.Scheduler) error {
+func (tc *TaskController) readSchedulerData(author string) (*models.Scheduler, error) {
+	return tc.store.GetScheduleByAuthor(author)
+}
 
-	if useDB {
-		err := tc.taskDBM.ReplaceSchedule(scheduler)
-		if err != nil {
-			return err
-		}
-		return nil
-	} else {
-		jsonData, err := json.MarshalIndent(scheduler, "", "    ")
-		if err != nil {
-			return err
-		}
-		return os.WriteFile("schedule.json", jsonData, 0644)
+// readSchedule reads the schedule of an HTML request, a failing store is answered with 500
+func (tc *TaskController) readSchedule(c *gin.Context, author string) (*models.Scheduler, bool) {
+	scheduler, err := tc.readSchedulerData(author)
+	if err != nil {
+		LogError(err, "Could not read scheduler data", c)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return nil, false
 	}
+	return scheduler, true
 }
 
-func (tc *TaskController) writeTasksData(tasks []*models.Task) error {
-	scheduler := tc.readSchedulerData()
-	scheduler.Tasks = tasks
-	return tc.writeSchedulerData(scheduler)
+func (tc *TaskController) readAllSchedulerData() []*models.Scheduler {
+	schedules, err := tc.store.GetAllSchedules()
+	if err != nil {
+		log.Error().Err(err).Msg("Could not query schedules")
+	}
+	return schedules
+}
+
+// updateTasks writes each of the tasks on its own, so other tasks of the schedule changed meanwhile are kept
+func (tc *TaskController) updateTasks(author string, tasks []*models.Task) error {
+	for _, task := range tasks {
+		if _, err := tc.store.UpdateTask(author, task); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (tc *TaskController) getTasks() []*models.Task {
-	scheduler := tc.readSchedulerData()
+func (tc *TaskController) getTasks(author string) ([]*models.Task, error) {
+	scheduler, err := tc.readSchedulerData(author)
+	if err != nil {
+		return nil, err
+	}
 	tasks := scheduler.Tasks
 
 	models.SortTasks(tasks)
 
-	return tasks
+	return tasks, nil
+}
+
+// readTasks reads the sorted tasks of an HTML request, a failing store is answered with 500
+func (tc *TaskController) readTasks(c *gin.Context, author string) ([]*models.Task, bool) {
+	scheduler, ok := tc.readSchedule(c, author)
+	if !ok {
+		return nil, false
+	}
+	models.SortTasks(scheduler.Tasks)
+	return scheduler.Tasks, true
 }
 
 func (tc *TaskController) insertNewTask(author string, newTask *models.Task) error {
-	_, err := tc.taskDBM.InsertTask(author, newTask)
+	_, err := tc.store.InsertTask(author, newTask)
 	if err != nil {
 		return err
 	}
@@ -347,9 +848,9 @@ func (tc *TaskController) insertNewTask(author string, newTask *models.Task) err
 }
 
 // updateTaskActivationByIds searches through the provided task array, either sets ActivatedTime & registers the task (activate)
-// or unsets ActivatedTime & unregisters the task (de-activate / delete)
+// or unsets ActivatedTime & unregisters the task (de-activate / delete). Either way a paused task loses its frozen time.
 // it will sort the tasks afterwards and returns a new array of affected tasks
-func (tc *TaskController) updateTaskActivationByIds(tasks []*models.Task, taskIds []string, activatedTime *time.Time) []*models.Task {
+func (tc *TaskController) updateTaskActivationByIds(author string, tasks []*models.Task, taskIds []string, activatedTime *time.Time) []*models.Task {
 	taskIDSet := make(map[string]bool)
 	for _, id := range taskIds {
 		taskIDSet[id] = true
@@ -360,11 +861,12 @@ func (tc *TaskController) updateTaskActivationByIds(tasks []*models.Task, taskId
 		if taskIDSet[task.Id] {
 			affectedTasks = append(affectedTasks, task)
 			task.ActivatedTime = activatedTime
+			task.PausedRemaining = nil
 
 			if activatedTime == nil {
-				tc.UnregisterTask(task)
+				tc.UnregisterTask(author, task)
 			} else {
-				tc.RegisterTaskSchedule(task)
+				tc.RegisterTaskSchedule(author, task)
 			}
 		}
 	}
@@ -374,10 +876,19 @@ func (tc *TaskController) updateTaskActivationByIds(tasks []*models.Task, taskId
 	return affectedTasks
 }
 
-func checkExpiredTasks(tasks []*models.Task) {
+// registryKey scopes the live timer of a task to its author
+func registryKey(author string, taskId string) string {
+	return author + "/" + taskId
+}
+
+// checkExpiredTasks resets the activated time of tasks whose timer ran out and returns them
+func checkExpiredTasks(tasks []*models.Task) []*models.Task {
+	var expired []*models.Task
 	for _, task := range tasks {
-		if !task.IsActive() {
+		if task.ActivatedTime != nil && !task.IsActive() {
 			task.ActivatedTime = nil
+			expired = append(expired, task)
 		}
 	}
+	return expired
 }
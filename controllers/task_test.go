@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"scheduler/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTaskController_OtherSchedules(t *testing.T) {
+	store := models.NewMemoryStore()
+	tc := NewTaskController(NewStreamController(), nil, store, store, store)
+
+	if _, err := store.InsertTask("bob", &models.Task{Id: "tea", Name: "Tea", Schedule: "in 10min", Trigger: models.Popup}); err != nil {
+		t.Fatalf("InsertTask failed: %v", err)
+	}
+	team := &models.Team{Id: "kitchen", Name: "Kitchen", Members: []*models.TeamMember{{UserId: "bob", Name: "bob", Role: models.RoleAdmin}}}
+	if _, err := store.InsertTeam(team); err != nil {
+		t.Fatalf("InsertTeam failed: %v", err)
+	}
+	if _, err := store.InsertTask(team.Id, &models.Task{Id: "soup", Name: "Soup", Schedule: "in 10min", Trigger: models.Popup}); err != nil {
+		t.Fatalf("InsertTask failed: %v", err)
+	}
+
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New("").Parse(`{{define "tasks/table-body"}}{{range .Tasks}}{{.Name}};{{end}}{{end}}{{define "tasks/edit-row"}}{{.Error}}{{end}}`)))
+	authorized := router.Group("", func(c *gin.Context) { c.Set(userContextKey, &models.User{Id: "ann", Name: "ann"}) })
+	tc.RegisterApiRoutes(authorized, authorized, authorized)
+	authorized.PUT("/tasks/:id", tc.UpdateTask)
+	authorized.PUT("/tasks/:id/done", tc.TaskDone)
+	authorized.PUT("/tasks/:id/snooze", tc.TaskSnooze)
+
+	do := func(method string, path string, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	edit := url.Values{"task-name": {"Coffee"}, "task-schedule": {"in 1min"}, "task-trigger": {"audio"}}.Encode()
+	requests := []struct {
+		method string
+		path   string
+		body   string
+		header []string
+	}{
+		{http.MethodGet, "/api/v1/tasks/tea", "", nil},
+		{http.MethodPut, "/api/v1/tasks/tea", `{"name":"Coffee","schedule":"in 1min","trigger":"audio"}`, nil},
+		{http.MethodPost, "/api/v1/tasks/tea/activate", "", nil},
+		{http.MethodPost, "/api/v1/tasks/tea/snooze", `{"duration":"10min"}`, nil},
+		{http.MethodGet, "/api/v1/tasks/tea/history", "", nil},
+		{http.MethodDelete, "/api/v1/tasks/tea", "", nil},
+		{http.MethodPut, "/tasks/tea", edit, []string{"Content-Type", "application/x-www-form-urlencoded"}},
+		{http.MethodPut, "/tasks/tea/snooze", "", nil},
+		{http.MethodGet, "/api/v1/tasks/soup", "", []string{scheduleHeaderName, team.Id}},
+		{http.MethodPost, "/api/v1/tasks/soup/activate", "", []string{scheduleHeaderName, team.Id}},
+		{http.MethodDelete, "/api/v1/tasks/soup", "", []string{scheduleHeaderName, team.Id}},
+		{http.MethodGet, "/api/v1/tasks?schedule=" + team.Id, "", nil},
+	}
+	for _, request := range requests {
+		res := do(request.method, request.path, request.body, request.header...)
+		if res.Code != http.StatusNotFound {
+			t.Errorf("%s %s of another schedule was incorrect, got: %v, want: %v.", request.method, request.path, res.Code, http.StatusNotFound)
+		}
+	}
+
+	// done is answered anyway, so a stale alert can always be dismissed
+	if res := do(http.MethodPut, "/tasks/tea/done", ""); res.Code != http.StatusOK {
+		t.Errorf("Done of another schedule was incorrect, got: %v, want: %v.", res.Code, http.StatusOK)
+	}
+
+	res := do(http.MethodGet, "/api/v1/tasks", "")
+	page := models.ApiPage[*models.TaskResource]{}
+	if err := json.Unmarshal(res.Body.Bytes(), &page); err != nil || len(page.Data) != 0 {
+		t.Errorf("Own tasks were incorrect, got: %v %v, want: none.", res.Body.String(), err)
+	}
+
+	for owner, want := range map[string]string{"bob": "Tea", team.Id: "Soup"} {
+		scheduler, _ := store.GetScheduleByAuthor(owner)
+		if len(scheduler.Tasks) != 1 || scheduler.Tasks[0].Name != want || scheduler.Tasks[0].IsActive() {
+			t.Errorf("Schedule of %s was incorrect, got: %v, want: the inactive task %s.", owner, scheduler.Tasks, want)
+		}
+		if trash, _ := store.GetTrash(owner); len(trash) != 0 {
+			t.Errorf("Trash of %s was incorrect, got: %v, want: empty.", owner, trash)
+		}
+	}
+	if len(tc.taskRegistry) != 0 {
+		t.Errorf("Registered timers were incorrect, got: %v, want: none.", tc.taskRegistry)
+	}
+}
+
+func TestTaskController_AlertReachesOwner(t *testing.T) {
+	store := models.NewMemoryStore()
+	tc := NewTaskController(NewStreamController(), nil, store, store, store)
+
+	// activated a schedule ago, so the timer fires right away
+	activatedTime := time.Now().Add(-10 * time.Minute)
+	task := &models.Task{Id: "tea", Name: "Tea", Schedule: "in 10min", Trigger: models.Popup, ActivatedTime: &activatedTime}
+	if _, err := store.InsertTask("ann", task); err != nil {
+		t.Fatalf("InsertTask failed: %v", err)
+	}
+
+	owner, unsubscribeOwner := tc.sc.Subscribe("ann", []string{"ann"})
+	defer unsubscribeOwner()
+	other, unsubscribeOther := tc.sc.Subscribe("bob", []string{"bob"})
+	defer unsubscribeOther()
+
+	tc.RegisterTaskSchedule("ann", task)
+
+	select {
+	case event := <-owner:
+		if event.Type != EVENT_TASK_ALERT || event.Owner != "ann" {
+			t.Errorf("Event of the owner was incorrect, got: %v %v, want: an alert of ann.", event.Type, event.Owner)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Owner did not receive the alert")
+	}
+
+	select {
+	case event := <-other:
+		t.Errorf("Event of another user was incorrect, got: %v %v, want: none.", event.Type, event.Owner)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTaskController_UpdateKeepsRemainingTime(t *testing.T) {
+	store := models.NewMemoryStore()
+	tc := NewTaskController(NewStreamController(), nil, store, store, store)
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New("").Parse(`{{define "tasks/table-body"}}{{end}}{{define "tasks/edit-row"}}{{.Error}}{{end}}`)))
+	router.PUT("/tasks/:id", func(c *gin.Context) { c.Set(userContextKey, &models.User{Id: "ann", Name: "ann"}) }, tc.UpdateTask)
+
+	// almost due, the timer of the edited task has to fire on the old target time
+	activatedTime := time.Now().Add(-10*time.Minute + 200*time.Millisecond)
+	task := &models.Task{Id: "tea", Name: "Tea", Schedule: "in 10min", Trigger: models.Popup, ActivatedTime: &activatedTime}
+	if _, err := store.InsertTask("ann", task); err != nil {
+		t.Fatalf("InsertTask failed: %v", err)
+	}
+	tc.RegisterTaskSchedule("ann", task)
+
+	alerts, unsubscribe := tc.sc.Subscribe("ann", []string{"ann"})
+	defer unsubscribe()
+
+	rename := url.Values{"task-name": {"Green tea"}, "task-schedule": {"in 10min"}, "task-trigger": {"popup"}}.Encode()
+	req := httptest.NewRequest(http.MethodPut, "/tasks/tea", strings.NewReader(rename))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("Renaming was incorrect, got: %v, want: %v.", res.Code, http.StatusOK)
+	}
+	scheduler, _ := store.GetScheduleByAuthor("ann")
+	if edited := scheduler.FindTask("tea"); edited.ActivatedTime == nil || !edited.ActivatedTime.Equal(activatedTime) {
+		t.Errorf("ActivatedTime after renaming was incorrect, got: %v, want: %v.", edited.ActivatedTime, activatedTime)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for alerted := false; !alerted; {
+		select {
+		case event := <-alerts:
+			alerted = event.Type == EVENT_TASK_ALERT
+		case <-deadline:
+			t.Fatalf("Renamed task did not fire on its remaining time")
+		}
+	}
+}
+
+func TestTaskController_UpdateRestartsChangedSchedule(t *testing.T) {
+	store := models.NewMemoryStore()
+	tc := NewTaskController(NewStreamController(), nil, store, store, store)
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New("").Parse(`{{define "tasks/table-body"}}{{end}}{{define "tasks/edit-row"}}{{.Error}}{{end}}`)))
+	router.PUT("/tasks/:id", func(c *gin.Context) { c.Set(userContextKey, &models.User{Id: "ann", Name: "ann"}) }, tc.UpdateTask)
+
+	activatedTime := time.Now().Add(-4 * time.Minute)
+	task := &models.Task{Id: "tea", Name: "Tea", Schedule: "in 10min", Trigger: models.Popup, ActivatedTime: &activatedTime}
+	if _, err := store.InsertTask("ann", task); err != nil {
+		t.Fatalf("InsertTask failed: %v", err)
+	}
+	tc.RegisterTaskSchedule("ann", task)
+	defer tc.UnregisterTask("ann", task)
+
+	reschedule := url.Values{"task-name": {"Tea"}, "task-schedule": {"in 20min"}, "task-trigger": {"popup"}}.Encode()
+	req := httptest.NewRequest(http.MethodPut, "/tasks/tea", strings.NewReader(reschedule))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("Rescheduling was incorrect, got: %v, want: %v.", res.Code, http.StatusOK)
+	}
+
+	scheduler, _ := store.GetScheduleByAuthor("ann")
+	remaining := scheduler.FindTask("tea").GetRemainingTime()
+	if remaining == nil || *remaining < 19*time.Minute {
+		t.Errorf("Remaining time after rescheduling was incorrect, got: %v, want: about %v.", remaining, 20*time.Minute)
+	}
+	if _, registered := tc.taskRegistry[registryKey("ann", "tea")]; !registered {
+		t.Errorf("Timer after rescheduling was incorrect, got: none, want: a registered timer.")
+	}
+}
+
+// failingStore is a store whose backend is down for reading schedules
+type failingStore struct {
+	*models.MemoryStore
+}
+
+func (s failingStore) GetScheduleByAuthor(author string) (*models.Scheduler, error) {
+	return nil, errors.New("connection lost")
+}
+
+func TestTaskController_StoreFailure(t *testing.T) {
+	store := models.NewMemoryStore()
+	tc := NewTaskController(NewStreamController(), nil, failingStore{store}, store, store)
+
+	router := gin.New()
+	authorized := router.Group("", func(c *gin.Context) { c.Set(userContextKey, &models.User{Id: "ann", Name: "ann"}) })
+	authorized.GET("/tasks", tc.GetTasks)
+	authorized.GET("/tasks/body", tc.GetTasksBody)
+	authorized.GET("/tasks/:id", tc.GetTask)
+	authorized.GET("/tasks/:id/edit", tc.GetEditTaskForm)
+	authorized.PUT("/tasks/:id", tc.UpdateTask)
+	authorized.POST("/tasks/activate", tc.TasksActivate)
+	authorized.POST("/tasks/deactivate", tc.TasksDeactivate)
+	authorized.POST("/tasks/pause", tc.TasksPause)
+	authorized.POST("/tasks/resume", tc.TasksResume)
+	authorized.POST("/tasks/delete", tc.TasksDelete)
+	authorized.GET("/routines", tc.GetRoutines)
+	tc.RegisterApiRoutes(authorized, authorized, authorized)
+
+	selected := url.Values{"task-ids": {"tea"}}.Encode()
+	edit := url.Values{"task-name": {"Tea"}, "task-schedule": {"in 10min"}, "task-trigger": {"popup"}}.Encode()
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/tasks", ""},
+		{http.MethodGet, "/tasks/body", ""},
+		{http.MethodGet, "/tasks/tea", ""},
+		{http.MethodGet, "/tasks/tea/edit", ""},
+		{http.MethodPut, "/tasks/tea", edit},
+		{http.MethodPost, "/tasks/activate", selected},
+		{http.MethodPost, "/tasks/deactivate", selected},
+		{http.MethodPost, "/tasks/pause", selected},
+		{http.MethodPost, "/tasks/resume", selected},
+		{http.MethodPost, "/tasks/delete", selected},
+		{http.MethodGet, "/routines", ""},
+		{http.MethodGet, "/api/v1/tasks", ""},
+		{http.MethodGet, "/api/v1/tasks/tea", ""},
+	}
+	for _, request := range requests {
+		req := httptest.NewRequest(request.method, request.path, strings.NewReader(request.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		if res.Code != http.StatusInternalServerError {
+			t.Errorf("%s %s with a failing store was incorrect, got: %v, want: %v.", request.method, request.path, res.Code, http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"scheduler/models"
+	"scheduler/utils"
+	"strings"
+	"time"
+)
+
+// TeamController manages teams, their members and invitations. The schedules of teams are handled by the TaskController.
+type TeamController struct {
+	teamDBM models.TeamStore
+	userDBM models.UserStore
+}
+
+func NewTeamController(teamDBM models.TeamStore, userDBM models.UserStore) *TeamController {
+	return &TeamController{teamDBM: teamDBM, userDBM: userDBM}
+}
+
+func (tc *TeamController) GetTeams(c *gin.Context) {
+	pageData := tc.teamsPageData(c, "")
+	c.HTML(http.StatusOK, "pages/teams", pageData)
+}
+
+// NewTeam creates a team with the creating user as its admin
+func (tc *TeamController) NewTeam(c *gin.Context) {
+	formData := &models.NewTeamFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+
+	user := CurrentUser(c)
+	name := strings.TrimSpace(formData.Name)
+	if name == "" {
+		tc.renderTeams(c, "Name is required")
+		return
+	}
+
+	team := &models.Team{
+		Id:          utils.Uuid(),
+		Name:        name,
+		Members:     []*models.TeamMember{{UserId: user.Id, Name: user.Name, Role: models.RoleAdmin}},
+		Invitations: []*models.TeamInvitation{},
+		CreatedAt:   time.Now(),
+	}
+	if _, err := tc.teamDBM.InsertTeam(team); err != nil {
+		LogError(err, "Could not create team", c)
+		tc.renderTeams(c, "FAILED TO CREATE TEAM")
+		return
+	}
+
+	log.Info().Str("team", name).Str("user", user.Name).Msg("Created team")
+	tc.renderTeams(c, "")
+}
+
+// Invite invites a user by name, the user joins the team after accepting
+func (tc *TeamController) Invite(c *gin.Context) {
+	formData := &models.InviteFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+
+	team, ok := tc.authorizeTeam(c, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	role, err := models.ParseTeamRole(formData.Role)
+	if err != nil {
+		tc.renderTeams(c, "Unknown role")
+		return
+	}
+
+	invitee, err := tc.userDBM.GetUserByName(strings.TrimSpace(formData.Name))
+	if err != nil {
+		tc.renderTeams(c, "There is no user "+formData.Name)
+		return
+	}
+
+	invitation := &models.TeamInvitation{
+		Id:        utils.Uuid(),
+		UserId:    invitee.Id,
+		Name:      invitee.Name,
+		Role:      role,
+		InvitedBy: CurrentUser(c).Name,
+		CreatedAt: time.Now(),
+	}
+	if _, err := tc.teamDBM.AddInvitation(team.Id, invitation); err != nil {
+		if errors.Is(err, models.ErrAlreadyMember) {
+			tc.renderTeams(c, invitee.Name+" is already a member or invited")
+			return
+		}
+		LogError(err, "Could not invite user", c)
+		tc.renderTeams(c, "FAILED TO INVITE USER")
+		return
+	}
+
+	log.Info().Str("team", team.Name).Str("user", invitee.Name).Str("role", string(role)).Msg("Invited user")
+	tc.renderTeams(c, "")
+}
+
+// RevokeInvitation withdraws a pending invitation of the team
+func (tc *TeamController) RevokeInvitation(c *gin.Context) {
+	team, ok := tc.authorizeTeam(c, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	if _, err := tc.teamDBM.DeleteInvitation(team.Id, c.Param("invitationId")); err != nil {
+		LogError(err, "Could not revoke invitation", c)
+		tc.renderTeams(c, "FAILED TO REVOKE INVITATION")
+		return
+	}
+
+	tc.renderTeams(c, "")
+}
+
+func (tc *TeamController) AcceptInvitation(c *gin.Context) {
+	user := CurrentUser(c)
+
+	team, err := tc.teamDBM.AcceptInvitation(user.Id, c.Param("invitationId"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvitationNotFound) {
+			c.String(http.StatusNotFound, "")
+			return
+		}
+		LogError(err, "Could not accept invitation", c)
+		tc.renderTeams(c, "FAILED TO ACCEPT INVITATION")
+		return
+	}
+
+	log.Info().Str("team", team.Name).Str("user", user.Name).Msg("Joined team")
+	tc.renderTeams(c, "")
+}
+
+func (tc *TeamController) DeclineInvitation(c *gin.Context) {
+	user := CurrentUser(c)
+	invitationId := c.Param("invitationId")
+
+	team, err := tc.teamDBM.FindInvitationTeam(user.Id, invitationId)
+	if err != nil {
+		c.String(http.StatusNotFound, "")
+		return
+	}
+
+	if _, err := tc.teamDBM.DeleteInvitation(team.Id, invitationId); err != nil {
+		LogError(err, "Could not decline invitation", c)
+		tc.renderTeams(c, "FAILED TO DECLINE INVITATION")
+		return
+	}
+
+	tc.renderTeams(c, "")
+}
+
+// UpdateMemberRole changes the role of a member, the last admin can't be demoted
+func (tc *TeamController) UpdateMemberRole(c *gin.Context) {
+	formData := &models.MemberRoleFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+
+	team, ok := tc.authorizeTeam(c, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	role, err := models.ParseTeamRole(formData.Role)
+	if err != nil {
+		tc.renderTeams(c, "Unknown role")
+		return
+	}
+
+	memberId := c.Param("userId")
+	if !team.CanChangeMember(memberId, role) {
+		tc.renderTeams(c, models.ErrLastAdmin.Error())
+		return
+	}
+
+	if _, err := tc.teamDBM.UpdateMemberRole(team.Id, memberId, role); err != nil {
+		LogError(err, "Could not update member role", c)
+		tc.renderTeams(c, "FAILED TO UPDATE ROLE")
+		return
+	}
+
+	tc.renderTeams(c, "")
+}
+
+// RemoveMember removes a member from the team. Admins remove others, every member can leave on their own.
+func (tc *TeamController) RemoveMember(c *gin.Context) {
+	memberId := c.Param("userId")
+
+	requiredRole := models.RoleAdmin
+	if memberId == CurrentUser(c).Id {
+		requiredRole = models.RoleViewer
+	}
+	team, ok := tc.authorizeTeam(c, requiredRole)
+	if !ok {
+		return
+	}
+
+	if !team.CanChangeMember(memberId, "") {
+		tc.renderTeams(c, models.ErrLastAdmin.Error())
+		return
+	}
+
+	if _, err := tc.teamDBM.RemoveMember(team.Id, memberId); err != nil {
+		LogError(err, "Could not remove member", c)
+		tc.renderTeams(c, "FAILED TO REMOVE MEMBER")
+		return
+	}
+
+	log.Info().Str("team", team.Name).Str("userId", memberId).Msg("Removed team member")
+	tc.renderTeams(c, "")
+}
+
+// authorizeTeam loads the team of the request and checks the user has at least the role in it.
+// On failure the response is written and ok is false.
+func (tc *TeamController) authorizeTeam(c *gin.Context, role models.TeamRole) (*models.Team, bool) {
+	team, err := tc.teamDBM.GetTeam(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			c.String(http.StatusNotFound, "")
+		} else {
+			LogError(err, "Could not read team", c)
+			c.String(http.StatusInternalServerError, "")
+		}
+		return nil, false
+	}
+
+	userRole := team.Role(CurrentUser(c).Id)
+	if !userRole.Includes(role) {
+		if userRole == "" {
+			c.String(http.StatusNotFound, "")
+		} else {
+			c.String(http.StatusForbidden, "")
+		}
+		return nil, false
+	}
+
+	return team, true
+}
+
+func (tc *TeamController) renderTeams(c *gin.Context, error string) {
+	c.HTML(http.StatusOK, "teams/list", tc.teamsPageData(c, error))
+}
+
+func (tc *TeamController) teamsPageData(c *gin.Context, error string) models.TeamsPageData {
+	user := CurrentUser(c)
+	pageData := models.TeamsPageData{PageData: GetPageData(c), Error: error}
+
+	teams, err := tc.teamDBM.GetTeamsByMember(user.Id)
+	if err != nil {
+		LogError(err, "Could not read teams", c)
+	}
+	pageData.Teams = teams
+
+	invitedTeams, err := tc.teamDBM.GetTeamsByInvitation(user.Id)
+	if err != nil {
+		LogError(err, "Could not read invitations", c)
+	}
+	for _, team := range invitedTeams {
+		for _, invitation := range team.Invitations {
+			if invitation.UserId == user.Id {
+				pageData.Invitations = append(pageData.Invitations, &models.TeamInvitationVM{
+					TeamInvitation: invitation,
+					TeamId:         team.Id,
+					TeamName:       team.Name,
+				})
+			}
+		}
+	}
+
+	return pageData
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"scheduler/models"
+	"strings"
+)
+
+// TokenController lets users create and revoke their personal API tokens
+type TokenController struct {
+	tokenDBM models.TokenStore
+}
+
+func NewTokenController(tokenDBM models.TokenStore) *TokenController {
+	return &TokenController{tokenDBM: tokenDBM}
+}
+
+func (tc *TokenController) GetTokens(c *gin.Context) {
+	tokens, err := tc.tokenDBM.GetTokensByUser(CurrentUser(c).Id)
+	if err != nil {
+		LogError(err, "Could not read tokens", c)
+		c.String(http.StatusInternalServerError, "")
+		return
+	}
+
+	c.HTML(http.StatusOK, "pages/tokens", models.TokensPageData{PageData: GetPageData(c), Tokens: tokens})
+}
+
+// NewToken creates a token and shows the plain token once
+func (tc *TokenController) NewToken(c *gin.Context) {
+	formData := &models.NewTokenFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+
+	user := CurrentUser(c)
+	pageData := models.TokensPageData{PageData: GetPageData(c)}
+
+	name := strings.TrimSpace(formData.Name)
+	scopes, err := models.ParseTokenScopes(formData.Scopes)
+	switch {
+	case name == "":
+		pageData.Error = "Name is required"
+	case err != nil:
+		pageData.Error = "Select at least one scope"
+	default:
+		if _, plainToken, err := tc.tokenDBM.CreateToken(user.Id, name, scopes); err != nil {
+			LogError(err, "Could not create token", c)
+			pageData.Error = "FAILED TO CREATE TOKEN"
+		} else {
+			log.Info().Str("user", user.Name).Str("token", name).Msg("Created API token")
+			pageData.NewToken = plainToken
+		}
+	}
+
+	tc.renderTokens(c, pageData)
+}
+
+func (tc *TokenController) DeleteToken(c *gin.Context) {
+	user := CurrentUser(c)
+	pageData := models.TokensPageData{PageData: GetPageData(c)}
+
+	if err := tc.tokenDBM.DeleteToken(user.Id, c.Param("id")); err != nil {
+		LogError(err, "Could not delete token", c)
+		pageData.Error = "FAILED TO REVOKE TOKEN"
+	} else {
+		log.Info().Str("user", user.Name).Str("tokenId", c.Param("id")).Msg("Revoked API token")
+	}
+
+	tc.renderTokens(c, pageData)
+}
+
+func (tc *TokenController) renderTokens(c *gin.Context, pageData models.TokensPageData) {
+	tokens, err := tc.tokenDBM.GetTokensByUser(CurrentUser(c).Id)
+	if err != nil {
+		LogError(err, "Could not read tokens", c)
+	}
+	pageData.Tokens = tokens
+
+	c.HTML(http.StatusOK, "tokens/list", pageData)
+}
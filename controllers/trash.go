@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"scheduler/models"
+	"scheduler/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// GetTrash renders the deleted tasks of the schedule that can still be restored
+func (tc *TaskController) GetTrash(c *gin.Context) {
+	author, ok := tc.authorizeSchedule(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	trash, err := tc.store.GetTrash(author)
+	if err != nil {
+		LogError(err, "Could not read trash", c)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	tasks := make([]*models.DeletedTaskVM, 0, len(trash))
+	for _, deleted := range trash {
+		tasks = append(tasks, &models.DeletedTaskVM{
+			Task:      deleted.Task.ToTaskVM(),
+			DeletedAt: deleted.DeletedAt,
+			PurgeAt:   deleted.DeletedAt.Add(tc.trashRetention),
+		})
+	}
+	access := currentSchedule(c)
+
+	c.HTML(http.StatusOK, "pages/trash", models.TrashPageData{
+		PageData: GetPageData(c),
+		Tasks:    tasks,
+		Team:     access.Team,
+		CanEdit:  access.Role.Includes(models.RoleEditor),
+	})
+}
+
+// TasksRestore takes tasks back from the trash, for the trash view and the undo toast which both remove their element
+func (tc *TaskController) TasksRestore(c *gin.Context) {
+	formData := &models.RestoreTaskFormData{}
+	if err := c.Bind(formData); err != nil {
+		return
+	}
+
+	author, ok := tc.authorizeSchedule(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	restored, err := tc.restoreTasks(author, requestActor(c), formData.TaskIds)
+	if err != nil {
+		LogError(err, "Could not restore tasks", c)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	log.Info().Int("restored", len(restored)).Strs("taskIds", formData.TaskIds).Msg("Restored tasks")
+
+	c.String(http.StatusOK, "")
+}
+
+// restoreTasks takes the tasks back from the trash and restarts the timers of the ones still running.
+// Tasks whose id is in use again stay in the trash, only the restored tasks are returned.
+func (tc *TaskController) restoreTasks(author string, actor *models.AuditActor, taskIds []string) ([]*models.Task, error) {
+	tc.apiMu.Lock()
+	defer tc.apiMu.Unlock()
+
+	scheduler, err := tc.store.GetScheduleByAuthor(author)
+	if err != nil {
+		return nil, err
+	}
+	taken := map[string]bool{}
+	for _, task := range scheduler.Tasks {
+		taken[task.Id] = true
+	}
+
+	if scheduler, err = tc.store.RestoreTasks(author, taskIds); err != nil {
+		return nil, err
+	}
+
+	restored := []*models.Task{}
+	for _, taskId := range taskIds {
+		task := scheduler.FindTask(taskId)
+		if task == nil || taken[taskId] {
+			continue
+		}
+		restored = append(restored, task)
+		tc.recordAudit(author, actor, models.AuditRestore, nil, task)
+
+		if task.IsActive() {
+			tc.RegisterTaskSchedule(author, task)
+		} else if task.ActivatedTime != nil {
+			// the timer ran out while the task was in the trash
+			tc.expireTask(author, task)
+		}
+	}
+
+	if len(restored) > 0 {
+		tc.sendTasksUpdate(author)
+	}
+	return restored, nil
+}
+
+// restoreTask restores a single task for the API
+func (tc *TaskController) restoreTask(author string, actor *models.AuditActor, taskId string) (*models.Task, error) {
+	restored, err := tc.restoreTasks(author, actor, []string{taskId})
+	if err != nil {
+		return nil, err
+	}
+	if len(restored) > 0 {
+		return restored[0], nil
+	}
+
+	// the task is either not in the trash or stayed there because its id is taken
+	trash, err := tc.store.GetTrash(author)
+	if err != nil {
+		return nil, err
+	}
+	for _, deleted := range trash {
+		if deleted.Task.Id == taskId {
+			return nil, fmt.Errorf("%w: a task with the id %s exists, delete it before restoring", errTaskState, taskId)
+		}
+	}
+	return nil, errTaskNotFound
+}
+
+// sendTasksDeleted offers the user who deleted the tasks to undo it
+func (tc *TaskController) sendTasksDeleted(author string, tasks []*models.Task, userId string) {
+	tc.sc.Message <- &Event{
+		Message:    tasks,
+		Type:       EVENT_TASKS_DELETED,
+		Owner:      author,
+		Recipients: []string{userId},
+	}
+}
+
+func (tc *TaskController) GetUndoToastTpl(owner string, tasks []*models.Task) string {
+	toastTpl, _ := utils.RenderTemplate(tc.template, "alerts/undo", models.UndoToastData{Tasks: tasks, Schedule: owner})
+
+	return toastTpl
+}
+
+// StartTrashPurge deletes the tasks that have been in the trash longer than the retention, once an hour
+func (tc *TaskController) StartTrashPurge(retention time.Duration) {
+	tc.trashRetention = retention
+	log.Info().Dur("retention", retention).Msg("Trash purge enabled")
+
+	go func() {
+		for ; ; time.Sleep(time.Hour) {
+			purged, err := tc.store.PurgeTrash(time.Now().Add(-retention))
+			if err != nil {
+				log.Error().Err(err).Msg("Could not purge trash")
+				continue
+			}
+			if purged > 0 {
+				log.Info().Int64("purged", purged).Msg("Purged trash")
+			}
+		}
+	}()
+}
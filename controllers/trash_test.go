@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"errors"
+	"scheduler/models"
+	"testing"
+	"time"
+)
+
+func TestRestoreTasks(t *testing.T) {
+	store := models.NewMemoryStore()
+	tc := NewTaskController(NewStreamController(), nil, store, store, store)
+
+	activatedTime := time.Now()
+	running := &models.Task{Id: "running", Name: "Running", Schedule: "in 5min", Trigger: models.Popup, ActivatedTime: &activatedTime}
+	// the timer of this task runs out while it's in the trash
+	longAgo := activatedTime.Add(-time.Hour)
+	expired := &models.Task{Id: "expired", Name: "Expired", Schedule: "in 5min", Trigger: models.Popup, ActivatedTime: &longAgo}
+	for _, task := range []*models.Task{running, expired} {
+		if _, err := store.InsertTask("u1", task); err != nil {
+			t.Fatalf("Inserting a task failed: %v", err)
+		}
+		if err := tc.deleteTask("u1", configActor, task.Id, ""); err != nil {
+			t.Fatalf("Deleting a task failed: %v", err)
+		}
+	}
+	defer tc.UnregisterTask("u1", running)
+
+	restored, err := tc.restoreTasks("u1", configActor, []string{"running", "expired", "missing"})
+	if err != nil || len(restored) != 2 {
+		t.Fatalf("Restored tasks were incorrect, got: %v %v, want: 2 tasks.", restored, err)
+	}
+	if _, registered := tc.taskRegistry[registryKey("u1", "running")]; !registered {
+		t.Errorf("Timer of the running task was incorrect, got: %v, want: %v.", registered, true)
+	}
+	scheduler, _ := tc.readSchedulerData("u1")
+	if task := scheduler.FindTask("expired"); task.ActivatedTime != nil {
+		t.Errorf("Activated time of the expired task was incorrect, got: %v, want: %v.", task.ActivatedTime, nil)
+	}
+
+	// a task created with the id of a deleted one keeps the deleted one in the trash
+	_ = tc.deleteTask("u1", configActor, "running", "")
+	if _, err := store.InsertTask("u1", &models.Task{Id: "running", Name: "New", Schedule: "in 5min", Trigger: models.Popup}); err != nil {
+		t.Fatalf("Inserting a task failed: %v", err)
+	}
+	if _, err := tc.restoreTask("u1", configActor, "running"); !errors.Is(err, errTaskState) {
+		t.Errorf("Restoring a taken id was incorrect, got: %v, want: %v.", err, errTaskState)
+	}
+	if _, err := tc.restoreTask("u1", configActor, "missing"); !errors.Is(err, errTaskNotFound) {
+		t.Errorf("Restoring a missing task was incorrect, got: %v, want: %v.", err, errTaskNotFound)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"github.com/getsentry/sentry-go"
 	sentrygin "github.com/getsentry/sentry-go/gin"
 	"github.com/gin-gonic/gin"
@@ -10,14 +11,17 @@ import (
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
 	"html/template"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"scheduler/controllers"
 	"scheduler/models"
 	"scheduler/utils"
+	"strings"
 	"time"
 )
 
@@ -32,6 +36,110 @@ func getPort() string {
 	return port
 }
 
+// getGrpcPort is the separate port of the gRPC service
+func getGrpcPort() string {
+	port := os.Getenv("GRPC_PORT")
+	if port == "" {
+		port = "localhost:50051"
+	} else {
+		port = ":" + port
+	}
+
+	return port
+}
+
+// migrateDatabase brings the documents to the schema of this build before anything reads them
+func migrateDatabase(client *mongo.Client) error {
+	applied, err := models.MigrationDBModel{Client: client}.Migrate(5 * time.Minute)
+	if err != nil {
+		return fmt.Errorf("could not migrate the database: %w", err)
+	}
+	for _, migration := range applied {
+		log.Info().Int("version", migration.Version).Msgf("Applied migration: %s", migration.Name)
+	}
+	return nil
+}
+
+// checkMigrated fails if the database has pending migrations, the maintenance commands don't migrate on their own
+func checkMigrated(client *mongo.Client) error {
+	pending, err := models.MigrationDBModel{Client: client}.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("the database has %d pending migrations, run \"scheduler migrate\" or start the server first", len(pending))
+	}
+	return nil
+}
+
+// getStoreConfig selects where the schedules and accounts are kept, STORE is mongo (default), file, sqlite or memory
+func getStoreConfig() models.StoreConfig {
+	kind := os.Getenv("STORE")
+	path := os.Getenv("STORE_PATH")
+	if path == "" && kind == models.StoreSqlite {
+		path = "schedules.db"
+	} else if path == "" {
+		path = "schedules.json"
+	}
+
+	return models.StoreConfig{Kind: kind, Path: path}
+}
+
+// openStore opens the store selected by STORE, MongoDB is only connected if it's the store. The server migrates it,
+// with migrate false a database with pending migrations is an error. The returned function closes the store again.
+func openStore(migrate bool) (models.Store, func(), error) {
+	config := getStoreConfig()
+	closers := []func(){}
+	closeStore := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	var mongoStore *models.MongoStore
+	if config.IsMongo() {
+		client, err := connectToMongo()
+		if err != nil {
+			return nil, nil, err
+		}
+		closers = append(closers, func() {
+			log.Info().Msg("Disconnecting mongo client")
+			if err := client.Disconnect(context.Background()); err != nil {
+				log.Error().Err(err).Msg("Error disconnecting from MongoDB")
+			}
+		})
+		if migrate {
+			err = migrateDatabase(client)
+		} else {
+			err = checkMigrated(client)
+		}
+		if err != nil {
+			closeStore()
+			return nil, nil, err
+		}
+
+		mongoStore = models.NewMongoStore(client)
+		if err := mongoStore.EnsureIndexes(); err != nil {
+			closeStore()
+			return nil, nil, err
+		}
+	}
+
+	store, err := models.NewStore(config, mongoStore)
+	if err != nil {
+		closeStore()
+		return nil, nil, err
+	}
+	if closer, ok := store.(io.Closer); ok {
+		closers = append(closers, func() {
+			if err := closer.Close(); err != nil {
+				log.Error().Err(err).Msg("Error closing the store")
+			}
+		})
+	}
+	return store, closeStore, nil
+}
+
 func initSentry() {
 	enabled := os.Getenv("SENTRY_ENABLED")
 	if enabled != "true" {
@@ -50,6 +158,36 @@ func initSentry() {
 	}
 }
 
+// getConfigInterval is how often SCHEDULE_CONFIG is checked for changes, SCHEDULE_CONFIG_INTERVAL like 30s
+func getConfigInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("SCHEDULE_CONFIG_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return 10 * time.Second
+	}
+	return interval
+}
+
+// getTrashRetention is how long deleted tasks stay in the trash, TRASH_RETENTION is a duration like 720h
+func getTrashRetention() time.Duration {
+	retention, err := time.ParseDuration(os.Getenv("TRASH_RETENTION"))
+	if err != nil || retention <= 0 {
+		return models.DefaultTrashRetention
+	}
+	return retention
+}
+
+// getTrustedProxies are the reverse proxies whose X-Forwarded-For is believed, TRUSTED_PROXIES is a comma separated
+// list of IPs or CIDRs. Without it the client IP is the address of the connection, so clients can't forge it.
+func getTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 type Templates struct {
 	templates *template.Template
 	funcMap   *template.FuncMap
@@ -105,9 +243,33 @@ func main() {
 
 	loadEnv()
 
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+	// MIGRATIONS=dry-run only shows what starting the server would migrate
+	if os.Getenv("MIGRATIONS") == "dry-run" && getStoreConfig().IsMongo() {
+		os.Exit(migrateCommand([]string{"--dry-run"}))
+	}
+
 	initSentry()
 
+	app, grpcServer, closeServer := newServer()
+	defer closeServer()
+
+	go serveGrpc(grpcServer)
+
+	err := app.Run(getPort())
+	if err != nil {
+		log.Panic().Err(err).Msgf("Could not run app on port: %s", getPort())
+	}
+}
+
+// newServer sets up the web app and the gRPC server on the store selected by STORE, the returned function closes the store
+func newServer() (*gin.Engine, *grpc.Server, func()) {
 	app := gin.Default()
+	if err := app.SetTrustedProxies(getTrustedProxies()); err != nil {
+		log.Fatal().Err(err).Msg("Invalid TRUSTED_PROXIES")
+	}
 
 	tpls, err := NewTemplates()
 	if err != nil {
@@ -119,49 +281,132 @@ func main() {
 
 	app.Use(sentrygin.New(sentrygin.Options{}))
 
-	client := connectToMongo()
-	// Defer the disconnection of the client
-	defer func() {
-		log.Info().Msg("Disconnecting mongo client")
-		if err := client.Disconnect(context.Background()); err != nil {
-			log.Error().Err(err).Msg("Error disconnecting from MongoDB")
-		}
-	}()
+	store, closeStore, err := openStore(true)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not open the store")
+	}
 
-	taskDB := models.TaskDBModel{Client: client}
+	authController := controllers.NewAuthController(store, store, store)
+	tokenController := controllers.NewTokenController(store)
+	teamController := controllers.NewTeamController(store, store)
 
 	streamController := controllers.NewStreamController()
 
-	taskController := controllers.NewTaskController(streamController, tpls.templates, &taskDB)
+	taskController := controllers.NewTaskController(streamController, tpls.templates, store, store, store)
 	taskController.RegisterAllTasksSchedules()
+	startBackups(store, getBackupConfig())
+	taskController.StartTrashPurge(getTrashRetention())
+	if path := os.Getenv("SCHEDULE_CONFIG"); path != "" {
+		if err := taskController.WatchConfig(path, getConfigInterval()); err != nil {
+			log.Fatal().Err(err).Str("path", path).Msg("Could not load the schedule config")
+		}
+	}
 	//	taskController.RegisterRefreshInterval()
 
 	app.Static("/static", "./static")
 	app.StaticFile("/alert.worker.js", "./static/js/alert.worker.js")
+	app.StaticFile("/api/openapi.json", "./static/openapi.json")
+	app.GET("/api/docs", controllers.GetApiDocs)
 	app.GET("/", func(c *gin.Context) {
 		c.Redirect(http.StatusMovedPermanently, "/tasks")
 	})
 
-	app.GET("/tasks", taskController.GetTasks)
-	app.GET("/tasks/new", taskController.GetNewTaskForm) // FOR HTMX
-	app.POST("/tasks/new", taskController.NewTask)
-	//	app.GET("/tasks-update", taskController.TasksUpdate) // FOR HTMX
-	app.PUT("/tasks/activate", taskController.TasksActivate)
-	app.PUT("/tasks/deactivate", taskController.TasksDeactivate)
-	app.PUT("/tasks/delete", taskController.TasksDelete)
-	app.PUT("/tasks/:id/done", taskController.TaskDone)
-	app.GET("/tasks/:id/snooze", taskController.TaskSnooze)
+	app.GET("/login", authController.GetLogin)
+	app.POST("/login", authController.Login)
+	app.GET("/register", authController.GetRegister)
+	app.POST("/register", authController.Register)
+
+	if oidcConfig := controllers.OIDCConfigFromEnv(); oidcConfig != nil {
+		oidcController, err := controllers.NewOIDCController(context.Background(), oidcConfig, store, authController)
+		if err != nil {
+			log.Error().Err(err).Msg("Could not set up OIDC login")
+		} else {
+			app.GET("/login/oidc", oidcController.Login)
+			app.GET("/login/oidc/callback", oidcController.Callback)
+		}
+	}
+
+	// everything below belongs to the logged-in user
+	authorized := app.Group("", authController.AuthMiddleware(), controllers.CSRFMiddleware())
 
-	app.GET("/stream", controllers.StreamHeadersMiddleware(), streamController.ServeHTTP(), func(c *gin.Context) {
+	authorized.POST("/logout", authController.Logout)
+
+	// API tokens are limited to their scopes, browser sessions may do everything
+	read := authorized.Group("", controllers.RequireScope(models.ScopeRead))
+	trigger := authorized.Group("", controllers.RequireScope(models.ScopeTrigger))
+	manage := authorized.Group("", controllers.RequireScope(models.ScopeManage))
+
+	read.GET("/tasks", controllers.ConditionalGetMiddleware(), taskController.GetTasks)
+	read.GET("/tasks/body", controllers.ConditionalGetMiddleware(), taskController.GetTasksBody) // FOR HTMX
+	read.GET("/tasks/new", taskController.GetNewTaskForm)                                        // FOR HTMX
+	manage.POST("/tasks/new", taskController.NewTask)
+	//	app.GET("/tasks-update", taskController.TasksUpdate) // FOR HTMX
+	trigger.PUT("/tasks/activate", taskController.TasksActivate)
+	trigger.PUT("/tasks/deactivate", taskController.TasksDeactivate)
+	trigger.PUT("/tasks/pause", taskController.TasksPause)
+	trigger.PUT("/tasks/resume", taskController.TasksResume)
+	manage.PUT("/tasks/delete", taskController.TasksDelete)
+	read.GET("/tasks/:id", taskController.GetTask)
+	manage.PUT("/tasks/:id", taskController.UpdateTask)
+	read.GET("/tasks/:id/edit", taskController.GetEditTaskForm) // FOR HTMX
+	trigger.PUT("/tasks/:id/done", taskController.TaskDone)
+	trigger.PUT("/tasks/:id/snooze", taskController.TaskSnooze)
+	trigger.PUT("/tasks/:id/claim", taskController.ClaimTask)
+	manage.PUT("/tasks/:id/assignees", taskController.AssignTask)
+
+	read.GET("/routines", taskController.GetRoutines) // FOR HTMX
+	manage.POST("/routines/new", taskController.NewRoutine)
+	trigger.PUT("/routines/:id/start", taskController.StartRoutine)
+	manage.DELETE("/routines/:id", taskController.DeleteRoutine)
+
+	read.GET("/trash", taskController.GetTrash)
+	manage.PUT("/trash/restore", taskController.TasksRestore)
+	read.GET("/audit", taskController.GetAudit)
+	read.GET("/audit/export", taskController.ExportAudit)
+
+	read.GET("/stream", controllers.StreamHeadersMiddleware(), streamController.ServeHTTP(taskController.ScheduleOwners), func(c *gin.Context) {
 		handleStream(c, taskController)
 	})
 
+	// JSON API, errors are answered with error objects, see controllers.abortRequest
+	taskController.RegisterApiRoutes(read, trigger, manage)
+
+	tokens := authorized.Group("/tokens", controllers.DenyApiTokens())
+	tokens.GET("", tokenController.GetTokens)
+	tokens.POST("", tokenController.NewToken)
+	tokens.DELETE("/:id", tokenController.DeleteToken)
+
+	account := authorized.Group("/account", controllers.DenyApiTokens())
+	account.GET("", authController.GetAccount)
+	account.PUT("/webhook", authController.UpdateWebhook)
+
+	teams := authorized.Group("/teams", controllers.DenyApiTokens())
+	teams.GET("", teamController.GetTeams)
+	teams.POST("", teamController.NewTeam)
+	teams.PUT("/invitations/:invitationId/accept", teamController.AcceptInvitation)
+	teams.DELETE("/invitations/:invitationId", teamController.DeclineInvitation)
+	teams.POST("/:id/invitations", teamController.Invite)
+	teams.DELETE("/:id/invitations/:invitationId", teamController.RevokeInvitation)
+	teams.PUT("/:id/members/:userId", teamController.UpdateMemberRole)
+	teams.DELETE("/:id/members/:userId", teamController.RemoveMember)
+
 	app.GET("/data", dataHandler)
-	err = app.Run(getPort())
+
+	grpcServer := controllers.NewGrpcServer(taskController, authController, streamController)
+
+	return app, grpcServer, closeStore
+}
+
+func serveGrpc(server *grpc.Server) {
+	listener, err := net.Listen("tcp", getGrpcPort())
 	if err != nil {
-		log.Panic().Err(err).Msgf("Could not run app on port: %d", getPort())
+		log.Error().Err(err).Msgf("Could not listen for gRPC on port: %s", getGrpcPort())
+		return
+	}
+	log.Info().Msgf("Serving gRPC on port: %s", getGrpcPort())
+	if err := server.Serve(listener); err != nil {
+		log.Error().Err(err).Msg("gRPC server stopped")
 	}
-
 }
 
 func loadEnv() {
@@ -196,7 +441,9 @@ func handleStream(c *gin.Context, taskController *controllers.TaskController) {
 			case controllers.EVENT_TASK_ALERT:
 				handleTaskAlertEvent(c, event, taskController)
 			case controllers.EVENT_TASKS_UPDATE:
-				handleTasksUpdateEvent(c, taskController)
+				handleTasksUpdateEvent(c)
+			case controllers.EVENT_TASKS_DELETED:
+				handleTasksDeletedEvent(c, event, taskController)
 			default:
 				// generic "message" event handler
 				c.SSEvent("message", event.Message)
@@ -210,7 +457,7 @@ func handleStream(c *gin.Context, taskController *controllers.TaskController) {
 
 func handleTaskAlertEvent(c *gin.Context, event *controllers.Event, taskController *controllers.TaskController) {
 	if task, isTask := event.Message.(*models.Task); isTask {
-		alertTpl := taskController.GetAlertTpl(task)
+		alertTpl := taskController.GetAlertTpl(controllers.CurrentUser(c), event.Owner, task)
 
 		eventName := "task-alert"
 		if task.Trigger == "audio" {
@@ -222,9 +469,18 @@ func handleTaskAlertEvent(c *gin.Context, event *controllers.Event, taskControll
 	}
 }
 
-func handleTasksUpdateEvent(c *gin.Context, taskController *controllers.TaskController) {
-	taskUpdateTpl := taskController.GetTasksUpdate()
-	c.SSEvent("tasks-update", taskUpdateTpl)
+// handleTasksUpdateEvent only notifies the page, it refetches the task list with its own filter applied
+func handleTasksUpdateEvent(c *gin.Context) {
+	c.SSEvent("tasks-update", "update")
+}
+
+// handleTasksDeletedEvent shows the undo toast to the user who deleted the tasks
+func handleTasksDeletedEvent(c *gin.Context, event *controllers.Event, taskController *controllers.TaskController) {
+	if tasks, isTasks := event.Message.([]*models.Task); isTasks {
+		c.SSEvent("tasks-deleted", taskController.GetUndoToastTpl(event.Owner, tasks))
+	} else {
+		log.Error().Msg("Event and message type dont match")
+	}
 }
 
 func dataHandler(c *gin.Context) {
@@ -237,7 +493,7 @@ func dataHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, &scheduler)
 }
 
-func connectToMongo() *mongo.Client {
+func connectToMongo() (*mongo.Client, error) {
 	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
 	mongoUri := os.Getenv("MONGO_URI")
 	opts := options.Client().ApplyURI(mongoUri).SetServerAPIOptions(serverAPI)
@@ -247,17 +503,17 @@ func connectToMongo() *mongo.Client {
 
 	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	err = client.Ping(ctx, nil)
 
 	if err != nil {
-		log.Fatal().Err(err).Msg("There was a problem connecting to your Atlas cluster. Check that the URI includes a valid username and password, and that your IP address has been added to the access list. Error: ")
-		panic(err)
+		_ = client.Disconnect(context.Background())
+		return nil, fmt.Errorf("there was a problem connecting to your Atlas cluster. Check that the URI includes a valid username and password, and that your IP address has been added to the access list: %w", err)
 	}
 
 	log.Info().Msg("Connected to MongoDB!")
 
-	return client
+	return client, nil
 }
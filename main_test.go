@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewServer_SqliteWithoutMongo(t *testing.T) {
+	t.Setenv("STORE", "sqlite")
+	t.Setenv("STORE_PATH", filepath.Join(t.TempDir(), "schedules.db"))
+	t.Setenv("MONGO_URI", "")
+	t.Setenv("REGISTRATION_ENABLED", "true")
+
+	app, _, closeServer := newServer()
+	defer closeServer()
+
+	form := url.Values{"name": {"ann"}, "password": {"correct horse"}, "password-confirm": {"correct horse"}}
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+	app.ServeHTTP(res, req)
+	if res.Code != http.StatusSeeOther {
+		t.Fatalf("Registering was incorrect, got: %v, want: %v.", res.Code, http.StatusSeeOther)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	for _, cookie := range res.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	res = httptest.NewRecorder()
+	app.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Errorf("Task page of the registered user was incorrect, got: %v, want: %v.", res.Code, http.StatusOK)
+	}
+}
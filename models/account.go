@@ -0,0 +1,81 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned for unknown users, sessions, tokens and teams. It's the error of the MongoDB driver,
+// so callers can check it the same way for every store.
+var ErrNotFound = mongo.ErrNoDocuments
+
+// UserStore keeps the user accounts. Registration and login on top of it are RegisterUser, Authenticate,
+// FindOrCreateUser and FindOrCreateOIDCUser.
+type UserStore interface {
+	GetUserById(id string) (*User, error)
+	GetUserByName(name string) (*User, error)
+	GetUserByOidcSubject(subject string) (*User, error)
+	// InsertUser fails with ErrUserExists if the id, the name or the OIDC subject is taken
+	InsertUser(user *User) (*User, error)
+	// ClaimLegacyUser gives the legacy user the name, password hash and OIDC subject of the claim.
+	// The legacy user is created if it doesn't exist, it can only be claimed once, after that ErrUserExists is returned.
+	ClaimLegacyUser(claim *User) (*User, error)
+	// UpdateWebhookUrl sets the personal webhook of the user, an empty url removes it
+	UpdateWebhookUrl(userId string, webhookUrl string) error
+}
+
+// SessionStore keeps the sessions of logged-in browsers
+type SessionStore interface {
+	// CreateSession stores a new session for the user, only the hash of the token is kept
+	CreateSession(userId string, token string, csrfToken string) (*Session, error)
+	// GetSession looks up an unexpired session by the plain token of the cookie
+	GetSession(token string) (*Session, error)
+	DeleteSession(token string) error
+}
+
+// TokenStore keeps the API tokens
+type TokenStore interface {
+	// CreateToken stores a new token for the user and returns it together with the plain token
+	CreateToken(userId string, name string, scopes []TokenScope) (*ApiToken, string, error)
+	// GetToken looks up a plain token and records its use
+	GetToken(plainToken string) (*ApiToken, error)
+	// GetTokensByUser returns the tokens of the user, the oldest first
+	GetTokensByUser(userId string) ([]*ApiToken, error)
+	// DeleteToken revokes a token, only the owning user can do so
+	DeleteToken(userId string, id string) error
+}
+
+// TeamStore keeps the teams with their members and invitations. The changes fail with ErrNotFound for unknown teams.
+type TeamStore interface {
+	InsertTeam(team *Team) (*Team, error)
+	GetTeam(id string) (*Team, error)
+	// GetTeamsByMember returns the teams of the user, sorted by name
+	GetTeamsByMember(userId string) ([]*Team, error)
+	// GetTeamsByInvitation returns the teams the user is invited to, sorted by name
+	GetTeamsByInvitation(userId string) ([]*Team, error)
+	// FindInvitationTeam returns the team the invitation of the user belongs to, ErrInvitationNotFound if there is none
+	FindInvitationTeam(userId string, invitationId string) (*Team, error)
+	// AddInvitation invites a user who is neither member nor invited yet, ErrAlreadyMember otherwise
+	AddInvitation(teamId string, invitation *TeamInvitation) (*Team, error)
+	// AcceptInvitation turns the invitation of the user into a membership with the invited role
+	AcceptInvitation(userId string, invitationId string) (*Team, error)
+	// DeleteInvitation removes an invitation, either declined by the invited user or revoked by an admin of the team
+	DeleteInvitation(teamId string, invitationId string) (*Team, error)
+	// UpdateMemberRole changes the role of a member, ErrNotFound if the user is no member
+	UpdateMemberRole(teamId string, userId string, role TeamRole) (*Team, error)
+	RemoveMember(teamId string, userId string) (*Team, error)
+}
+
+// AccountStore keeps everything about the users besides their schedules
+type AccountStore interface {
+	UserStore
+	SessionStore
+	TokenStore
+	TeamStore
+}
+
+// Store is everything the server persists. It's implemented by MongoStore, MemoryStore, FileStore and SqliteStore,
+// which all pass the conformance suites in store_test.go and account_test.go.
+type Store interface {
+	TaskStore
+	AccountStore
+}
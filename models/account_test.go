@@ -0,0 +1,284 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"scheduler/utils"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAccountStore_Conformance(t *testing.T) {
+	for kind, newStore := range storeFactories(t) {
+		t.Run(kind, func(t *testing.T) {
+			t.Run("Users", func(t *testing.T) { testStoreUsers(t, newStore(t)) })
+			t.Run("Sessions", func(t *testing.T) { testStoreSessions(t, newStore(t)) })
+			t.Run("Tokens", func(t *testing.T) { testStoreTokens(t, newStore(t)) })
+			t.Run("Teams", func(t *testing.T) { testStoreTeams(t, newStore(t)) })
+			if kind != StoreMongo {
+				// the legacy user is shared by all runs on the same MongoDB
+				t.Run("LegacyUser", func(t *testing.T) { testStoreLegacyUser(t, newStore(t)) })
+			}
+		})
+	}
+}
+
+// cleanupAccounts removes the documents of the test from the mongo store, the other stores are thrown away anyway
+func cleanupAccounts(t *testing.T, store Store, collection string, filter bson.M) {
+	if mongoStore, ok := store.(*MongoStore); ok {
+		t.Cleanup(func() {
+			_, _ = mongoStore.UserDBModel.Client.Database("SchedulerCluster").Collection(collection).DeleteMany(context.Background(), filter)
+		})
+	}
+}
+
+func testUserName(t *testing.T, store Store) string {
+	name := "store-test-" + utils.Uuid()
+	cleanupAccounts(t, store, "users", bson.M{"name": name})
+	return name
+}
+
+func testStoreUsers(t *testing.T, store Store) {
+	name := testUserName(t, store)
+
+	if _, err := store.GetUserByName(name); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUserByName of an unknown user was incorrect, got: %v, want: %v.", err, ErrNotFound)
+	}
+
+	user, err := RegisterUser(store, name, "secret", false)
+	if err != nil {
+		t.Fatalf("RegisterUser failed: %v", err)
+	}
+	if _, err := RegisterUser(store, name, "other", false); !errors.Is(err, ErrUserExists) {
+		t.Errorf("RegisterUser of a taken name was incorrect, got: %v, want: %v.", err, ErrUserExists)
+	}
+
+	if found, err := store.GetUserById(user.Id); err != nil || found.Name != name || found.PasswordHash == "" {
+		t.Errorf("GetUserById was incorrect, got: %v %v, want: the user with its password hash.", found, err)
+	}
+	if found, err := Authenticate(store, name, "secret"); err != nil || found.Id != user.Id {
+		t.Errorf("Authenticate was incorrect, got: %v %v, want: %v.", found, err, user.Id)
+	}
+	if _, err := Authenticate(store, name, "wrong"); err == nil {
+		t.Errorf("Authenticate with a wrong password was incorrect, got: %v, want: an error.", err)
+	}
+
+	if err := store.UpdateWebhookUrl(user.Id, "https://example.com/hook"); err != nil {
+		t.Fatalf("UpdateWebhookUrl failed: %v", err)
+	}
+	if found, _ := store.GetUserById(user.Id); found == nil || found.WebhookUrl != "https://example.com/hook" {
+		t.Errorf("WebhookUrl was incorrect, got: %v, want: %v.", found, "https://example.com/hook")
+	}
+	if err := store.UpdateWebhookUrl(user.Id, ""); err != nil {
+		t.Fatalf("UpdateWebhookUrl failed: %v", err)
+	}
+	if found, _ := store.GetUserById(user.Id); found == nil || found.WebhookUrl != "" {
+		t.Errorf("Removed WebhookUrl was incorrect, got: %v, want: empty.", found)
+	}
+
+	proxyName := testUserName(t, store)
+	proxyUser, err := FindOrCreateUser(store, proxyName)
+	if err != nil {
+		t.Fatalf("FindOrCreateUser failed: %v", err)
+	}
+	if found, err := FindOrCreateUser(store, proxyName); err != nil || found.Id != proxyUser.Id {
+		t.Errorf("FindOrCreateUser of an existing user was incorrect, got: %v %v, want: %v.", found, err, proxyUser.Id)
+	}
+
+	subject := "https://issuer|" + utils.Uuid()
+	oidcName := testUserName(t, store)
+	oidcUser, err := FindOrCreateOIDCUser(store, subject, oidcName, false)
+	if err != nil {
+		t.Fatalf("FindOrCreateOIDCUser failed: %v", err)
+	}
+	if found, err := store.GetUserByOidcSubject(subject); err != nil || found.Id != oidcUser.Id {
+		t.Errorf("GetUserByOidcSubject was incorrect, got: %v %v, want: %v.", found, err, oidcUser.Id)
+	}
+	if _, err := FindOrCreateOIDCUser(store, subject+"-other", oidcName, false); !errors.Is(err, ErrUserExists) {
+		t.Errorf("FindOrCreateOIDCUser of a taken name was incorrect, got: %v, want: %v.", err, ErrUserExists)
+	}
+}
+
+func testStoreLegacyUser(t *testing.T, store Store) {
+	claimed, err := RegisterUser(store, "ann", "secret", true)
+	if err != nil {
+		t.Fatalf("Claiming the legacy user failed: %v", err)
+	}
+	if claimed.Id != LegacyAuthor || claimed.Name != "ann" {
+		t.Errorf("Claimed user was incorrect, got: %v, want: %v named ann.", claimed, LegacyAuthor)
+	}
+	if _, err := FindOrCreateOIDCUser(store, "https://issuer|bob", "bob", true); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Claiming the legacy user twice was incorrect, got: %v, want: %v.", err, ErrUserExists)
+	}
+	if found, err := Authenticate(store, "ann", "secret"); err != nil || found.Id != LegacyAuthor {
+		t.Errorf("Authenticate as the legacy user was incorrect, got: %v %v, want: %v.", found, err, LegacyAuthor)
+	}
+}
+
+func testStoreSessions(t *testing.T, store Store) {
+	token := utils.RandomToken()
+	cleanupAccounts(t, store, "sessions", bson.M{"id": HashToken(token)})
+
+	session, err := store.CreateSession("user", token, "csrf")
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if session.Id == token {
+		t.Errorf("Session id was incorrect, got: the plain token, want: its hash.")
+	}
+
+	found, err := store.GetSession(token)
+	if err != nil || found.UserId != "user" || found.CsrfToken != "csrf" || !found.ExpiresAt.After(time.Now()) {
+		t.Errorf("GetSession was incorrect, got: %v %v, want: the session of user.", found, err)
+	}
+	if _, err := store.GetSession(session.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetSession by the hash was incorrect, got: %v, want: %v.", err, ErrNotFound)
+	}
+
+	if err := store.DeleteSession(token); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if _, err := store.GetSession(token); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetSession after logout was incorrect, got: %v, want: %v.", err, ErrNotFound)
+	}
+}
+
+func testStoreTokens(t *testing.T, store Store) {
+	userId := "store-test-" + utils.Uuid()
+	cleanupAccounts(t, store, "tokens", bson.M{"userId": userId})
+
+	first, plainToken, err := store.CreateToken(userId, "script", []TokenScope{ScopeRead, ScopeTrigger})
+	if err != nil {
+		t.Fatalf("CreateToken failed: %v", err)
+	}
+	second, _, err := store.CreateToken(userId, "backup", []TokenScope{ScopeRead})
+	if err != nil {
+		t.Fatalf("CreateToken failed: %v", err)
+	}
+
+	found, err := store.GetToken(plainToken)
+	if err != nil || found.Id != first.Id || found.UserId != userId || !found.HasScope(ScopeTrigger) {
+		t.Errorf("GetToken was incorrect, got: %v %v, want: %v.", found, err, first.Id)
+	}
+	if _, err := store.GetToken(plainToken + "x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetToken of an unknown token was incorrect, got: %v, want: %v.", err, ErrNotFound)
+	}
+
+	tokens, err := store.GetTokensByUser(userId)
+	if err != nil || len(tokens) != 2 || tokens[0].Id != first.Id || tokens[1].Id != second.Id {
+		t.Fatalf("GetTokensByUser was incorrect, got: %v %v, want: %v and %v.", tokens, err, first.Id, second.Id)
+	}
+	if tokens[0].LastUsedAt == nil || tokens[1].LastUsedAt != nil {
+		t.Errorf("LastUsedAt was incorrect, got: %v %v, want: only the used token.", tokens[0].LastUsedAt, tokens[1].LastUsedAt)
+	}
+
+	if err := store.DeleteToken("someone-else", first.Id); err != nil {
+		t.Fatalf("DeleteToken failed: %v", err)
+	}
+	if _, err := store.GetToken(plainToken); err != nil {
+		t.Errorf("Token deleted by another user was incorrect, got: %v, want: still valid.", err)
+	}
+	if err := store.DeleteToken(userId, first.Id); err != nil {
+		t.Fatalf("DeleteToken failed: %v", err)
+	}
+	if _, err := store.GetToken(plainToken); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetToken after revoking was incorrect, got: %v, want: %v.", err, ErrNotFound)
+	}
+}
+
+func testStoreTeams(t *testing.T, store Store) {
+	admin := "store-test-" + utils.Uuid()
+	invited := "store-test-" + utils.Uuid()
+	teamId := utils.Uuid()
+	cleanupAccounts(t, store, "teams", bson.M{"id": teamId})
+
+	team := &Team{Id: teamId, Name: "Kitchen", Members: []*TeamMember{{UserId: admin, Name: "ann", Role: RoleAdmin}},
+		Invitations: []*TeamInvitation{}, CreatedAt: time.Now()}
+	if _, err := store.InsertTeam(team); err != nil {
+		t.Fatalf("InsertTeam failed: %v", err)
+	}
+
+	if _, err := store.GetTeam(utils.Uuid()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTeam of an unknown team was incorrect, got: %v, want: %v.", err, ErrNotFound)
+	}
+	if teams, err := store.GetTeamsByMember(admin); err != nil || len(teams) != 1 || teams[0].Id != teamId {
+		t.Errorf("GetTeamsByMember was incorrect, got: %v %v, want: %v.", teams, err, teamId)
+	}
+
+	invitation := &TeamInvitation{Id: utils.Uuid(), UserId: invited, Name: "bob", Role: RoleEditor, InvitedBy: admin, CreatedAt: time.Now()}
+	if _, err := store.AddInvitation(teamId, invitation); err != nil {
+		t.Fatalf("AddInvitation failed: %v", err)
+	}
+	if _, err := store.AddInvitation(teamId, &TeamInvitation{Id: utils.Uuid(), UserId: invited, Role: RoleViewer}); !errors.Is(err, ErrAlreadyMember) {
+		t.Errorf("Inviting twice was incorrect, got: %v, want: %v.", err, ErrAlreadyMember)
+	}
+	if teams, err := store.GetTeamsByInvitation(invited); err != nil || len(teams) != 1 || teams[0].Id != teamId {
+		t.Errorf("GetTeamsByInvitation was incorrect, got: %v %v, want: %v.", teams, err, teamId)
+	}
+	if _, err := store.FindInvitationTeam(admin, invitation.Id); !errors.Is(err, ErrInvitationNotFound) {
+		t.Errorf("FindInvitationTeam of another user was incorrect, got: %v, want: %v.", err, ErrInvitationNotFound)
+	}
+
+	accepted, err := store.AcceptInvitation(invited, invitation.Id)
+	if err != nil {
+		t.Fatalf("AcceptInvitation failed: %v", err)
+	}
+	if accepted.Role(invited) != RoleEditor || len(accepted.Invitations) != 0 {
+		t.Errorf("Accepted team was incorrect, got: %v %v, want: an editor without invitations.", accepted.Role(invited), accepted.Invitations)
+	}
+	if _, err := store.AcceptInvitation(invited, invitation.Id); !errors.Is(err, ErrInvitationNotFound) {
+		t.Errorf("Accepting twice was incorrect, got: %v, want: %v.", err, ErrInvitationNotFound)
+	}
+
+	if updated, err := store.UpdateMemberRole(teamId, invited, RoleViewer); err != nil || updated.Role(invited) != RoleViewer {
+		t.Errorf("UpdateMemberRole was incorrect, got: %v, want: %v.", err, RoleViewer)
+	}
+	if _, err := store.UpdateMemberRole(teamId, "nobody", RoleViewer); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateMemberRole of a non-member was incorrect, got: %v, want: %v.", err, ErrNotFound)
+	}
+
+	if removed, err := store.RemoveMember(teamId, invited); err != nil || removed.Role(invited) != "" {
+		t.Errorf("RemoveMember was incorrect, got: %v, want: no role.", err)
+	}
+
+	revoked := &TeamInvitation{Id: utils.Uuid(), UserId: invited, Role: RoleViewer}
+	if _, err := store.AddInvitation(teamId, revoked); err != nil {
+		t.Fatalf("AddInvitation failed: %v", err)
+	}
+	if updated, err := store.DeleteInvitation(teamId, revoked.Id); err != nil || len(updated.Invitations) != 0 {
+		t.Errorf("DeleteInvitation was incorrect, got: %v, want: no invitations.", err)
+	}
+	if _, err := store.DeleteInvitation(utils.Uuid(), revoked.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteInvitation of an unknown team was incorrect, got: %v, want: %v.", err, ErrNotFound)
+	}
+}
+
+func TestFileStore_ReopenAccounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	user, err := RegisterUser(store, "ann", "secret", false)
+	if err != nil {
+		t.Fatalf("RegisterUser failed: %v", err)
+	}
+	_, plainToken, err := store.CreateToken(user.Id, "script", []TokenScope{ScopeRead})
+	if err != nil {
+		t.Fatalf("CreateToken failed: %v", err)
+	}
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Reopening the FileStore failed: %v", err)
+	}
+	if found, err := Authenticate(reopened, "ann", "secret"); err != nil || found.Id != user.Id {
+		t.Errorf("Authenticate after reopening was incorrect, got: %v %v, want: %v.", found, err, user.Id)
+	}
+	if token, err := reopened.GetToken(plainToken); err != nil || token.UserId != user.Id {
+		t.Errorf("GetToken after reopening was incorrect, got: %v %v, want: a token of %v.", token, err, user.Id)
+	}
+}
@@ -2,4 +2,20 @@ package models
 
 type AlertPopupData struct {
 	Task *TaskVM
+	// Schedule is the owner of the task's schedule, the alert actions are sent for it
+	Schedule string
+	// Members of the team schedule the task can be reassigned to, empty for the personal schedule
+	Members []*TeamMember
+	// UserId is the user the alert is shown to
+	UserId string
+}
+
+// IsAssignedTo tells if the alerted task is assigned to the user
+func (data AlertPopupData) IsAssignedTo(userId string) bool {
+	for _, assignee := range data.Task.Assignees {
+		if assignee.UserId == userId {
+			return true
+		}
+	}
+	return false
 }
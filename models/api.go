@@ -0,0 +1,156 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"scheduler/utils"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+var (
+	ErrInvalidTrigger  = errors.New("unknown task trigger")
+	ErrInvalidPriority = errors.New("priority must be -1, 0 or 1")
+)
+
+// ApiError is the error object of every failed API request
+type ApiError struct {
+	// Code is a stable, machine readable error code like "not_found"
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ApiErrorResponse struct {
+	Error *ApiError `json:"error"`
+}
+
+// PageParams are the pagination query parameters of list endpoints
+type PageParams struct {
+	Offset int `form:"offset"`
+	Limit  int `form:"limit"`
+}
+
+// Normalize clamps the parameters to the allowed range
+func (params *PageParams) Normalize() {
+	if params.Offset < 0 {
+		params.Offset = 0
+	}
+	if params.Limit <= 0 {
+		params.Limit = DefaultPageLimit
+	}
+	if params.Limit > MaxPageLimit {
+		params.Limit = MaxPageLimit
+	}
+}
+
+type Pagination struct {
+	Offset int   `json:"offset"`
+	Limit  int   `json:"limit"`
+	Total  int64 `json:"total"`
+}
+
+type ApiPage[T any] struct {
+	Data       []T        `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// Paginate cuts the page of the params out of all items
+func Paginate[T any](items []T, params PageParams) ApiPage[T] {
+	start := min(params.Offset, len(items))
+	end := min(start+params.Limit, len(items))
+
+	return ApiPage[T]{
+		Data:       items[start:end],
+		Pagination: Pagination{Offset: params.Offset, Limit: params.Limit, Total: int64(len(items))},
+	}
+}
+
+// TaskResource is the API representation of a task, the stored task plus its current state
+type TaskResource struct {
+	*Task
+	Active bool `json:"active"`
+	Paused bool `json:"paused"`
+	// RemainingSeconds is set for active and paused tasks
+	RemainingSeconds *int64 `json:"remainingSeconds,omitempty"`
+}
+
+func NewTaskResource(task *Task) *TaskResource {
+	resource := &TaskResource{Task: task, Active: task.IsActive(), Paused: task.IsPaused()}
+
+	var remaining *time.Duration
+	if resource.Active {
+		remaining = task.GetRemainingTime()
+	} else if resource.Paused {
+		remaining = task.PausedRemaining
+	}
+	if remaining != nil {
+		seconds := int64(remaining.Seconds())
+		resource.RemainingSeconds = &seconds
+	}
+
+	return resource
+}
+
+// TaskRequest is the body to create or replace a task
+type TaskRequest struct {
+	Name      string          `json:"name"`
+	Schedule  string          `json:"schedule"`
+	Trigger   TaskTrigger     `json:"trigger"`
+	Tags      []string        `json:"tags"`
+	Priority  TaskPriority    `json:"priority"`
+	DependsOn *TaskDependency `json:"dependsOn"`
+	// Assignees are user ids, only tasks of team schedules can be assigned
+	Assignees []string `json:"assignees"`
+}
+
+// Validate checks the fields that don't depend on the other tasks of the schedule
+func (request *TaskRequest) Validate() error {
+	if strings.TrimSpace(request.Name) == "" {
+		return errors.New("name is required")
+	}
+	if _, err := utils.ParseDuration(request.Schedule); err != nil {
+		return err
+	}
+	if request.Trigger != Popup && request.Trigger != Audio && request.Trigger != WebHook {
+		return ErrInvalidTrigger
+	}
+	if !request.Priority.IsValid() {
+		return ErrInvalidPriority
+	}
+	return nil
+}
+
+// Apply copies the request onto the task, the dependency and the assignees are set up separately
+func (request *TaskRequest) Apply(task *Task) {
+	task.Name = strings.TrimSpace(request.Name)
+	task.Schedule = request.Schedule
+	task.Trigger = request.Trigger
+	task.Tags = ParseTags(strings.Join(request.Tags, ","))
+	task.Priority = request.Priority
+}
+
+// AlertEventResource is the data of the events of the API alert stream
+type AlertEventResource struct {
+	// Schedule is the user or team id owning the schedule of the event
+	Schedule string        `json:"schedule"`
+	Task     *TaskResource `json:"task,omitempty"`
+}
+
+type SnoozeRequest struct {
+	// Duration like "10min", DefaultSnooze if empty
+	Duration string `json:"duration"`
+}
+
+// ETag is the entity tag of a value, derived from its JSON representation
+func ETag(value any) string {
+	data, _ := json.Marshal(value)
+	hash := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		params PageParams
+		want   int
+	}{
+		{PageParams{Offset: 0, Limit: 2}, 2},
+		{PageParams{Offset: 4, Limit: 2}, 1},
+		{PageParams{Offset: 10, Limit: 2}, 0},
+	}
+
+	for _, test := range tests {
+		page := Paginate(items, test.params)
+		if len(page.Data) != test.want {
+			t.Errorf("Paginate(%v) was incorrect, got: %v, want: %v.", test.params, len(page.Data), test.want)
+		}
+		if page.Pagination.Total != 5 {
+			t.Errorf("Paginate(%v) total was incorrect, got: %v, want: %v.", test.params, page.Pagination.Total, 5)
+		}
+	}
+}
+
+func TestPageParams_Normalize(t *testing.T) {
+	params := PageParams{Offset: -1, Limit: 1000}
+	params.Normalize()
+	if params.Offset != 0 || params.Limit != MaxPageLimit {
+		t.Errorf("Normalize was incorrect, got: %v, want: %v.", params, PageParams{Offset: 0, Limit: MaxPageLimit})
+	}
+
+	params = PageParams{}
+	params.Normalize()
+	if params.Limit != DefaultPageLimit {
+		t.Errorf("Normalize limit was incorrect, got: %v, want: %v.", params.Limit, DefaultPageLimit)
+	}
+}
+
+func TestETag(t *testing.T) {
+	task := &Task{Id: "1", Name: "Tea", Schedule: "in 5min"}
+	etag := ETag(task)
+
+	if etag != ETag(&Task{Id: "1", Name: "Tea", Schedule: "in 5min"}) {
+		t.Errorf("ETag of equal tasks was incorrect, got different tags, want: %v.", etag)
+	}
+
+	task.Name = "Coffee"
+	if etag == ETag(task) {
+		t.Errorf("ETag of changed task was incorrect, got: %v, want a different tag.", etag)
+	}
+}
+
+func TestTaskRequest_Validate(t *testing.T) {
+	tests := []struct {
+		request TaskRequest
+		valid   bool
+	}{
+		{TaskRequest{Name: "Tea", Schedule: "in 5min", Trigger: Popup}, true},
+		{TaskRequest{Name: " ", Schedule: "in 5min", Trigger: Popup}, false},
+		{TaskRequest{Name: "Tea", Schedule: "soon", Trigger: Popup}, false},
+		{TaskRequest{Name: "Tea", Schedule: "in 5min", Trigger: "smoke"}, false},
+		{TaskRequest{Name: "Tea", Schedule: "in 5min", Trigger: Audio, Priority: 5}, false},
+	}
+
+	for _, test := range tests {
+		err := test.request.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("Validate(%v) was incorrect, got: %v, want valid: %v.", test.request, err, test.valid)
+		}
+	}
+}
+
+func TestTask_Snooze(t *testing.T) {
+	remaining := 2 * time.Minute
+	task := &Task{Schedule: "in 1h", PausedRemaining: &remaining}
+
+	task.Snooze(10 * time.Minute)
+
+	if !task.IsActive() || task.IsPaused() {
+		t.Errorf("Snooze state was incorrect, got active: %v paused: %v, want active: true paused: false.", task.IsActive(), task.IsPaused())
+	}
+	if got := task.GetRemainingTime().Round(time.Minute); got != 10*time.Minute {
+		t.Errorf("Snooze remaining time was incorrect, got: %v, want: %v.", got, 10*time.Minute)
+	}
+}
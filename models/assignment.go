@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"slices"
+)
+
+var ErrNotTeamMember = errors.New("assignee is not a member of the team")
+
+// TaskAssignee is a user responsible for a task of a team schedule, the name is kept for display
+type TaskAssignee struct {
+	UserId string `json:"userId" bson:"userId"`
+	Name   string `json:"name" bson:"name"`
+}
+
+type AssignTaskFormData struct {
+	Assignees []string `form:"task-assignees"`
+}
+
+// NewTaskAssignees resolves the user ids to assignees, every one of them has to be a member of the team
+func NewTaskAssignees(team *Team, userIds []string) ([]*TaskAssignee, error) {
+	var assignees []*TaskAssignee
+	for _, member := range team.Members {
+		if slices.Contains(userIds, member.UserId) {
+			assignees = append(assignees, &TaskAssignee{UserId: member.UserId, Name: member.Name})
+		}
+	}
+	if len(assignees) != len(uniqueIds(userIds)) {
+		return nil, ErrNotTeamMember
+	}
+	return assignees, nil
+}
+
+func uniqueIds(ids []string) []string {
+	var unique []string
+	for _, id := range ids {
+		if id != "" && !slices.Contains(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// AssigneeIds returns the user ids of the assignees, empty if the task is not assigned
+func (task *Task) AssigneeIds() []string {
+	ids := make([]string, 0, len(task.Assignees))
+	for _, assignee := range task.Assignees {
+		ids = append(ids, assignee.UserId)
+	}
+	return ids
+}
+
+func (task *Task) IsAssignedTo(userId string) bool {
+	return slices.Contains(task.AssigneeIds(), userId)
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestNewTaskAssignees(t *testing.T) {
+	team := &Team{Members: []*TeamMember{
+		{UserId: "1", Name: "ann", Role: RoleAdmin},
+		{UserId: "2", Name: "bob", Role: RoleViewer},
+	}}
+
+	assignees, err := NewTaskAssignees(team, []string{"2", "2"})
+	if err != nil || len(assignees) != 1 || assignees[0].Name != "bob" {
+		t.Errorf("Assignees were incorrect, got: %v (%v), want: [bob].", assignees, err)
+	}
+
+	if _, err := NewTaskAssignees(team, []string{"1", "3"}); err != ErrNotTeamMember {
+		t.Errorf("Outsider error was incorrect, got: %v, want: %v.", err, ErrNotTeamMember)
+	}
+
+	assignees, err = NewTaskAssignees(team, nil)
+	if err != nil || len(assignees) != 0 {
+		t.Errorf("Unassigning was incorrect, got: %v (%v), want: [].", assignees, err)
+	}
+}
@@ -0,0 +1,364 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"scheduler/utils"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate     AuditAction = "create"
+	AuditEdit       AuditAction = "edit"
+	AuditActivate   AuditAction = "activate"
+	AuditDeactivate AuditAction = "deactivate"
+	AuditPause      AuditAction = "pause"
+	AuditResume     AuditAction = "resume"
+	AuditAssign     AuditAction = "assign"
+	AuditSnooze     AuditAction = "snooze"
+	AuditDone       AuditAction = "done"
+	AuditDelete     AuditAction = "delete"
+	AuditRestore    AuditAction = "restore"
+)
+
+// AuditActions are all actions, in the order the audit view offers them as filter
+var AuditActions = []AuditAction{AuditCreate, AuditEdit, AuditActivate, AuditDeactivate, AuditPause, AuditResume,
+	AuditAssign, AuditSnooze, AuditDone, AuditDelete, AuditRestore}
+
+const (
+	AuditSourceWeb    = "web"
+	AuditSourceApi    = "api"
+	AuditSourceGrpc   = "grpc"
+	AuditSourceConfig = "config"
+)
+
+// AuditActor is who changed a task and from where. Changes of the server itself, like the config file, have no user.
+type AuditActor struct {
+	UserId string `json:"userId,omitempty" bson:"userId,omitempty"`
+	Name   string `json:"name,omitempty" bson:"name,omitempty"`
+	// Source is the way the change came in, one of the AuditSource constants
+	Source string `json:"source" bson:"source"`
+	// Client is the API token or the user agent of the request
+	Client string `json:"client,omitempty" bson:"client,omitempty"`
+	Ip     string `json:"ip,omitempty" bson:"ip,omitempty"`
+}
+
+// AuditEntry records a change of a task. Entries are append-only, the stores have no way to change or remove them.
+type AuditEntry struct {
+	Id       string         `json:"id" bson:"id"`
+	Owner    string         `json:"-" bson:"owner"`
+	TaskId   string         `json:"taskId" bson:"taskId"`
+	TaskName string         `json:"taskName" bson:"taskName"`
+	Action   AuditAction    `json:"action" bson:"action"`
+	Actor    *AuditActor    `json:"actor" bson:"actor"`
+	Changes  []*AuditChange `json:"changes" bson:"changes"`
+	At       time.Time      `json:"at" bson:"at"`
+}
+
+// AuditChange is a field of the task before and after the change, as JSON. A missing side means the field was unset.
+type AuditChange struct {
+	Field  string          `json:"field" bson:"field"`
+	Before json.RawMessage `json:"before,omitempty" bson:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// NewAuditEntry records the change of the task from before to after, before is nil for created and after for deleted tasks
+func NewAuditEntry(owner string, actor *AuditActor, action AuditAction, before *Task, after *Task) *AuditEntry {
+	task := after
+	if task == nil {
+		task = before
+	}
+	return &AuditEntry{
+		Id:       utils.Uuid(),
+		Owner:    owner,
+		TaskId:   task.Id,
+		TaskName: task.Name,
+		Action:   action,
+		Actor:    actor,
+		Changes:  DiffTasks(before, after),
+		At:       time.Now(),
+	}
+}
+
+// DiffTasks lists the fields that differ between the tasks by their JSON names, sorted by name
+func DiffTasks(before *Task, after *Task) []*AuditChange {
+	beforeFields, afterFields := taskFields(before), taskFields(after)
+
+	names := make([]string, 0, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, exists := beforeFields[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []*AuditChange{}
+	for _, name := range names {
+		if name == "id" || bytes.Equal(beforeFields[name], afterFields[name]) {
+			continue
+		}
+		changes = append(changes, &AuditChange{Field: name, Before: beforeFields[name], After: afterFields[name]})
+	}
+	return changes
+}
+
+// taskFields splits the JSON of the task into its fields, leaving out unset ones
+func taskFields(task *Task) map[string]json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if task == nil {
+		return fields
+	}
+	encoded, _ := json.Marshal(task)
+	_ = json.Unmarshal(encoded, &fields)
+	for name, value := range fields {
+		if string(value) == "null" {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+// AuditFilterParams are the filters of the audit view and API, times are RFC 3339 or dates
+type AuditFilterParams struct {
+	TaskId string `form:"taskId"`
+	Action string `form:"action"`
+	UserId string `form:"userId"`
+	Since  string `form:"since"`
+	Until  string `form:"until"`
+}
+
+// AuditFilter selects audit entries, empty fields match everything
+type AuditFilter struct {
+	TaskId string
+	Action AuditAction
+	UserId string
+	Since  time.Time
+	// Until is exclusive, a date as until includes the whole day
+	Until time.Time
+}
+
+func (params *AuditFilterParams) Filter() (*AuditFilter, error) {
+	filter := &AuditFilter{TaskId: params.TaskId, Action: AuditAction(params.Action), UserId: params.UserId}
+	if params.Action != "" && !isAuditAction(filter.Action) {
+		return nil, fmt.Errorf("unknown action <%s>", params.Action)
+	}
+
+	var err error
+	if params.Since != "" {
+		if filter.Since, _, err = parseAuditTime(params.Since); err != nil {
+			return nil, err
+		}
+	}
+	if params.Until != "" {
+		var isDate bool
+		if filter.Until, isDate, err = parseAuditTime(params.Until); err != nil {
+			return nil, err
+		}
+		if isDate {
+			filter.Until = filter.Until.AddDate(0, 0, 1)
+		}
+	}
+	return filter, nil
+}
+
+func isAuditAction(action AuditAction) bool {
+	for _, known := range AuditActions {
+		if action == known {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAuditTime(value string) (time.Time, bool, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, false, nil
+	}
+	if at, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return at, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid time <%s>, use RFC 3339 or a date like 2024-05-01", value)
+}
+
+func (filter *AuditFilter) Matches(entry *AuditEntry) bool {
+	return (filter.TaskId == "" || entry.TaskId == filter.TaskId) &&
+		(filter.Action == "" || entry.Action == filter.Action) &&
+		(filter.UserId == "" || entry.Actor != nil && entry.Actor.UserId == filter.UserId) &&
+		(filter.Since.IsZero() || !entry.At.Before(filter.Since)) &&
+		(filter.Until.IsZero() || entry.At.Before(filter.Until))
+}
+
+// AuditEntryVM is an audit entry in the audit view
+type AuditEntryVM struct {
+	*AuditEntry
+}
+
+// Who names the actor for the audit view
+func (entry *AuditEntryVM) Who() string {
+	if entry.Actor == nil {
+		return "unknown"
+	}
+	if entry.Actor.Name != "" {
+		return entry.Actor.Name
+	}
+	return entry.Actor.Source
+}
+
+type AuditPageData struct {
+	PageData
+	Entries []*AuditEntryVM
+	Filter  *AuditFilterParams
+	Actions []AuditAction
+	// Team is the team owning the schedule, nil for the personal schedule
+	Team *Team
+	// Pagination of the entries, the view links to the previous and next page
+	Pagination Pagination
+}
+
+func (data *AuditPageData) PreviousOffset() int {
+	return max(data.Pagination.Offset-data.Pagination.Limit, 0)
+}
+
+func (data *AuditPageData) NextOffset() int {
+	return data.Pagination.Offset + data.Pagination.Limit
+}
+
+func (data *AuditPageData) HasNext() bool {
+	return int64(data.NextOffset()) < data.Pagination.Total
+}
+
+// PageUrl links to the page of the audit view at the offset, keeping the filter and schedule
+func (data *AuditPageData) PageUrl(offset int) string {
+	query := data.query()
+	query.Set("offset", strconv.Itoa(offset))
+	query.Set("limit", strconv.Itoa(data.Pagination.Limit))
+	return "/audit?" + query.Encode()
+}
+
+// ExportUrl downloads all entries of the filter
+func (data *AuditPageData) ExportUrl() string {
+	return "/audit/export?" + data.query().Encode()
+}
+
+func (data *AuditPageData) query() url.Values {
+	query := url.Values{}
+	for name, value := range map[string]string{
+		"taskId": data.Filter.TaskId,
+		"action": data.Filter.Action,
+		"userId": data.Filter.UserId,
+		"since":  data.Filter.Since,
+		"until":  data.Filter.Until,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if data.Team != nil {
+		query.Set("schedule", data.Team.Id)
+	}
+	return query
+}
+
+type AuditDBModel struct {
+	Client *mongo.Client
+}
+
+func (m AuditDBModel) EnsureIndexes() error {
+	dbName := "SchedulerCluster"
+	collectionName := "audit"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "at", Value: -1}}},
+		{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "taskId", Value: 1}, {Key: "at", Value: -1}}},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to create audit indexes")
+	}
+	return err
+}
+
+func (m AuditDBModel) InsertAuditEntry(entry *AuditEntry) error {
+	dbName := "SchedulerCluster"
+	collectionName := "audit"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, entry)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to insert an audit entry")
+	}
+	return err
+}
+
+// GetAuditEntries returns a page of the audit entries of the schedule, newest first, together with the total count
+func (m AuditDBModel) GetAuditEntries(owner string, filter *AuditFilter, offset int, limit int) ([]*AuditEntry, int64, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "audit"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := bson.M{"owner": owner}
+	if filter.TaskId != "" {
+		query["taskId"] = filter.TaskId
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.UserId != "" {
+		query["actor.userId"] = filter.UserId
+	}
+	at := bson.M{}
+	if !filter.Since.IsZero() {
+		at["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		at["$lt"] = filter.Until
+	}
+	if len(at) > 0 {
+		query["at"] = at
+	}
+
+	total, err := collection.CountDocuments(ctx, query)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to count audit entries")
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"at": -1}).SetSkip(int64(offset)).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to find audit entries")
+		return nil, 0, err
+	}
+
+	entries := []*AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to decode audit entries")
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
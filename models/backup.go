@@ -0,0 +1,275 @@
+package models
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupVersion is the version of the Backup format. Version 2 added the trash and the audit log,
+// version 1 backups only restore the schedules and their history.
+const BackupVersion = 2
+
+const (
+	backupPrefix = "backup-"
+	backupSuffix = ".json.gz"
+	// backupTimeFormat sorts by name in time order and is safe in file names,
+	// the nanoseconds keep two backups of the same second apart
+	backupTimeFormat = "20060102T150405.000000000Z"
+	// backupParseFormat also reads the names of version 1 backups without fractional seconds,
+	// time.Parse accepts fractional seconds after the seconds even if the layout has none
+	backupParseFormat = "20060102T150405Z"
+)
+
+// Backup is a snapshot of every schedule in a store together with the history of their tasks, their trash and audit log
+type Backup struct {
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Schedules []*BackupSchedule `json:"schedules"`
+}
+
+type BackupSchedule struct {
+	Owner    string            `json:"owner"`
+	Tasks    []*Task           `json:"tasks"`
+	Routines []*Routine        `json:"routines,omitempty"`
+	History  []*TaskOccurrence `json:"history,omitempty"`
+	// Trash are the deleted tasks, the last deleted first
+	Trash []*DeletedTask `json:"trash,omitempty"`
+	Audit []*AuditEntry  `json:"audit,omitempty"`
+}
+
+// BackupFile is a snapshot in a backup directory
+type BackupFile struct {
+	Path      string
+	CreatedAt time.Time
+}
+
+// NewBackup reads every schedule with the whole history of its tasks, deleted ones included, its trash and audit log from the store
+func NewBackup(store TaskStore) (*Backup, error) {
+	schedules, err := store.GetAllSchedules()
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &Backup{Version: BackupVersion, CreatedAt: time.Now().UTC(), Schedules: make([]*BackupSchedule, 0, len(schedules))}
+	for _, scheduler := range schedules {
+		schedule := &BackupSchedule{Owner: scheduler.Author, Tasks: scheduler.Tasks, Routines: scheduler.Routines}
+		if schedule.History, err = allOccurrences(store, scheduler.Author, ""); err != nil {
+			return nil, err
+		}
+		if schedule.Trash, err = store.GetTrash(scheduler.Author); err != nil {
+			return nil, err
+		}
+		if schedule.Audit, err = allAuditEntries(store, scheduler.Author); err != nil {
+			return nil, err
+		}
+		backup.Schedules = append(backup.Schedules, schedule)
+	}
+	return backup, nil
+}
+
+func allOccurrences(store TaskStore, owner string, taskId string) ([]*TaskOccurrence, error) {
+	var history []*TaskOccurrence
+	for offset := 0; ; offset += MaxPageLimit {
+		occurrences, total, err := store.GetOccurrences(owner, taskId, offset, MaxPageLimit)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, occurrences...)
+		if len(occurrences) == 0 || int64(offset+len(occurrences)) >= total {
+			return history, nil
+		}
+	}
+}
+
+func allAuditEntries(store TaskStore, owner string) ([]*AuditEntry, error) {
+	var audit []*AuditEntry
+	for offset := 0; ; offset += MaxPageLimit {
+		entries, total, err := store.GetAuditEntries(owner, &AuditFilter{}, offset, MaxPageLimit)
+		if err != nil {
+			return nil, err
+		}
+		audit = append(audit, entries...)
+		if len(entries) == 0 || int64(offset+len(entries)) >= total {
+			return audit, nil
+		}
+	}
+}
+
+// WriteBackup saves the backup compressed into the directory and returns its path.
+// The file only appears once it's complete, a crash leaves at most a temporary file behind.
+func WriteBackup(dir string, backup *Backup) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, backupPrefix+backup.CreatedAt.UTC().Format(backupTimeFormat)+backupSuffix)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := gzip.NewWriter(tmp)
+	if err := json.NewEncoder(writer).Encode(backup); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp.Name(), path)
+}
+
+func ReadBackup(path string) (*Backup, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer reader.Close()
+
+	backup := &Backup{}
+	if err := json.NewDecoder(reader).Decode(backup); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if backup.Version > BackupVersion {
+		return nil, fmt.Errorf("backup version %d is newer than this build, which reads up to %d", backup.Version, BackupVersion)
+	}
+	return backup, nil
+}
+
+// ListBackups returns the snapshots in the directory, oldest first
+func ListBackups(dir string) ([]*BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*BackupFile{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		createdAt, err := time.Parse(backupParseFormat, strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix))
+		if err != nil {
+			continue
+		}
+		files = append(files, &BackupFile{Path: filepath.Join(dir, name), CreatedAt: createdAt})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt.Before(files[j].CreatedAt) })
+	return files, nil
+}
+
+var ErrNoBackup = errors.New("no backup found")
+
+// FindBackup returns the newest snapshot of the directory taken at or before the time
+func FindBackup(dir string, at time.Time) (*BackupFile, error) {
+	files, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if !files[i].CreatedAt.After(at) {
+			return files[i], nil
+		}
+	}
+	return nil, ErrNoBackup
+}
+
+// RotateBackups deletes all but the newest keep snapshots and returns the deleted ones
+func RotateBackups(dir string, keep int) ([]*BackupFile, error) {
+	files, err := ListBackups(dir)
+	if err != nil || len(files) <= keep {
+		return nil, err
+	}
+
+	removed := files[:len(files)-keep]
+	for _, file := range removed {
+		if err := os.Remove(file.Path); err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+// RestoreBackup makes every schedule of the backup in the store what it was at the time of the backup, its trash included.
+// Schedules created after the backup are kept, history and audit entries that are already in the store aren't added twice.
+func RestoreBackup(store TaskStore, backup *Backup) error {
+	for _, schedule := range backup.Schedules {
+		if err := restoreSchedule(store, schedule); err != nil {
+			return fmt.Errorf("schedule <%s>: %w", schedule.Owner, err)
+		}
+	}
+	return nil
+}
+
+func restoreSchedule(store TaskStore, schedule *BackupSchedule) error {
+	scheduler := &Scheduler{Author: schedule.Owner, Tasks: schedule.Tasks, Routines: schedule.Routines}
+	if scheduler.Tasks == nil {
+		scheduler.Tasks = []*Task{}
+	}
+	if err := store.ReplaceSchedule(scheduler); err != nil {
+		return err
+	}
+	if err := store.ReplaceTrash(schedule.Owner, schedule.Trash); err != nil {
+		return err
+	}
+
+	history, err := allOccurrences(store, schedule.Owner, "")
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, stored := range history {
+		existing[stored.Id] = true
+	}
+	for _, occurrence := range schedule.History {
+		if existing[occurrence.Id] {
+			continue
+		}
+		occurrence.Owner = schedule.Owner
+		if err := store.InsertOccurrence(occurrence); err != nil {
+			return err
+		}
+	}
+
+	audit, err := allAuditEntries(store, schedule.Owner)
+	if err != nil {
+		return err
+	}
+	existing = map[string]bool{}
+	for _, stored := range audit {
+		existing[stored.Id] = true
+	}
+	for _, entry := range schedule.Audit {
+		if existing[entry.Id] {
+			continue
+		}
+		entry.Owner = schedule.Owner
+		if err := store.InsertAuditEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
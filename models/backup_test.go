@@ -0,0 +1,124 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"scheduler/utils"
+)
+
+func TestBackup_Restore(t *testing.T) {
+	source := NewMemoryStore()
+	tea := newTestTask("Tea")
+	_, _ = source.InsertTask("u1", tea)
+	_, _ = source.InsertTask("u1", newTestTask("Coffee"))
+	_, _ = source.InsertTask("team", newTestTask("Standup"))
+	_ = source.InsertOccurrence(&TaskOccurrence{Id: utils.Uuid(), Owner: "u1", TaskId: tea.Id, TaskName: "Tea", Event: OccurrenceFired, At: time.Now()})
+	// a deleted task keeps its history and its audit
+	cake := newTestTask("Cake")
+	_, _ = source.InsertTask("u1", cake)
+	_ = source.InsertOccurrence(NewTaskOccurrence("u1", cake, OccurrenceDone, "u1"))
+	_ = source.InsertAuditEntry(NewAuditEntry("u1", &AuditActor{UserId: "u1", Source: AuditSourceWeb}, AuditCreate, nil, cake))
+	_ = source.InsertAuditEntry(NewAuditEntry("u1", &AuditActor{UserId: "u1", Source: AuditSourceWeb}, AuditDelete, cake, nil))
+	_, _ = source.DeleteTasks("u1", []string{cake.Id})
+
+	backup, err := NewBackup(source)
+	if err != nil {
+		t.Fatalf("NewBackup failed: %v", err)
+	}
+	dir := t.TempDir()
+	path, err := WriteBackup(dir, backup)
+	if err != nil {
+		t.Fatalf("WriteBackup failed: %v", err)
+	}
+	read, err := ReadBackup(path)
+	if err != nil {
+		t.Fatalf("ReadBackup failed: %v", err)
+	}
+
+	// restoring twice must not duplicate the history
+	target, err := NewSqliteStore(filepath.Join(t.TempDir(), "schedules.db"))
+	if err != nil {
+		t.Fatalf("NewSqliteStore failed: %v", err)
+	}
+	defer target.Close()
+	_, _ = target.InsertTask("u1", newTestTask("Later"))
+	for i := 0; i < 2; i++ {
+		if err := RestoreBackup(target, read); err != nil {
+			t.Fatalf("RestoreBackup failed: %v", err)
+		}
+	}
+
+	scheduler, _ := target.GetScheduleByAuthor("u1")
+	if names := taskNames(scheduler); !equalNames(names, "Tea", "Coffee") {
+		t.Errorf("Restored tasks were incorrect, got: %v, want: %v.", names, []string{"Tea", "Coffee"})
+	}
+	if team, _ := target.GetScheduleByAuthor("team"); !equalNames(taskNames(team), "Standup") {
+		t.Errorf("Restored team tasks were incorrect, got: %v, want: %v.", taskNames(team), []string{"Standup"})
+	}
+	if _, total, _ := target.GetOccurrences("u1", tea.Id, 0, 10); total != 1 {
+		t.Errorf("Restored history was incorrect, got: %v, want: %v.", total, 1)
+	}
+	if _, total, _ := target.GetOccurrences("u1", cake.Id, 0, 10); total != 1 {
+		t.Errorf("Restored history of the deleted task was incorrect, got: %v, want: %v.", total, 1)
+	}
+	if trash, _ := target.GetTrash("u1"); len(trash) != 1 || trash[0].Task.Id != cake.Id {
+		t.Errorf("Restored trash was incorrect, got: %+v, want: %v.", trash, cake.Name)
+	}
+	if _, total, _ := target.GetAuditEntries("u1", &AuditFilter{TaskId: cake.Id}, 0, 10); total != 2 {
+		t.Errorf("Restored audit was incorrect, got: %v, want: %v.", total, 2)
+	}
+}
+
+func TestBackup_SameSecond(t *testing.T) {
+	dir := t.TempDir()
+	createdAt := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	first, _ := WriteBackup(dir, &Backup{Version: BackupVersion, CreatedAt: createdAt.Add(100 * time.Millisecond)})
+	second, _ := WriteBackup(dir, &Backup{Version: BackupVersion, CreatedAt: createdAt.Add(200 * time.Millisecond)})
+	if first == second {
+		t.Errorf("Paths of backups of the same second were incorrect, got: %v twice, want: two paths.", first)
+	}
+
+	// version 1 named backups by the second
+	_ = os.WriteFile(filepath.Join(dir, "backup-20240501T020000Z.json.gz"), []byte{}, 0o600)
+	files, err := ListBackups(dir)
+	if err != nil || len(files) != 3 {
+		t.Fatalf("ListBackups was incorrect, got: %+v %v, want: 3 backups.", files, err)
+	}
+	want := []time.Time{createdAt.Add(-time.Hour), createdAt.Add(100 * time.Millisecond), createdAt.Add(200 * time.Millisecond)}
+	for i, file := range files {
+		if !file.CreatedAt.Equal(want[i]) {
+			t.Errorf("CreatedAt of backup %d was incorrect, got: %v, want: %v.", i, file.CreatedAt, want[i])
+		}
+	}
+}
+
+func TestBackup_FindAndRotate(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	for day := 0; day < 4; day++ {
+		if _, err := WriteBackup(dir, &Backup{Version: BackupVersion, CreatedAt: start.AddDate(0, 0, day)}); err != nil {
+			t.Fatalf("WriteBackup failed: %v", err)
+		}
+	}
+	_ = os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a backup"), 0o600)
+
+	file, err := FindBackup(dir, start.AddDate(0, 0, 2).Add(time.Hour))
+	if err != nil || !file.CreatedAt.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("FindBackup was incorrect, got: %+v %v, want: the backup of %v.", file, err, start.AddDate(0, 0, 2))
+	}
+	if _, err := FindBackup(dir, start.Add(-time.Hour)); err != ErrNoBackup {
+		t.Errorf("FindBackup before the first backup was incorrect, got: %v, want: %v.", err, ErrNoBackup)
+	}
+
+	removed, err := RotateBackups(dir, 2)
+	if err != nil || len(removed) != 2 {
+		t.Fatalf("RotateBackups was incorrect, got: %v %v, want: 2 removed.", removed, err)
+	}
+	files, _ := ListBackups(dir)
+	if len(files) != 2 || !files[0].CreatedAt.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("Kept backups were incorrect, got: %+v, want: the newest two.", files)
+	}
+}
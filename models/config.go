@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/invopop/yaml"
+)
+
+// ScheduleConfig declares schedules in a file that the server keeps the store in line with.
+// Only the declared schedules are managed, schedules missing from the file are left alone.
+type ScheduleConfig struct {
+	Version   int                 `json:"version"`
+	Schedules []*DeclaredSchedule `json:"schedules"`
+}
+
+// DeclaredSchedule is the complete schedule of a user or team id: tasks the file doesn't declare are deleted.
+// Declared tasks need an id, it tells changed tasks from new ones between reloads.
+type DeclaredSchedule struct {
+	Owner string `json:"owner"`
+	// Activate starts the timers of the tasks the file creates or changes
+	Activate bool       `json:"activate,omitempty"`
+	Tasks    []*Task    `json:"tasks"`
+	Routines []*Routine `json:"routines,omitempty"`
+}
+
+func (schedule *DeclaredSchedule) Export() *ScheduleExport {
+	return &ScheduleExport{Version: ExportVersion, Tasks: schedule.Tasks, Routines: schedule.Routines}
+}
+
+func DecodeScheduleConfig(data []byte, format string) (*ScheduleConfig, error) {
+	config := &ScheduleConfig{}
+	var err error
+	if format == ExportYAML {
+		err = yaml.Unmarshal(data, config)
+	} else {
+		err = json.Unmarshal(data, config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the %s: %w", format, err)
+	}
+	if config.Version > ExportVersion {
+		return nil, fmt.Errorf("config version %d is newer than this server, which reads up to %d", config.Version, ExportVersion)
+	}
+
+	owners := map[string]bool{}
+	for _, schedule := range config.Schedules {
+		schedule.Owner = strings.TrimSpace(schedule.Owner)
+		if schedule.Owner == "" {
+			return nil, errors.New("every schedule needs an owner")
+		}
+		if owners[schedule.Owner] {
+			return nil, fmt.Errorf("schedule <%s> is declared more than once", schedule.Owner)
+		}
+		owners[schedule.Owner] = true
+
+		for _, task := range schedule.Tasks {
+			if task.Id == "" {
+				return nil, fmt.Errorf("schedule <%s>: task <%s> needs an id", schedule.Owner, task.Name)
+			}
+		}
+	}
+	return config, nil
+}
+
+// Find returns the declared schedule of the owner, nil if the config doesn't declare it
+func (config *ScheduleConfig) Find(owner string) *DeclaredSchedule {
+	for _, schedule := range config.Schedules {
+		if schedule.Owner == owner {
+			return schedule
+		}
+	}
+	return nil
+}
+
+// ScheduleDiff is what loading the config changes in the schedule of the owner
+type ScheduleDiff struct {
+	Owner  string        `json:"owner"`
+	Report *ImportReport `json:"report"`
+}
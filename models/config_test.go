@@ -0,0 +1,40 @@
+package models
+
+import "testing"
+
+func TestDecodeScheduleConfig(t *testing.T) {
+	data := `
+version: 1
+schedules:
+  - owner: " team "
+    activate: true
+    tasks:
+      - {id: standup, name: Standup, schedule: "at 09:30"}
+`
+	config, err := DecodeScheduleConfig([]byte(data), ExportYAML)
+	if err != nil {
+		t.Fatalf("Valid config was rejected: %v", err)
+	}
+	schedule := config.Find("team")
+	if schedule == nil || !schedule.Activate || len(schedule.Export().Tasks) != 1 {
+		t.Errorf("Declared schedule was incorrect, got: %+v, want: the activated team schedule.", schedule)
+	}
+	if config.Find("other") != nil {
+		t.Errorf("Undeclared schedule was found")
+	}
+}
+
+func TestDecodeScheduleConfig_Invalid(t *testing.T) {
+	inputs := []string{
+		`{"schedules":[{"tasks":[]}]}`,
+		`{"schedules":[{"owner":"team","tasks":[]},{"owner":"team","tasks":[]}]}`,
+		`{"schedules":[{"owner":"team","tasks":[{"name":"Standup","schedule":"at 09:30"}]}]}`,
+		`{"version":99,"schedules":[]}`,
+		`{"schedules":`,
+	}
+	for _, input := range inputs {
+		if _, err := DecodeScheduleConfig([]byte(input), ExportJSON); err == nil {
+			t.Errorf("Invalid config was accepted <%s>", input)
+		}
+	}
+}
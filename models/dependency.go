@@ -0,0 +1,107 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"scheduler/utils"
+	"time"
+)
+
+type DependencyEvent string
+
+const (
+	// DependencyFired starts the successor as soon as the predecessor's timer expires
+	DependencyFired DependencyEvent = "fired"
+	// DependencyDone starts the successor once the predecessor's alert was marked as done
+	DependencyDone DependencyEvent = "done"
+)
+
+var (
+	ErrDependencyNotFound = errors.New("dependency task not found")
+	ErrDependencyCycle    = errors.New("dependency cycle detected")
+	ErrDependencyEvent    = errors.New("invalid dependency event")
+	ErrDependencyDelay    = errors.New("dependency delay must not be negative")
+)
+
+// TaskDependency chains a task to a predecessor. The task is activated automatically
+// when the predecessor fires or is done, optionally after a delay (e.g. "5min").
+type TaskDependency struct {
+	TaskId string          `json:"taskId" bson:"taskId"`
+	Event  DependencyEvent `json:"event" bson:"event"`
+	Delay  string          `json:"delay,omitempty" bson:"delay,omitempty"`
+}
+
+// NewTaskDependency validates the raw form values and creates a dependency.
+// It returns nil without an error if no predecessor is given.
+func NewTaskDependency(taskId string, event DependencyEvent, delay string) (*TaskDependency, error) {
+	if taskId == "" {
+		return nil, nil
+	}
+
+	if event == "" {
+		event = DependencyFired
+	}
+	if event != DependencyFired && event != DependencyDone {
+		return nil, fmt.Errorf("%w <%s>", ErrDependencyEvent, event)
+	}
+
+	if delay != "" {
+		parsed, err := utils.ParseDelay(delay)
+		if err != nil {
+			return nil, err
+		}
+		if parsed < 0 {
+			return nil, fmt.Errorf("%w <%s>", ErrDependencyDelay, delay)
+		}
+	}
+
+	return &TaskDependency{TaskId: taskId, Event: event, Delay: delay}, nil
+}
+
+// GetDelay is the parsed delay, stored negative delays from before they were rejected start at once
+func (dep *TaskDependency) GetDelay() time.Duration {
+	delay, _ := utils.ParseDelay(dep.Delay)
+	return max(delay, 0)
+}
+
+// ValidateDependency checks that the predecessor of the given task exists and that following
+// the chain of predecessors never leads back to the task itself.
+// The task may or may not be part of tasks already, its own dependency always takes precedence.
+func ValidateDependency(tasks []*Task, task *Task) error {
+	if task.DependsOn == nil {
+		return nil
+	}
+
+	predecessors := make(map[string]*TaskDependency, len(tasks))
+	for _, t := range tasks {
+		predecessors[t.Id] = t.DependsOn
+	}
+	predecessors[task.Id] = task.DependsOn
+
+	visited := map[string]bool{task.Id: true}
+	current := task.DependsOn
+	for current != nil {
+		if visited[current.TaskId] {
+			return ErrDependencyCycle
+		}
+		next, exists := predecessors[current.TaskId]
+		if !exists {
+			return fmt.Errorf("%w <%s>", ErrDependencyNotFound, current.TaskId)
+		}
+		visited[current.TaskId] = true
+		current = next
+	}
+
+	return nil
+}
+
+// GetSuccessors returns all tasks that should be started when the task with the given id triggers the event
+func GetSuccessors(tasks []*Task, taskId string, event DependencyEvent) []*Task {
+	var successors []*Task
+	for _, task := range tasks {
+		if task.DependsOn != nil && task.DependsOn.TaskId == taskId && task.DependsOn.Event == event {
+			successors = append(successors, task)
+		}
+	}
+	return successors
+}
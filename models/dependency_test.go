@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func chainedTasks() []*Task {
+	return []*Task{
+		{Id: "preheat", Name: "Preheat oven", Schedule: "in 10min"},
+		{Id: "bake", Name: "Bake", Schedule: "in 25min", DependsOn: &TaskDependency{TaskId: "preheat", Event: DependencyFired}},
+		{Id: "cool", Name: "Cool", Schedule: "in 10min", DependsOn: &TaskDependency{TaskId: "bake", Event: DependencyDone, Delay: "1min"}},
+	}
+}
+
+func TestValidateDependency_Chain(t *testing.T) {
+	tasks := chainedTasks()
+	for _, task := range tasks {
+		if err := ValidateDependency(tasks, task); err != nil {
+			t.Errorf("Valid chain was rejected for %s: %v", task.Id, err)
+		}
+	}
+}
+
+func TestValidateDependency_Cycle(t *testing.T) {
+	tasks := chainedTasks()
+	edited := *tasks[0]
+	edited.DependsOn = &TaskDependency{TaskId: "cool", Event: DependencyFired}
+
+	if err := ValidateDependency(tasks, &edited); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("Cycle was not detected, got: %v", err)
+	}
+}
+
+func TestValidateDependency_Self(t *testing.T) {
+	tasks := chainedTasks()
+	edited := *tasks[0]
+	edited.DependsOn = &TaskDependency{TaskId: "preheat", Event: DependencyFired}
+
+	if err := ValidateDependency(tasks, &edited); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("Self dependency was not detected, got: %v", err)
+	}
+}
+
+func TestValidateDependency_NotFound(t *testing.T) {
+	task := &Task{Id: "new", DependsOn: &TaskDependency{TaskId: "missing", Event: DependencyFired}}
+
+	if err := ValidateDependency(chainedTasks(), task); !errors.Is(err, ErrDependencyNotFound) {
+		t.Errorf("Missing predecessor was not detected, got: %v", err)
+	}
+}
+
+func TestGetSuccessors(t *testing.T) {
+	tasks := chainedTasks()
+
+	if successors := GetSuccessors(tasks, "preheat", DependencyFired); len(successors) != 1 || successors[0].Id != "bake" {
+		t.Errorf("Successors of preheat were incorrect, got: %v", successors)
+	}
+	if successors := GetSuccessors(tasks, "bake", DependencyFired); len(successors) != 0 {
+		t.Errorf("Successors waiting for done were started on fire, got: %v", successors)
+	}
+}
+
+func TestNewTaskDependency_InvalidDelay(t *testing.T) {
+	if _, err := NewTaskDependency("preheat", DependencyFired, "soon"); err == nil {
+		t.Errorf("Invalid delay was accepted")
+	}
+}
+
+func TestNewTaskDependency_NegativeDelay(t *testing.T) {
+	if _, err := NewTaskDependency("preheat", DependencyFired, "-5min"); !errors.Is(err, ErrDependencyDelay) {
+		t.Errorf("Negative delay was incorrect, got: %v, want: %v.", err, ErrDependencyDelay)
+	}
+	if _, err := NewTaskDependency("preheat", DependencyFired, "0s"); err != nil {
+		t.Errorf("Zero delay was incorrect, got: %v, want: %v.", err, nil)
+	}
+}
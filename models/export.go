@@ -0,0 +1,319 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"scheduler/utils"
+	"strings"
+	"time"
+
+	"github.com/invopop/yaml"
+)
+
+// ExportVersion is the version of the ScheduleExport format
+const ExportVersion = 1
+
+const (
+	ExportJSON = "json"
+	ExportYAML = "yaml"
+)
+
+// ScheduleExport is a complete schedule in a file. It has the JSON shape of a Scheduler with a few additions,
+// so a plain schedule.json can be imported as well. YAML uses the same field names.
+type ScheduleExport struct {
+	Version    int        `json:"version"`
+	ExportedAt *time.Time `json:"exportedAt,omitempty"`
+	Tasks      []*Task    `json:"tasks"`
+	Routines   []*Routine `json:"routines,omitempty"`
+	// History is only exported on request and never imported
+	History []*TaskOccurrence `json:"history,omitempty"`
+}
+
+func NewScheduleExport(scheduler *Scheduler, history []*TaskOccurrence) *ScheduleExport {
+	exportedAt := time.Now()
+	return &ScheduleExport{
+		Version:    ExportVersion,
+		ExportedAt: &exportedAt,
+		Tasks:      scheduler.Tasks,
+		Routines:   scheduler.Routines,
+		History:    history,
+	}
+}
+
+// ExportFormat returns the format of a file name or a content type, JSON if it's neither YAML nor JSON
+func ExportFormat(nameOrType string) string {
+	value := strings.ToLower(nameOrType)
+	if strings.HasSuffix(value, ".yaml") || strings.HasSuffix(value, ".yml") || strings.Contains(value, "yaml") {
+		return ExportYAML
+	}
+	return ExportJSON
+}
+
+func (export *ScheduleExport) Encode(format string) ([]byte, error) {
+	if format == ExportYAML {
+		// the YAML is converted from the JSON, so it has the same field names
+		return yaml.Marshal(export)
+	}
+	return json.MarshalIndent(export, "", "  ")
+}
+
+func DecodeScheduleExport(data []byte, format string) (*ScheduleExport, error) {
+	export := &ScheduleExport{}
+	var err error
+	if format == ExportYAML {
+		err = yaml.Unmarshal(data, export)
+	} else {
+		err = json.Unmarshal(data, export)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the %s: %w", format, err)
+	}
+	if export.Version > ExportVersion {
+		return nil, fmt.Errorf("export version %d is newer than this server, which reads up to %d", export.Version, ExportVersion)
+	}
+	return export, nil
+}
+
+const (
+	// ImportMerge adds the tasks and routines that aren't in the schedule yet
+	ImportMerge = "merge"
+	// ImportReplace makes the schedule exactly the imported one
+	ImportReplace = "replace"
+)
+
+var ErrInvalidImportMode = errors.New("mode has to be merge or replace")
+
+// ExportParams are the query parameters of an export
+type ExportParams struct {
+	// Format is json or yaml
+	Format  string `form:"format"`
+	History bool   `form:"history"`
+}
+
+// ImportParams are the query parameters of an import
+type ImportParams struct {
+	Mode   string `form:"mode"`
+	DryRun bool   `form:"dryRun"`
+}
+
+// ImportErrorResponse is the error object of a rejected import together with the report of its problems
+type ImportErrorResponse struct {
+	ApiErrorResponse
+	Report *ImportReport `json:"report,omitempty"`
+}
+
+// ImportIssue is a problem with a task or routine of an import, identified by its id
+type ImportIssue struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// ImportReport lists what an import changes. Nothing is changed if there are errors or, when merging, conflicts.
+type ImportReport struct {
+	Mode    string `json:"mode"`
+	DryRun  bool   `json:"dryRun"`
+	Applied bool   `json:"applied"`
+	// Created, Updated, Unchanged and Removed are task ids
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Unchanged []string `json:"unchanged"`
+	Removed   []string `json:"removed"`
+	// Errors are invalid tasks and routines of the import
+	Errors []*ImportIssue `json:"errors"`
+	// Conflicts are tasks and routines of a merge whose id exists with a different definition
+	Conflicts []*ImportIssue `json:"conflicts"`
+}
+
+func (report *ImportReport) Ok() bool {
+	return len(report.Errors) == 0 && len(report.Conflicts) == 0
+}
+
+// PlanImport validates the import and computes the resulting schedule, which is nil if the import isn't Ok.
+// Imported tasks start inactive, the timers of another environment don't carry over.
+// Only tasks that are already in the schedule with the same definition keep their timers.
+// Team is the team owning the schedule, nil for a personal schedule, imported assignees have to be its members.
+func PlanImport(current *Scheduler, export *ScheduleExport, mode string, team *Team) (*ImportReport, *Scheduler, error) {
+	if mode != ImportMerge && mode != ImportReplace {
+		return nil, nil, ErrInvalidImportMode
+	}
+
+	report := &ImportReport{Mode: mode, Created: []string{}, Updated: []string{}, Unchanged: []string{}, Removed: []string{},
+		Errors: validateExport(export, team), Conflicts: []*ImportIssue{}}
+
+	tasks := make([]*Task, 0, len(export.Tasks))
+	for _, task := range export.Tasks {
+		imported := *task
+		if imported.Id == "" {
+			// hand written files may leave out the ids
+			imported.Id = utils.Uuid()
+		}
+		imported.Name = strings.TrimSpace(imported.Name)
+		if imported.Trigger == "" {
+			imported.Trigger = Popup
+		}
+		imported.ActivatedTime = nil
+		imported.PausedRemaining = nil
+		if team != nil && len(imported.Assignees) > 0 {
+			// the names of the assignees are the current ones of the team, invalid assignees are reported by validateExport
+			if assignees, err := NewTaskAssignees(team, imported.AssigneeIds()); err == nil {
+				imported.Assignees = assignees
+			}
+		}
+		tasks = append(tasks, &imported)
+	}
+
+	planned := &Scheduler{Author: current.Author}
+	switch mode {
+	case ImportReplace:
+		planned.Tasks = make([]*Task, 0, len(tasks))
+		planned.Routines = export.Routines
+		for _, task := range tasks {
+			existing := current.FindTask(task.Id)
+			switch {
+			case existing == nil:
+				report.Created = append(report.Created, task.Id)
+			case sameDefinition(existing, task):
+				// unchanged tasks keep running
+				task = existing
+				report.Unchanged = append(report.Unchanged, task.Id)
+			default:
+				report.Updated = append(report.Updated, task.Id)
+			}
+			planned.Tasks = append(planned.Tasks, task)
+		}
+		for _, task := range current.Tasks {
+			if !containsTask(tasks, task.Id) {
+				report.Removed = append(report.Removed, task.Id)
+			}
+		}
+
+	case ImportMerge:
+		planned.Tasks = append([]*Task{}, current.Tasks...)
+		for _, task := range tasks {
+			existing := current.FindTask(task.Id)
+			switch {
+			case existing == nil:
+				planned.Tasks = append(planned.Tasks, task)
+				report.Created = append(report.Created, task.Id)
+			case sameDefinition(existing, task):
+				report.Unchanged = append(report.Unchanged, task.Id)
+			default:
+				report.Conflicts = append(report.Conflicts, &ImportIssue{Id: task.Id, Name: task.Name,
+					Message: fmt.Sprintf("a different task <%s> has this id", existing.Name)})
+			}
+		}
+
+		planned.Routines = append([]*Routine{}, current.Routines...)
+		for _, routine := range export.Routines {
+			existing := findRoutine(current.Routines, routine.Id)
+			switch {
+			case existing == nil:
+				planned.Routines = append(planned.Routines, routine)
+			case !reflect.DeepEqual(existing, routine):
+				report.Conflicts = append(report.Conflicts, &ImportIssue{Id: routine.Id, Name: routine.Name,
+					Message: fmt.Sprintf("a different routine <%s> has this id", existing.Name)})
+			}
+		}
+	}
+
+	// dependencies may point to tasks of the schedule as well as of the import
+	for _, task := range tasks {
+		if task.DependsOn == nil || !containsTask(planned.Tasks, task.Id) {
+			continue
+		}
+		_, err := NewTaskDependency(task.DependsOn.TaskId, task.DependsOn.Event, task.DependsOn.Delay)
+		if err == nil {
+			err = ValidateDependency(planned.Tasks, task)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, &ImportIssue{Id: task.Id, Name: task.Name, Message: err.Error()})
+		}
+	}
+
+	if !report.Ok() {
+		return report, nil, nil
+	}
+	return report, planned, nil
+}
+
+// ApplyReplaceImport writes a replacing import planned by PlanImport. The removed tasks are moved to the trash
+// before the schedule is replaced, so they can be restored like any deleted task.
+func ApplyReplaceImport(store TaskStore, report *ImportReport, planned *Scheduler) error {
+	if len(report.Removed) > 0 {
+		if _, err := store.DeleteTasks(planned.Author, report.Removed); err != nil {
+			return err
+		}
+	}
+	return store.ReplaceSchedule(planned)
+}
+
+// validateExport checks every task and routine like the forms and the API do
+func validateExport(export *ScheduleExport, team *Team) []*ImportIssue {
+	issues := []*ImportIssue{}
+	seen := map[string]bool{}
+	for _, task := range export.Tasks {
+		if task.Id != "" && seen[task.Id] {
+			issues = append(issues, &ImportIssue{Id: task.Id, Name: task.Name, Message: "the id is used by more than one task"})
+		}
+		seen[task.Id] = true
+
+		request := &TaskRequest{Name: task.Name, Schedule: task.Schedule, Trigger: task.Trigger, Priority: task.Priority}
+		if request.Trigger == "" {
+			request.Trigger = Popup
+		}
+		if err := request.Validate(); err != nil {
+			issues = append(issues, &ImportIssue{Id: task.Id, Name: task.Name, Message: err.Error()})
+		}
+
+		if len(task.Assignees) == 0 {
+			continue
+		}
+		if team == nil {
+			issues = append(issues, &ImportIssue{Id: task.Id, Name: task.Name, Message: "only tasks of team schedules can be assigned"})
+		} else if _, err := NewTaskAssignees(team, task.AssigneeIds()); err != nil {
+			issues = append(issues, &ImportIssue{Id: task.Id, Name: task.Name, Message: err.Error()})
+		}
+	}
+
+	for _, routine := range export.Routines {
+		if routine.Id == "" || strings.TrimSpace(routine.Name) == "" {
+			issues = append(issues, &ImportIssue{Id: routine.Id, Name: routine.Name, Message: "routines need an id and a name"})
+		}
+		for _, task := range routine.Tasks {
+			if _, err := utils.ParseDuration(task.Schedule); err != nil {
+				issues = append(issues, &ImportIssue{Id: routine.Id, Name: routine.Name,
+					Message: fmt.Sprintf("task <%s>: %v", task.Name, err)})
+			}
+		}
+	}
+	return issues
+}
+
+// sameDefinition compares tasks without their timer state
+func sameDefinition(a *Task, b *Task) bool {
+	definitionA, definitionB := *a, *b
+	definitionA.ActivatedTime, definitionB.ActivatedTime = nil, nil
+	definitionA.PausedRemaining, definitionB.PausedRemaining = nil, nil
+	return ETag(&definitionA) == ETag(&definitionB)
+}
+
+func containsTask(tasks []*Task, id string) bool {
+	for _, task := range tasks {
+		if task.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func findRoutine(routines []*Routine, id string) *Routine {
+	for _, routine := range routines {
+		if routine.Id == id {
+			return routine
+		}
+	}
+	return nil
+}
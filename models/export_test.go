@@ -0,0 +1,177 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportTestSchedule() *Scheduler {
+	activatedTime := time.Now()
+	return &Scheduler{
+		Author: "u1",
+		Tasks: []*Task{
+			{Id: "preheat", Name: "Preheat", Schedule: "in 10min", Trigger: Popup, ActivatedTime: &activatedTime},
+			{Id: "bake", Name: "Bake", Schedule: "in 25min", Trigger: Audio, Tags: []string{"kitchen"},
+				DependsOn: &TaskDependency{TaskId: "preheat", Event: DependencyFired}},
+		},
+		Routines: []*Routine{{Id: "r1", Name: "Bread", Tasks: []*RoutineTask{{Name: "Knead", Schedule: "in 10min", Trigger: Popup}}}},
+	}
+}
+
+func TestScheduleExport_Roundtrip(t *testing.T) {
+	export := NewScheduleExport(exportTestSchedule(), nil)
+
+	for _, format := range []string{ExportJSON, ExportYAML} {
+		data, err := export.Encode(format)
+		if err != nil {
+			t.Fatalf("Encoding %s failed: %v", format, err)
+		}
+		decoded, err := DecodeScheduleExport(data, format)
+		if err != nil {
+			t.Fatalf("Decoding %s failed: %v", format, err)
+		}
+		if len(decoded.Tasks) != 2 || decoded.Tasks[1].DependsOn == nil || decoded.Tasks[1].Tags[0] != "kitchen" {
+			t.Errorf("Decoded %s tasks were incorrect, got: %+v, want: %+v.", format, decoded.Tasks, export.Tasks)
+		}
+		if len(decoded.Routines) != 1 || decoded.Routines[0].Tasks[0].Name != "Knead" {
+			t.Errorf("Decoded %s routines were incorrect, got: %+v, want: %+v.", format, decoded.Routines, export.Routines)
+		}
+	}
+
+	if data, _ := export.Encode(ExportYAML); !strings.Contains(string(data), "schedule: in 10min") {
+		t.Errorf("YAML field names were incorrect, got: %s, want: the JSON names.", data)
+	}
+	if _, err := DecodeScheduleExport([]byte(`{"version":99,"tasks":[]}`), ExportJSON); err == nil {
+		t.Errorf("Export of a newer version was accepted")
+	}
+}
+
+func TestExportFormat(t *testing.T) {
+	tests := map[string]string{
+		"schedule.yml":                    ExportYAML,
+		"backup.YAML":                     ExportYAML,
+		"application/yaml":                ExportYAML,
+		"application/json; charset=utf-8": ExportJSON,
+		"":                                ExportJSON,
+	}
+	for input, want := range tests {
+		if got := ExportFormat(input); got != want {
+			t.Errorf("ExportFormat(%q) was incorrect, got: %v, want: %v.", input, got, want)
+		}
+	}
+}
+
+func TestPlanImport_ScheduleJson(t *testing.T) {
+	// a schedule.json of the file store has no version and keeps the timer state
+	data := `{"author":"u1","tasks":[{"id":"tea","name":"Tea","schedule":"in 4min","activatedTime":"2024-01-01T10:00:00Z","trigger":"audio"}]}`
+	export, err := DecodeScheduleExport([]byte(data), ExportJSON)
+	if err != nil {
+		t.Fatalf("Decoding a schedule.json failed: %v", err)
+	}
+
+	report, planned, err := PlanImport(&Scheduler{Author: "u2"}, export, ImportMerge, nil)
+	if err != nil || !report.Ok() {
+		t.Fatalf("Import of a schedule.json was rejected: %v %+v", err, report)
+	}
+	if len(planned.Tasks) != 1 || planned.Tasks[0].ActivatedTime != nil || planned.Author != "u2" {
+		t.Errorf("Planned schedule was incorrect, got: %+v, want: the inactive task in the schedule of u2.", planned.Tasks)
+	}
+}
+
+func TestPlanImport_Merge(t *testing.T) {
+	current := exportTestSchedule()
+	export := NewScheduleExport(exportTestSchedule(), nil)
+	export.Tasks = append(export.Tasks, &Task{Name: "Cool", Schedule: "in 30min",
+		DependsOn: &TaskDependency{TaskId: "bake", Event: DependencyDone}})
+
+	report, planned, err := PlanImport(current, export, ImportMerge, nil)
+	if err != nil || !report.Ok() {
+		t.Fatalf("Merge was rejected: %v %+v", err, report)
+	}
+	if len(report.Created) != 1 || len(report.Unchanged) != 2 || len(planned.Tasks) != 3 || len(planned.Routines) != 1 {
+		t.Errorf("Merge report was incorrect, got: %+v, want: 1 created and 2 unchanged tasks.", report)
+	}
+	if planned.Tasks[0].ActivatedTime == nil {
+		t.Errorf("Unchanged task lost its timer")
+	}
+	if planned.Tasks[2].Id == "" || planned.Tasks[2].Trigger != Popup {
+		t.Errorf("Created task was incorrect, got: %+v, want: a generated id and the popup trigger.", planned.Tasks[2])
+	}
+
+	export.Tasks[1] = &Task{Id: "bake", Name: "Roast", Schedule: "in 40min", Trigger: Audio}
+	report, planned, _ = PlanImport(current, export, ImportMerge, nil)
+	if len(report.Conflicts) != 1 || report.Conflicts[0].Id != "bake" || planned != nil {
+		t.Errorf("Merge conflicts were incorrect, got: %+v, want: a conflict for bake and no plan.", report.Conflicts)
+	}
+}
+
+func TestPlanImport_Replace(t *testing.T) {
+	current := exportTestSchedule()
+	export := &ScheduleExport{Tasks: []*Task{
+		{Id: "preheat", Name: "Preheat", Schedule: "in 10min", Trigger: Popup},
+		{Id: "proof", Name: "Proof", Schedule: "in 1h", Trigger: Popup},
+	}}
+
+	report, planned, err := PlanImport(current, export, ImportReplace, nil)
+	if err != nil || !report.Ok() {
+		t.Fatalf("Replace was rejected: %v %+v", err, report)
+	}
+	got := strings.Join([]string{strings.Join(report.Created, ","), strings.Join(report.Unchanged, ","), strings.Join(report.Removed, ",")}, "|")
+	if got != "proof|preheat|bake" {
+		t.Errorf("Replace report was incorrect, got: %v, want: %v.", got, "proof|preheat|bake")
+	}
+	if len(planned.Tasks) != 2 || len(planned.Routines) != 0 {
+		t.Errorf("Replaced schedule was incorrect, got: %+v, want: the imported tasks only.", planned)
+	}
+
+	if _, _, err := PlanImport(current, export, "append", nil); err != ErrInvalidImportMode {
+		t.Errorf("Invalid mode was incorrect, got: %v, want: %v.", err, ErrInvalidImportMode)
+	}
+}
+
+func TestPlanImport_Invalid(t *testing.T) {
+	exports := []*ScheduleExport{
+		{Tasks: []*Task{{Name: "", Schedule: "in 5min"}}},
+		{Tasks: []*Task{{Name: "Tea", Schedule: "soon"}}},
+		{Tasks: []*Task{{Name: "Tea", Schedule: "in 5min", Trigger: "email"}}},
+		{Tasks: []*Task{{Id: "a", Name: "Tea", Schedule: "in 5min"}, {Id: "a", Name: "Coffee", Schedule: "in 5min"}}},
+		{Tasks: []*Task{{Name: "Tea", Schedule: "in 5min", DependsOn: &TaskDependency{TaskId: "missing", Event: DependencyFired}}}},
+		{Routines: []*Routine{{Id: "r", Name: "Bread", Tasks: []*RoutineTask{{Name: "Knead", Schedule: "later"}}}}},
+	}
+	for _, export := range exports {
+		report, planned, err := PlanImport(&Scheduler{Author: "u1"}, export, ImportReplace, nil)
+		if err != nil || len(report.Errors) == 0 || planned != nil {
+			t.Errorf("Invalid import was accepted: %+v %+v", export.Tasks, export.Routines)
+		}
+	}
+}
+
+func TestPlanImport_Assignees(t *testing.T) {
+	team := &Team{Id: "kitchen", Name: "Kitchen", Members: []*TeamMember{{UserId: "ann", Name: "Ann", Role: RoleEditor}}}
+	assigned := &ScheduleExport{Tasks: []*Task{{Id: "tea", Name: "Tea", Schedule: "in 5min", Assignees: []*TaskAssignee{{UserId: "ann", Name: "Old name"}}}}}
+
+	report, planned, err := PlanImport(&Scheduler{Author: team.Id}, assigned, ImportReplace, team)
+	if err != nil || !report.Ok() {
+		t.Fatalf("Import with a member as assignee was rejected: %v %+v", err, report)
+	}
+	if assignees := planned.Tasks[0].Assignees; len(assignees) != 1 || assignees[0].Name != "Ann" {
+		t.Errorf("Imported assignees were incorrect, got: %+v, want: Ann of the team.", assignees)
+	}
+
+	outsider := &ScheduleExport{Tasks: []*Task{{Id: "tea", Name: "Tea", Schedule: "in 5min", Assignees: []*TaskAssignee{{UserId: "mallory"}}}}}
+	report, planned, _ = PlanImport(&Scheduler{Author: team.Id}, outsider, ImportReplace, team)
+	if len(report.Errors) != 1 || report.Errors[0].Id != "tea" || planned != nil {
+		t.Errorf("Import with an outsider as assignee was incorrect, got: %+v, want: an error for tea and no plan.", report.Errors)
+	}
+}
+
+func TestPlanImport_AssigneesOfPersonalSchedule(t *testing.T) {
+	export := &ScheduleExport{Tasks: []*Task{{Id: "tea", Name: "Tea", Schedule: "in 5min", Assignees: []*TaskAssignee{{UserId: "ann"}}}}}
+	for _, mode := range []string{ImportReplace, ImportMerge} {
+		report, planned, err := PlanImport(&Scheduler{Author: "ann"}, export, mode, nil)
+		if err != nil || len(report.Errors) != 1 || report.Errors[0].Id != "tea" || planned != nil {
+			t.Errorf("%s with assignees on a personal schedule was incorrect, got: %v %+v, want: an error for tea and no plan.", mode, err, report.Errors)
+		}
+	}
+}
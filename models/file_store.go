@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// FileStore is a Store keeping everything in a single JSON file, for small single instance deployments.
+// The file is rewritten on every change, atomically by renaming a fully written temporary file over it.
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+// NewFileStore loads the store from the file at path, a missing file starts an empty store
+func NewFileStore(path string) (*FileStore, error) {
+	store := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(content) > 0 {
+		data := newStoreData()
+		if err := json.Unmarshal(content, data); err != nil {
+			return nil, err
+		}
+		data.restoreOwners()
+		store.data = data
+	}
+
+	store.persist = store.write
+	return store, nil
+}
+
+func (s *FileStore) write(data *storeData) error {
+	content, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type TaskPriority int
+
+const (
+	PriorityLow    TaskPriority = -1
+	PriorityNormal TaskPriority = 0
+	PriorityHigh   TaskPriority = 1
+)
+
+// IsValid reports whether the priority is one of the defined levels
+func (p TaskPriority) IsValid() bool {
+	return p >= PriorityLow && p <= PriorityHigh
+}
+
+func (p TaskPriority) String() string {
+	switch {
+	case p < PriorityNormal:
+		return "low"
+	case p > PriorityNormal:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+const (
+	TaskStateActive   = "active"
+	TaskStateInactive = "inactive"
+	TaskStatePaused   = "paused"
+)
+
+const (
+	SortByRemaining = "remaining"
+	SortByName      = "name"
+	SortByPriority  = "priority"
+)
+
+var ErrInvalidPriorityFilter = errors.New("priority filter must be low, normal or high")
+
+// TaskFilter narrows down and orders the task list. Empty fields match every task.
+// It binds from the query of GET /tasks as well as from the filter inputs included in htmx requests.
+type TaskFilter struct {
+	Tag      string      `form:"tag"`
+	Trigger  TaskTrigger `form:"trigger"`
+	State    string      `form:"state"`
+	Priority string      `form:"priority"`
+	Sort     string      `form:"sort"`
+}
+
+// Validate rejects a priority the filter could never match
+func (filter *TaskFilter) Validate() error {
+	switch filter.Priority {
+	case "", PriorityLow.String(), PriorityNormal.String(), PriorityHigh.String():
+		return nil
+	}
+	return ErrInvalidPriorityFilter
+}
+
+func (filter *TaskFilter) Matches(task *Task) bool {
+	if filter.Tag != "" && !slices.Contains(task.Tags, strings.ToLower(filter.Tag)) {
+		return false
+	}
+	if filter.Trigger != "" && task.Trigger != filter.Trigger {
+		return false
+	}
+	if filter.Priority != "" && task.Priority.String() != filter.Priority {
+		return false
+	}
+
+	switch filter.State {
+	case TaskStateActive:
+		return task.IsActive()
+	case TaskStateInactive:
+		return !task.IsActive() && !task.IsPaused()
+	case TaskStatePaused:
+		return task.IsPaused()
+	}
+
+	return true
+}
+
+// FilterTasks returns the matching tasks in the order requested by the filter
+func FilterTasks(tasks []*Task, filter *TaskFilter) []*Task {
+	filtered := make([]*Task, 0, len(tasks))
+	for _, task := range tasks {
+		if filter.Matches(task) {
+			filtered = append(filtered, task)
+		}
+	}
+
+	SortTasksBy(filtered, filter.Sort)
+
+	return filtered
+}
+
+// SortTasksBy orders tasks by name or priority, anything else falls back to the remaining time order of SortTasks.
+// Ties are broken by the remaining time order.
+func SortTasksBy(tasks []*Task, sortBy string) {
+	SortTasks(tasks)
+
+	switch sortBy {
+	case SortByName:
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return strings.ToLower(tasks[i].Name) < strings.ToLower(tasks[j].Name)
+		})
+	case SortByPriority:
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return tasks[i].Priority > tasks[j].Priority
+		})
+	}
+}
+
+// ParseTags splits a comma separated tag input into normalized, unique tags
+func ParseTags(input string) []string {
+	var tags []string
+	for _, tag := range strings.Split(input, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// CollectTags returns all distinct tags used by the tasks in alphabetical order
+func CollectTags(tasks []*Task) []string {
+	var tags []string
+	for _, task := range tasks {
+		for _, tag := range task.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// GetFilteredViewTasks applies the filter to the tasks and returns the view models.
+// Predecessor names are resolved from all tasks, so chains to filtered out tasks stay readable.
+func GetFilteredViewTasks(tasks []*Task, filter *TaskFilter) []*TaskVM {
+	return getViewTasks(FilterTasks(tasks, filter), tasks)
+}
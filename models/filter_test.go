@@ -0,0 +1,70 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func filterTasks() []*Task {
+	activatedTime := time.Now()
+	pausedRemaining := 5 * time.Minute
+	return []*Task{
+		{Id: "1", Name: "Deploy", Schedule: "in 1h", Trigger: Audio, Tags: []string{"work"}, Priority: PriorityHigh, ActivatedTime: &activatedTime},
+		{Id: "2", Name: "Stir pot", Schedule: "in 20min", Trigger: Popup, Tags: []string{"kitchen"}, PausedRemaining: &pausedRemaining},
+		{Id: "3", Name: "Backup", Schedule: "every 2h", Trigger: Popup, Tags: []string{"work", "ops"}, Priority: PriorityLow},
+		{Id: "4", Name: "Coffee", Schedule: "in 5min", Trigger: Popup, ActivatedTime: &activatedTime},
+	}
+}
+
+func taskIds(tasks []*Task) []string {
+	var ids []string
+	for _, task := range tasks {
+		ids = append(ids, task.Id)
+	}
+	return ids
+}
+
+func TestFilterTasks(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter TaskFilter
+		want   []string
+	}{
+		{"no filter sorts by remaining time", TaskFilter{}, []string{"4", "1", "2", "3"}},
+		{"tag", TaskFilter{Tag: "Work"}, []string{"1", "3"}},
+		{"trigger", TaskFilter{Trigger: Audio}, []string{"1"}},
+		{"active", TaskFilter{State: TaskStateActive}, []string{"4", "1"}},
+		{"inactive", TaskFilter{State: TaskStateInactive}, []string{"3"}},
+		{"paused", TaskFilter{State: TaskStatePaused}, []string{"2"}},
+		{"priority", TaskFilter{Priority: "normal"}, []string{"4", "2"}},
+		{"sort by priority", TaskFilter{Sort: SortByPriority}, []string{"1", "4", "2", "3"}},
+		{"sort by name", TaskFilter{Tag: "work", Sort: SortByName}, []string{"3", "1"}},
+	}
+
+	for _, test := range tests {
+		got := taskIds(FilterTasks(filterTasks(), &test.filter))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: filtered tasks were incorrect, got: %v, want: %v.", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTaskFilter_Validate(t *testing.T) {
+	for _, priority := range []string{"", "low", "normal", "high"} {
+		if err := (&TaskFilter{Priority: priority}).Validate(); err != nil {
+			t.Errorf("Priority filter %q was incorrect, got: %v, want: %v.", priority, err, nil)
+		}
+	}
+	if err := (&TaskFilter{Priority: "urgent"}).Validate(); err != ErrInvalidPriorityFilter {
+		t.Errorf("Unknown priority filter was incorrect, got: %v, want: %v.", err, ErrInvalidPriorityFilter)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	got := ParseTags(" Work, ops,,work ")
+	want := []string{"work", "ops"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tags were incorrect, got: %v, want: %v.", got, want)
+	}
+}
@@ -0,0 +1,355 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// storedUser keeps the fields the JSON of a user leaves out, so the FileStore doesn't lose them
+type storedUser struct {
+	User
+	PasswordHash string `json:"passwordHash,omitempty"`
+	OidcSubject  string `json:"oidcSubject,omitempty"`
+	WebhookUrl   string `json:"webhookUrl,omitempty"`
+}
+
+func newStoredUser(user *User) *storedUser {
+	return &storedUser{User: *user, PasswordHash: user.PasswordHash, OidcSubject: user.OidcSubject, WebhookUrl: user.WebhookUrl}
+}
+
+func (stored *storedUser) user() *User {
+	user := stored.User
+	user.PasswordHash = stored.PasswordHash
+	user.OidcSubject = stored.OidcSubject
+	user.WebhookUrl = stored.WebhookUrl
+	return &user
+}
+
+// storedToken keeps the fields the JSON of a token leaves out, like storedUser
+type storedToken struct {
+	ApiToken
+	UserId string `json:"userId"`
+	Hash   string `json:"hash"`
+}
+
+func newStoredToken(token *ApiToken) *storedToken {
+	return &storedToken{ApiToken: *token, UserId: token.UserId, Hash: token.Hash}
+}
+
+func (stored *storedToken) token() *ApiToken {
+	token := stored.ApiToken
+	token.UserId = stored.UserId
+	token.Hash = stored.Hash
+	token.Scopes = slices.Clone(token.Scopes)
+	return &token
+}
+
+// The accounts follow the schedules: changes never modify the stored values, they replace them in the copy of the data
+
+func (s *MemoryStore) findUser(match func(user *storedUser) bool) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := slices.IndexFunc(s.data.Users, match)
+	if index < 0 {
+		return nil, ErrNotFound
+	}
+	return s.data.Users[index].user(), nil
+}
+
+func (s *MemoryStore) GetUserById(id string) (*User, error) {
+	return s.findUser(func(user *storedUser) bool { return user.Id == id })
+}
+
+func (s *MemoryStore) GetUserByName(name string) (*User, error) {
+	return s.findUser(func(user *storedUser) bool { return user.Name == name })
+}
+
+func (s *MemoryStore) GetUserByOidcSubject(subject string) (*User, error) {
+	return s.findUser(func(user *storedUser) bool { return subject != "" && user.OidcSubject == subject })
+}
+
+func (s *MemoryStore) InsertUser(user *User) (*User, error) {
+	err := s.update(func(data *storeData) error {
+		if data.userTaken(user, "") {
+			return ErrUserExists
+		}
+		data.Users = append(data.Users, newStoredUser(user))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *MemoryStore) ClaimLegacyUser(claim *User) (*User, error) {
+	var claimed *User
+	err := s.update(func(data *storeData) error {
+		if data.userTaken(claim, LegacyAuthor) {
+			return ErrUserExists
+		}
+
+		index := slices.IndexFunc(data.Users, func(user *storedUser) bool { return user.Id == LegacyAuthor })
+		legacy := &User{Id: LegacyAuthor, CreatedAt: time.Now()}
+		if index >= 0 {
+			legacy = data.Users[index].user()
+			if legacy.PasswordHash != "" || legacy.OidcSubject != "" {
+				return ErrUserExists
+			}
+		}
+
+		legacy.Name = claim.Name
+		legacy.PasswordHash = claim.PasswordHash
+		legacy.OidcSubject = claim.OidcSubject
+		if index >= 0 {
+			data.Users[index] = newStoredUser(legacy)
+		} else {
+			data.Users = append(data.Users, newStoredUser(legacy))
+		}
+		claimed = legacy
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// userTaken tells if another user than the one with the id has the id, name or OIDC subject of the user
+func (data *storeData) userTaken(user *User, id string) bool {
+	return slices.ContainsFunc(data.Users, func(existing *storedUser) bool {
+		if existing.Id == id {
+			return false
+		}
+		return existing.Id == user.Id || existing.Name == user.Name || (user.OidcSubject != "" && existing.OidcSubject == user.OidcSubject)
+	})
+}
+
+func (s *MemoryStore) UpdateWebhookUrl(userId string, webhookUrl string) error {
+	return s.update(func(data *storeData) error {
+		for i, stored := range data.Users {
+			if stored.Id == userId {
+				user := stored.user()
+				user.WebhookUrl = webhookUrl
+				data.Users[i] = newStoredUser(user)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) CreateSession(userId string, token string, csrfToken string) (*Session, error) {
+	session := newSession(userId, token, csrfToken)
+	err := s.update(func(data *storeData) error {
+		// expired sessions are removed on the way, like the TTL index of the sessions collection
+		now := time.Now()
+		data.Sessions = slices.DeleteFunc(slices.Clone(data.Sessions), func(existing *Session) bool { return !existing.ExpiresAt.After(now) })
+		copied := *session
+		data.Sessions = append(data.Sessions, &copied)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *MemoryStore) GetSession(token string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := HashToken(token)
+	now := time.Now()
+	for _, session := range s.data.Sessions {
+		if session.Id == id && session.ExpiresAt.After(now) {
+			copied := *session
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) DeleteSession(token string) error {
+	id := HashToken(token)
+	return s.update(func(data *storeData) error {
+		data.Sessions = slices.DeleteFunc(slices.Clone(data.Sessions), func(session *Session) bool { return session.Id == id })
+		return nil
+	})
+}
+
+func (s *MemoryStore) CreateToken(userId string, name string, scopes []TokenScope) (*ApiToken, string, error) {
+	token, plainToken := newApiToken(userId, name, scopes)
+	err := s.update(func(data *storeData) error {
+		data.Tokens = append(data.Tokens, newStoredToken(token))
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return token, plainToken, nil
+}
+
+func (s *MemoryStore) GetToken(plainToken string) (*ApiToken, error) {
+	hash := HashToken(plainToken)
+	var found *ApiToken
+	err := s.update(func(data *storeData) error {
+		index := slices.IndexFunc(data.Tokens, func(token *storedToken) bool { return token.Hash == hash })
+		if index < 0 {
+			return ErrNotFound
+		}
+		// the token is returned as it was before this use, like the update of TokenDBModel
+		found = data.Tokens[index].token()
+		used := data.Tokens[index].token()
+		lastUsedAt := time.Now()
+		used.LastUsedAt = &lastUsedAt
+		data.Tokens[index] = newStoredToken(used)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+func (s *MemoryStore) GetTokensByUser(userId string) ([]*ApiToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := []*ApiToken{}
+	for _, token := range s.data.Tokens {
+		if token.UserId == userId {
+			tokens = append(tokens, token.token())
+		}
+	}
+	slices.SortStableFunc(tokens, func(a, b *ApiToken) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return tokens, nil
+}
+
+func (s *MemoryStore) DeleteToken(userId string, id string) error {
+	return s.update(func(data *storeData) error {
+		data.Tokens = slices.DeleteFunc(slices.Clone(data.Tokens), func(token *storedToken) bool {
+			return token.UserId == userId && token.Id == id
+		})
+		return nil
+	})
+}
+
+func (s *MemoryStore) InsertTeam(team *Team) (*Team, error) {
+	stored, err := cloneJSON(team)
+	if err != nil {
+		return nil, err
+	}
+	err = s.update(func(data *storeData) error {
+		data.Teams = append(data.Teams, stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (s *MemoryStore) GetTeam(id string) (*Team, error) {
+	teams, err := s.findTeams(func(team *Team) bool { return team.Id == id })
+	if err != nil {
+		return nil, err
+	}
+	if len(teams) == 0 {
+		return nil, ErrNotFound
+	}
+	return teams[0], nil
+}
+
+func (s *MemoryStore) GetTeamsByMember(userId string) ([]*Team, error) {
+	return s.findTeams(func(team *Team) bool { return team.Role(userId) != "" })
+}
+
+func (s *MemoryStore) GetTeamsByInvitation(userId string) ([]*Team, error) {
+	return s.findTeams(func(team *Team) bool { return team.hasInvitation(userId, "") })
+}
+
+func (s *MemoryStore) FindInvitationTeam(userId string, invitationId string) (*Team, error) {
+	teams, err := s.findTeams(func(team *Team) bool { return team.hasInvitation(userId, invitationId) })
+	if err != nil {
+		return nil, err
+	}
+	if len(teams) == 0 {
+		return nil, ErrInvitationNotFound
+	}
+	return teams[0], nil
+}
+
+// findTeams returns copies of the matching teams, sorted by name
+func (s *MemoryStore) findTeams(match func(team *Team) bool) ([]*Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	teams := []*Team{}
+	for _, team := range s.data.Teams {
+		if match(team) {
+			copied, err := cloneJSON(team)
+			if err != nil {
+				return nil, err
+			}
+			teams = append(teams, copied)
+		}
+	}
+	sortTeams(teams)
+	return teams, nil
+}
+
+func (s *MemoryStore) AddInvitation(teamId string, invitation *TeamInvitation) (*Team, error) {
+	return s.updateTeam(teamId, func(team *Team) error { return team.addInvitation(invitation) })
+}
+
+func (s *MemoryStore) AcceptInvitation(userId string, invitationId string) (*Team, error) {
+	team, err := s.FindInvitationTeam(userId, invitationId)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateTeam(team.Id, func(team *Team) error { return team.acceptInvitation(userId, invitationId) })
+}
+
+func (s *MemoryStore) DeleteInvitation(teamId string, invitationId string) (*Team, error) {
+	return s.updateTeam(teamId, func(team *Team) error {
+		team.deleteInvitation(invitationId)
+		return nil
+	})
+}
+
+func (s *MemoryStore) UpdateMemberRole(teamId string, userId string, role TeamRole) (*Team, error) {
+	return s.updateTeam(teamId, func(team *Team) error { return team.setMemberRole(userId, role) })
+}
+
+func (s *MemoryStore) RemoveMember(teamId string, userId string) (*Team, error) {
+	return s.updateTeam(teamId, func(team *Team) error {
+		team.removeMember(userId)
+		return nil
+	})
+}
+
+// updateTeam replaces the team with a changed copy and returns another copy of it
+func (s *MemoryStore) updateTeam(teamId string, change func(team *Team) error) (*Team, error) {
+	var updated *Team
+	err := s.update(func(data *storeData) error {
+		index := slices.IndexFunc(data.Teams, func(team *Team) bool { return team.Id == teamId })
+		if index < 0 {
+			return ErrNotFound
+		}
+		team, err := cloneJSON(data.Teams[index])
+		if err != nil {
+			return err
+		}
+		if err := change(team); err != nil {
+			return err
+		}
+		data.Teams[index] = team
+		updated, err = cloneJSON(team)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
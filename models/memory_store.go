@@ -0,0 +1,394 @@
+package models
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// storeData is everything a MemoryStore holds, it's also the format of the FileStore
+type storeData struct {
+	Schedules []*Scheduler `json:"schedules"`
+	// Occurrences are kept per owner, the owner of an occurrence isn't part of its JSON
+	Occurrences map[string][]*TaskOccurrence `json:"occurrences"`
+	// Trash holds the deleted tasks per owner, the last deleted last
+	Trash map[string][]*DeletedTask `json:"trash,omitempty"`
+	// Audit holds the audit entries per owner like the occurrences, oldest first
+	Audit map[string][]*AuditEntry `json:"audit,omitempty"`
+
+	// Users, Sessions, Tokens and Teams are the accounts, see memory_accounts.go
+	Users    []*storedUser  `json:"users,omitempty"`
+	Sessions []*Session     `json:"sessions,omitempty"`
+	Tokens   []*storedToken `json:"tokens,omitempty"`
+	Teams    []*Team        `json:"teams,omitempty"`
+}
+
+// copy is the snapshot a change works on. It only copies the collections, the schedules, trash, logs and accounts
+// in them are shared with the data: changes replace a schedule with editSchedule and a trash or an account with
+// a changed copy, while the append-only logs are only ever appended to.
+func (data *storeData) copy() *storeData {
+	return &storeData{
+		Schedules:   slices.Clone(data.Schedules),
+		Occurrences: maps.Clone(data.Occurrences),
+		Trash:       maps.Clone(data.Trash),
+		Audit:       maps.Clone(data.Audit),
+		Users:       slices.Clone(data.Users),
+		Sessions:    slices.Clone(data.Sessions),
+		Tokens:      slices.Clone(data.Tokens),
+		Teams:       slices.Clone(data.Teams),
+	}
+}
+
+func (data *storeData) restoreOwners() {
+	for owner, occurrences := range data.Occurrences {
+		for _, occurrence := range occurrences {
+			occurrence.Owner = owner
+		}
+	}
+	for owner, entries := range data.Audit {
+		for _, entry := range entries {
+			entry.Owner = owner
+		}
+	}
+}
+
+func newStoreData() *storeData {
+	return &storeData{
+		Schedules:   []*Scheduler{},
+		Occurrences: map[string][]*TaskOccurrence{},
+		Trash:       map[string][]*DeletedTask{},
+		Audit:       map[string][]*AuditEntry{},
+	}
+}
+
+func (data *storeData) schedule(author string) *Scheduler {
+	for _, scheduler := range data.Schedules {
+		if scheduler.Author == author {
+			return scheduler
+		}
+	}
+	return nil
+}
+
+// editSchedule replaces the schedule of the author with a copy the change can modify, adding an empty one if there is none
+func (data *storeData) editSchedule(author string) (*Scheduler, error) {
+	for i, scheduler := range data.Schedules {
+		if scheduler.Author == author {
+			copied, err := cloneSchedule(scheduler)
+			if err != nil {
+				return nil, err
+			}
+			data.Schedules[i] = copied
+			return copied, nil
+		}
+	}
+
+	scheduler := &Scheduler{Author: author, Tasks: []*Task{}}
+	data.Schedules = append(data.Schedules, scheduler)
+	return scheduler, nil
+}
+
+// MemoryStore is a Store keeping everything in memory, for tests and throwaway instances
+type MemoryStore struct {
+	mu   sync.Mutex
+	data *storeData
+	// persist is called with every changed data before it replaces the current one, see FileStore
+	persist func(data *storeData) error
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: newStoreData()}
+}
+
+// update applies the change to a copy of the data, which only replaces the data if the change and persisting succeed
+func (s *MemoryStore) update(change func(data *storeData) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.data.copy()
+	if err := change(next); err != nil {
+		return err
+	}
+	if s.persist != nil {
+		if err := s.persist(next); err != nil {
+			return err
+		}
+	}
+
+	s.data = next
+	return nil
+}
+
+// updateSchedule is update for changes of a single schedule, it returns a copy of the changed schedule
+func (s *MemoryStore) updateSchedule(author string, change func(scheduler *Scheduler) error) (*Scheduler, error) {
+	var updated *Scheduler
+	err := s.update(func(data *storeData) error {
+		scheduler, err := data.editSchedule(author)
+		if err != nil {
+			return err
+		}
+		if err := change(scheduler); err != nil {
+			return err
+		}
+		// detach the tasks and routines handed in by the caller
+		stored, err := cloneSchedule(scheduler)
+		if err != nil {
+			return err
+		}
+		*scheduler = *stored
+		updated, err = cloneSchedule(stored)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// cloneSchedule deep copies the schedule through its JSON representation, the same way the FileStore sees it
+func cloneSchedule(scheduler *Scheduler) (*Scheduler, error) {
+	return cloneJSON(scheduler)
+}
+
+func cloneJSON[T any](value T) (T, error) {
+	var copied T
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return copied, err
+	}
+	err = json.Unmarshal(encoded, &copied)
+	return copied, err
+}
+
+// GetScheduleByAuthor never writes, authors without a schedule get an empty one that is stored on the first change
+func (s *MemoryStore) GetScheduleByAuthor(author string) (*Scheduler, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scheduler := s.data.schedule(author)
+	if scheduler == nil {
+		return &Scheduler{Author: author, Tasks: []*Task{}}, nil
+	}
+	return cloneSchedule(scheduler)
+}
+
+func (s *MemoryStore) GetAllSchedules() ([]*Scheduler, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedules := make([]*Scheduler, 0, len(s.data.Schedules))
+	for _, scheduler := range s.data.Schedules {
+		copied, err := cloneSchedule(scheduler)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, copied)
+	}
+	return schedules, nil
+}
+
+func (s *MemoryStore) ReplaceSchedule(scheduler *Scheduler) error {
+	replacement, err := cloneSchedule(scheduler)
+	if err != nil {
+		return err
+	}
+	return s.update(func(data *storeData) error {
+		for i, existing := range data.Schedules {
+			if existing.Author == replacement.Author {
+				data.Schedules[i] = replacement
+				return nil
+			}
+		}
+		data.Schedules = append(data.Schedules, replacement)
+		return nil
+	})
+}
+
+func (s *MemoryStore) InsertTask(author string, task *Task) (*Scheduler, error) {
+	return s.updateSchedule(author, func(scheduler *Scheduler) error {
+		scheduler.Tasks = append(scheduler.Tasks, task)
+		return nil
+	})
+}
+
+func (s *MemoryStore) UpdateTask(author string, task *Task) (*Scheduler, error) {
+	return s.updateSchedule(author, func(scheduler *Scheduler) error {
+		index := slices.IndexFunc(scheduler.Tasks, func(existing *Task) bool { return existing.Id == task.Id })
+		if index < 0 {
+			return ErrTaskNotFound
+		}
+		scheduler.Tasks[index] = task
+		return nil
+	})
+}
+
+func (s *MemoryStore) UpdateTaskActivatedTime(author string, task *Task) error {
+	_, err := s.updateSchedule(author, func(scheduler *Scheduler) error {
+		existing := scheduler.FindTask(task.Id)
+		if existing == nil {
+			return ErrTaskNotFound
+		}
+		existing.ActivatedTime = task.ActivatedTime
+		return nil
+	})
+	return err
+}
+
+func (s *MemoryStore) DeleteTasks(author string, taskIds []string) (*Scheduler, error) {
+	var updated *Scheduler
+	err := s.update(func(data *storeData) error {
+		scheduler, err := data.editSchedule(author)
+		if err != nil {
+			return err
+		}
+		deletedAt := time.Now()
+		trash := slices.Clone(data.Trash[author])
+		scheduler.Tasks = slices.DeleteFunc(scheduler.Tasks, func(task *Task) bool {
+			if !slices.Contains(taskIds, task.Id) {
+				return false
+			}
+			trash = append(trash, &DeletedTask{Task: task, DeletedAt: deletedAt})
+			return true
+		})
+		data.Trash[author] = trash
+		updated, err = cloneSchedule(scheduler)
+		return err
+	})
+	return updated, err
+}
+
+func (s *MemoryStore) GetTrash(author string) ([]*DeletedTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trash, err := cloneJSON(s.data.Trash[author])
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(trash)
+	if trash == nil {
+		trash = []*DeletedTask{}
+	}
+	return trash, nil
+}
+
+func (s *MemoryStore) RestoreTasks(author string, taskIds []string) (*Scheduler, error) {
+	var updated *Scheduler
+	err := s.update(func(data *storeData) error {
+		scheduler, err := data.editSchedule(author)
+		if err != nil {
+			return err
+		}
+		data.Trash[author] = slices.DeleteFunc(slices.Clone(data.Trash[author]), func(deleted *DeletedTask) bool {
+			if !slices.Contains(taskIds, deleted.Task.Id) || scheduler.FindTask(deleted.Task.Id) != nil {
+				return false
+			}
+			scheduler.Tasks = append(scheduler.Tasks, deleted.Task)
+			return true
+		})
+		updated, err = cloneSchedule(scheduler)
+		return err
+	})
+	return updated, err
+}
+
+func (s *MemoryStore) PurgeTrash(before time.Time) (int64, error) {
+	var purged int64
+	err := s.update(func(data *storeData) error {
+		for owner, trash := range data.Trash {
+			kept := slices.DeleteFunc(slices.Clone(trash), func(deleted *DeletedTask) bool { return deleted.DeletedAt.Before(before) })
+			purged += int64(len(trash) - len(kept))
+			data.Trash[owner] = kept
+		}
+		return nil
+	})
+	return purged, err
+}
+
+func (s *MemoryStore) ReplaceTrash(author string, trash []*DeletedTask) error {
+	replaced, err := cloneJSON(trash)
+	if err != nil {
+		return err
+	}
+	// the store keeps the last deleted last
+	slices.Reverse(replaced)
+	return s.update(func(data *storeData) error {
+		if _, err := data.editSchedule(author); err != nil {
+			return err
+		}
+		data.Trash[author] = replaced
+		return nil
+	})
+}
+
+func (s *MemoryStore) InsertRoutine(author string, routine *Routine) (*Scheduler, error) {
+	return s.updateSchedule(author, func(scheduler *Scheduler) error {
+		scheduler.Routines = append(scheduler.Routines, routine)
+		return nil
+	})
+}
+
+func (s *MemoryStore) DeleteRoutine(author string, routineId string) (*Scheduler, error) {
+	return s.updateSchedule(author, func(scheduler *Scheduler) error {
+		scheduler.Routines = slices.DeleteFunc(scheduler.Routines, func(routine *Routine) bool {
+			return routine.Id == routineId
+		})
+		return nil
+	})
+}
+
+func (s *MemoryStore) InsertOccurrence(occurrence *TaskOccurrence) error {
+	return s.update(func(data *storeData) error {
+		copied := *occurrence
+		data.Occurrences[occurrence.Owner] = append(data.Occurrences[occurrence.Owner], &copied)
+		return nil
+	})
+}
+
+func (s *MemoryStore) GetOccurrences(owner string, taskId string, offset int, limit int) ([]*TaskOccurrence, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	occurrences := []*TaskOccurrence{}
+	for _, occurrence := range s.data.Occurrences[owner] {
+		if taskId == "" || occurrence.TaskId == taskId {
+			copied := *occurrence
+			occurrences = append(occurrences, &copied)
+		}
+	}
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].At.After(occurrences[j].At)
+	})
+
+	page := Paginate(occurrences, PageParams{Offset: offset, Limit: limit})
+	return page.Data, page.Pagination.Total, nil
+}
+
+func (s *MemoryStore) InsertAuditEntry(entry *AuditEntry) error {
+	return s.update(func(data *storeData) error {
+		copied := *entry
+		data.Audit[entry.Owner] = append(data.Audit[entry.Owner], &copied)
+		return nil
+	})
+}
+
+func (s *MemoryStore) GetAuditEntries(owner string, filter *AuditFilter, offset int, limit int) ([]*AuditEntry, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := []*AuditEntry{}
+	for _, entry := range s.data.Audit[owner] {
+		if filter.Matches(entry) {
+			copied := *entry
+			entries = append(entries, &copied)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].At.After(entries[j].At)
+	})
+
+	page := Paginate(entries, PageParams{Offset: offset, Limit: limit})
+	return page.Data, page.Pagination.Total, nil
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"scheduler/utils"
+)
+
+// Migration changes the stored documents from the previous schema version to Version.
+// Up may be interrupted before the new version is recorded, so it has to be safe to run again.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(client *mongo.Client) error
+	// Plan describes what Up would change without changing anything, it's used by dry runs and optional
+	Plan func(client *mongo.Client) (string, error)
+}
+
+// Migrations are applied in order of their versions, which start at 1 and have no gaps.
+// Released migrations must never change: add a new one instead.
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "move embedded tasks to the tasks collection",
+		Up: func(client *mongo.Client) error {
+			_, err := TaskDBModel{Client: client}.MigrateEmbeddedTasks()
+			return err
+		},
+		Plan: func(client *mongo.Client) (string, error) {
+			count, err := client.Database("SchedulerCluster").Collection("schedules").
+				CountDocuments(context.Background(), bson.M{"tasks": bson.M{"$exists": true}})
+			return fmt.Sprintf("%d schedules with embedded tasks", count), err
+		},
+	},
+}
+
+var ErrMigrationLocked = errors.New("another instance is migrating")
+
+// MigrationState is the single document of the migrations collection
+type MigrationState struct {
+	Version int                `bson:"version"`
+	Applied []AppliedMigration `bson:"applied,omitempty"`
+	// LockedBy is the instance running the migrations, the lock expires at LockedUntil if the instance dies
+	LockedBy    string     `bson:"lockedBy,omitempty"`
+	LockedUntil *time.Time `bson:"lockedUntil,omitempty"`
+}
+
+type AppliedMigration struct {
+	Version   int       `bson:"version"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+const migrationStateId = "schema"
+
+// migrationLease is how long a lock is held at most, it has to outlast the slowest migration
+const migrationLease = 15 * time.Minute
+
+type MigrationDBModel struct {
+	Client *mongo.Client
+	// Migrations default to the Migrations of the package
+	Migrations []Migration
+	// stateId is the id of the state document, tests use their own to not share the state of the database
+	stateId string
+}
+
+func (m MigrationDBModel) migrations() []Migration {
+	if m.Migrations != nil {
+		return m.Migrations
+	}
+	return Migrations
+}
+
+func (m MigrationDBModel) stateDocumentId() string {
+	if m.stateId != "" {
+		return m.stateId
+	}
+	return migrationStateId
+}
+
+func (m MigrationDBModel) GetState() (*MigrationState, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "migrations"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	state := &MigrationState{}
+	err := collection.FindOne(ctx, bson.M{"_id": m.stateDocumentId()}).Decode(state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return state, nil
+	}
+	return state, err
+}
+
+// Pending returns the migrations above the stored version. It fails if the database is newer than this build.
+func (m MigrationDBModel) Pending() ([]Migration, error) {
+	state, err := m.GetState()
+	if err != nil {
+		return nil, err
+	}
+	return pendingMigrations(m.migrations(), state.Version)
+}
+
+// DryRun logs the pending migrations and their plans without changing anything
+func (m MigrationDBModel) DryRun() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range pending {
+		plan := ""
+		if migration.Plan != nil {
+			if plan, err = migration.Plan(m.Client); err != nil {
+				return nil, fmt.Errorf("planning migration %d: %w", migration.Version, err)
+			}
+		}
+		log.Info().Int("version", migration.Version).Str("plan", plan).Msgf("Would apply migration: %s", migration.Name)
+	}
+	if len(pending) == 0 {
+		log.Info().Msg("No pending migrations")
+	}
+	return pending, nil
+}
+
+// Migrate applies the pending migrations. Only one instance migrates at a time, the others wait for it to finish
+// and find nothing left to do. It returns the applied migrations.
+func (m MigrationDBModel) Migrate(wait time.Duration) ([]Migration, error) {
+	if err := validateMigrations(m.migrations()); err != nil {
+		return nil, err
+	}
+
+	owner := migrationLockOwner()
+	deadline := time.Now().Add(wait)
+	for {
+		err := m.lock(owner)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrMigrationLocked) || time.Now().After(deadline) {
+			return nil, err
+		}
+		log.Info().Msg("Waiting for another instance to finish the migrations")
+		time.Sleep(2 * time.Second)
+	}
+	defer func() {
+		if err := m.unlock(owner); err != nil {
+			log.Error().Err(err).Msg("Could not release the migration lock")
+		}
+	}()
+
+	// read after locking, the previous holder may have applied everything
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	for _, migration := range pending {
+		log.Info().Int("version", migration.Version).Msgf("Applying migration: %s", migration.Name)
+		if err := migration.Up(m.Client); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+		if err := m.setVersion(owner, migration); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// lock takes the migration lock if it's free or expired
+func (m MigrationDBModel) lock(owner string) error {
+	dbName := "SchedulerCluster"
+	collectionName := "migrations"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"_id": m.stateDocumentId(),
+		"$or": bson.A{
+			bson.M{"lockedUntil": bson.M{"$exists": false}},
+			bson.M{"lockedUntil": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"lockedBy": owner, "lockedUntil": now.Add(migrationLease)}}
+
+	// a locked state doesn't match the filter, so the upsert collides with the existing document
+	_, err := collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrMigrationLocked
+	}
+	return err
+}
+
+func (m MigrationDBModel) unlock(owner string) error {
+	dbName := "SchedulerCluster"
+	collectionName := "migrations"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": m.stateDocumentId(), "lockedBy": owner}
+	update := bson.M{"$unset": bson.M{"lockedBy": "", "lockedUntil": ""}}
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (m MigrationDBModel) setVersion(owner string, migration Migration) error {
+	dbName := "SchedulerCluster"
+	collectionName := "migrations"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": m.stateDocumentId(), "lockedBy": owner}
+	update := bson.M{
+		"$set":  bson.M{"version": migration.Version},
+		"$push": bson.M{"applied": AppliedMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}},
+	}
+	res, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("migration lock was lost before recording version %d", migration.Version)
+	}
+	return nil
+}
+
+func migrationLockOwner() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d/%s", hostname, os.Getpid(), utils.Uuid())
+}
+
+// validateMigrations checks that the versions count up from 1 without gaps
+func validateMigrations(migrations []Migration) error {
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return fmt.Errorf("migration <%s> has version %d, want %d", migration.Name, migration.Version, i+1)
+		}
+		if migration.Up == nil {
+			return fmt.Errorf("migration %d has no Up", migration.Version)
+		}
+	}
+	return nil
+}
+
+func pendingMigrations(migrations []Migration, version int) ([]Migration, error) {
+	if err := validateMigrations(migrations); err != nil {
+		return nil, err
+	}
+	if version > len(migrations) {
+		return nil, fmt.Errorf("database schema version %d is newer than this build, which knows %d", version, len(migrations))
+	}
+	return migrations[version:], nil
+}
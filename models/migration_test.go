@@ -0,0 +1,184 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"os"
+	"scheduler/utils"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMigrations_Valid(t *testing.T) {
+	if err := validateMigrations(Migrations); err != nil {
+		t.Errorf("Migrations were incorrect, got: %v, want: no error.", err)
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	up := func(client *mongo.Client) error { return nil }
+	migrations := []Migration{{Version: 1, Name: "one", Up: up}, {Version: 2, Name: "two", Up: up}}
+
+	tests := []struct {
+		version int
+		want    int
+	}{
+		{0, 2},
+		{1, 1},
+		{2, 0},
+	}
+	for _, test := range tests {
+		pending, err := pendingMigrations(migrations, test.version)
+		if err != nil || len(pending) != test.want {
+			t.Errorf("pendingMigrations(%v) was incorrect, got: %v %v, want: %v.", test.version, len(pending), err, test.want)
+		}
+	}
+
+	if _, err := pendingMigrations(migrations, 3); err == nil {
+		t.Errorf("pendingMigrations of a newer database was incorrect, got: %v, want: an error.", err)
+	}
+
+	gap := []Migration{{Version: 1, Name: "one", Up: up}, {Version: 3, Name: "three", Up: up}}
+	if _, err := pendingMigrations(gap, 0); err == nil {
+		t.Errorf("pendingMigrations with a gap was incorrect, got: %v, want: an error.", err)
+	}
+}
+
+// newMigrationTestModel runs the migrations on a state document of its own, it needs TEST_MONGO_URI
+func newMigrationTestModel(t *testing.T, migrations []Migration) MigrationDBModel {
+	mongoUri := os.Getenv("TEST_MONGO_URI")
+	if mongoUri == "" {
+		t.Skip("TEST_MONGO_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoUri))
+	if err != nil {
+		t.Fatalf("Connecting to MongoDB failed: %v", err)
+	}
+
+	m := MigrationDBModel{Client: client, Migrations: migrations, stateId: "migration-test-" + utils.Uuid()}
+	t.Cleanup(func() {
+		_, _ = m.collection().DeleteOne(context.Background(), bson.M{"_id": m.stateId})
+		_ = client.Disconnect(context.Background())
+	})
+	return m
+}
+
+func (m MigrationDBModel) collection() *mongo.Collection {
+	return m.Client.Database("SchedulerCluster").Collection("migrations")
+}
+
+// countingMigrations count how often each of them runs, slow enough that concurrent runs overlap
+func countingMigrations(runs []atomic.Int32) []Migration {
+	migrations := make([]Migration, len(runs))
+	for i := range runs {
+		i := i
+		migrations[i] = Migration{Version: i + 1, Name: "counting", Up: func(client *mongo.Client) error {
+			runs[i].Add(1)
+			time.Sleep(200 * time.Millisecond)
+			return nil
+		}}
+	}
+	return migrations
+}
+
+func TestMigrate_Concurrent(t *testing.T) {
+	runs := make([]atomic.Int32, 2)
+	m := newMigrationTestModel(t, countingMigrations(runs))
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = m.Migrate(time.Minute)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Migrate %d was incorrect, got: %v, want: no error.", i, err)
+		}
+	}
+	for i := range runs {
+		if got := runs[i].Load(); got != 1 {
+			t.Errorf("Runs of migration %d were incorrect, got: %v, want: %v.", i+1, got, 1)
+		}
+	}
+	state, err := m.GetState()
+	if err != nil || state.Version != 2 || len(state.Applied) != 2 || state.LockedBy != "" {
+		t.Errorf("State after migrating was incorrect, got: %+v %v, want: version 2 and unlocked.", state, err)
+	}
+}
+
+func TestMigrate_Lease(t *testing.T) {
+	m := newMigrationTestModel(t, countingMigrations(make([]atomic.Int32, 1)))
+
+	if err := m.lock("first"); err != nil {
+		t.Fatalf("Taking the free lock failed: %v", err)
+	}
+	if err := m.lock("second"); !errors.Is(err, ErrMigrationLocked) {
+		t.Errorf("Taking a held lock was incorrect, got: %v, want: %v.", err, ErrMigrationLocked)
+	}
+	if err := m.setVersion("second", m.Migrations[0]); err == nil {
+		t.Errorf("Recording a version without the lock was incorrect, got: %v, want: an error.", err)
+	}
+
+	// only the holder releases the lock
+	if err := m.unlock("second"); err != nil {
+		t.Fatalf("Unlocking failed: %v", err)
+	}
+	if state, _ := m.GetState(); state.LockedBy != "first" {
+		t.Errorf("Lock after a foreign unlock was incorrect, got: %v, want: %v.", state.LockedBy, "first")
+	}
+
+	// the lease of a dead instance runs out
+	expired := time.Now().Add(-time.Minute)
+	if _, err := m.collection().UpdateOne(context.Background(), bson.M{"_id": m.stateId}, bson.M{"$set": bson.M{"lockedUntil": expired}}); err != nil {
+		t.Fatalf("Expiring the lock failed: %v", err)
+	}
+	if err := m.lock("second"); err != nil {
+		t.Errorf("Taking an expired lock was incorrect, got: %v, want: no error.", err)
+	}
+	if err := m.setVersion("second", m.Migrations[0]); err != nil {
+		t.Errorf("Recording a version with the lock was incorrect, got: %v, want: no error.", err)
+	}
+	if err := m.unlock("second"); err != nil {
+		t.Fatalf("Unlocking failed: %v", err)
+	}
+	if state, _ := m.GetState(); state.Version != 1 || state.LockedBy != "" || state.LockedUntil != nil {
+		t.Errorf("State after unlocking was incorrect, got: %+v, want: version 1 and unlocked.", state)
+	}
+}
+
+func TestMigrate_DryRun(t *testing.T) {
+	runs := make([]atomic.Int32, 2)
+	migrations := countingMigrations(runs)
+	planned := 0
+	migrations[0].Plan = func(client *mongo.Client) (string, error) {
+		planned++
+		return "nothing", nil
+	}
+	m := newMigrationTestModel(t, migrations)
+
+	pending, err := m.DryRun()
+	if err != nil || len(pending) != 2 || planned != 1 {
+		t.Fatalf("DryRun was incorrect, got: %v %v %v, want: 2 pending and 1 plan.", len(pending), err, planned)
+	}
+	if runs[0].Load() != 0 || runs[1].Load() != 0 {
+		t.Errorf("DryRun ran migrations, got: %v %v, want: none.", runs[0].Load(), runs[1].Load())
+	}
+	if count, err := m.collection().CountDocuments(context.Background(), bson.M{"_id": m.stateId}); err != nil || count != 0 {
+		t.Errorf("DryRun wrote the state, got: %v %v, want: no document.", count, err)
+	}
+}
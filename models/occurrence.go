@@ -0,0 +1,115 @@
+package models
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"scheduler/utils"
+	"time"
+)
+
+type OccurrenceEvent string
+
+const (
+	OccurrenceFired   OccurrenceEvent = "fired"
+	OccurrenceDone    OccurrenceEvent = "done"
+	OccurrenceSnoozed OccurrenceEvent = "snoozed"
+)
+
+// TaskOccurrence is an entry in the history of a task, recorded whenever it fires or its alert is handled
+type TaskOccurrence struct {
+	Id       string          `json:"id" bson:"id"`
+	Owner    string          `json:"-" bson:"owner"`
+	TaskId   string          `json:"taskId" bson:"taskId"`
+	TaskName string          `json:"taskName" bson:"taskName"`
+	Event    OccurrenceEvent `json:"event" bson:"event"`
+	// UserId is the user who handled the alert, empty for events of the scheduler itself
+	UserId string    `json:"userId,omitempty" bson:"userId,omitempty"`
+	At     time.Time `json:"at" bson:"at"`
+}
+
+func NewTaskOccurrence(owner string, task *Task, event OccurrenceEvent, userId string) *TaskOccurrence {
+	return &TaskOccurrence{
+		Id:       utils.Uuid(),
+		Owner:    owner,
+		TaskId:   task.Id,
+		TaskName: task.Name,
+		Event:    event,
+		UserId:   userId,
+		At:       time.Now(),
+	}
+}
+
+type OccurrenceDBModel struct {
+	Client *mongo.Client
+}
+
+func (m OccurrenceDBModel) EnsureIndexes() error {
+	dbName := "SchedulerCluster"
+	collectionName := "occurrences"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "owner", Value: 1}, {Key: "taskId", Value: 1}, {Key: "at", Value: -1}},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to create occurrence indexes")
+	}
+	return err
+}
+
+func (m OccurrenceDBModel) InsertOccurrence(occurrence *TaskOccurrence) error {
+	dbName := "SchedulerCluster"
+	collectionName := "occurrences"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, occurrence)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to insert an occurrence")
+	}
+	return err
+}
+
+// GetOccurrences returns a page of the history of a task, newest first, together with the total count.
+// An empty taskId returns the history of every task of the schedule.
+func (m OccurrenceDBModel) GetOccurrences(owner string, taskId string, offset int, limit int) ([]*TaskOccurrence, int64, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "occurrences"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"owner": owner}
+	if taskId != "" {
+		filter["taskId"] = taskId
+	}
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to count occurrences")
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"at": -1}).SetSkip(int64(offset)).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to find occurrences")
+		return nil, 0, err
+	}
+
+	occurrences := []*TaskOccurrence{}
+	if err := cursor.All(ctx, &occurrences); err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to decode occurrences")
+		return nil, 0, err
+	}
+
+	return occurrences, total, nil
+}
@@ -0,0 +1,17 @@
+package models
+
+// PageData is shared by all full pages, the base header picks up the CSRF token for htmx requests
+type PageData struct {
+	User      *User
+	CsrfToken string
+}
+
+type AccountPageData struct {
+	PageData
+	Error string
+	Saved bool
+}
+
+type WebhookFormData struct {
+	WebhookUrl string `form:"webhook-url"`
+}
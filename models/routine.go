@@ -0,0 +1,158 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"scheduler/utils"
+	"strings"
+	"time"
+)
+
+var ErrEmptyRoutine = errors.New("routine has no tasks")
+
+// Routine is a named bundle of task definitions that are started together, e.g. a "morning routine"
+type Routine struct {
+	Id    string         `json:"id" bson:"id"`
+	Name  string         `json:"name" bson:"name"`
+	Tasks []*RoutineTask `json:"tasks" bson:"tasks"`
+}
+
+// RoutineTask is a task definition inside a routine. Offset delays the start relative to the routine start.
+type RoutineTask struct {
+	Name     string      `json:"name" bson:"name"`
+	Schedule string      `json:"schedule" bson:"schedule"`
+	Trigger  TaskTrigger `json:"trigger" bson:"trigger"`
+	Offset   string      `json:"offset,omitempty" bson:"offset,omitempty"`
+}
+
+type NewRoutineFormData struct {
+	Name  string `form:"routine-name" validate:"required"`
+	Tasks string `form:"routine-tasks" validate:"required"`
+}
+
+type RoutinesData struct {
+	Routines []*Routine
+	Error    string
+}
+
+// TaskName is the name of the task a routine member is instantiated as.
+// It's used to find the member task again when the routine is started another time.
+func (r *Routine) TaskName(member *RoutineTask) string {
+	return fmt.Sprintf("%s: %s", r.Name, member.Name)
+}
+
+func (member *RoutineTask) GetOffset() time.Duration {
+	offset, _ := utils.ParseDelay(member.Offset)
+	return offset
+}
+
+func (member *RoutineTask) ToNewTaskFormData(routine *Routine) *NewTaskFormData {
+	return &NewTaskFormData{
+		Name:     routine.TaskName(member),
+		Schedule: member.Schedule,
+		Trigger:  member.Trigger,
+	}
+}
+
+// ParseRoutineTasks parses the routine definition of the form, one task per line:
+// <name> | <schedule> | <trigger> | <offset>
+// Trigger defaults to popup and offset to no delay, e.g. "Stretch | in 5min | audio | 10min"
+func ParseRoutineTasks(input string) ([]*RoutineTask, error) {
+	var tasks []*RoutineTask
+
+	for i, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "|")
+		for j := range fields {
+			fields[j] = strings.TrimSpace(fields[j])
+		}
+		if len(fields) < 2 || len(fields) > 4 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid routine task in line %d <%s>", i+1, line)
+		}
+
+		task := &RoutineTask{Name: fields[0], Schedule: fields[1], Trigger: Popup}
+		if _, err := utils.ParseDuration(task.Schedule); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if len(fields) > 2 && fields[2] != "" {
+			task.Trigger = TaskTrigger(fields[2])
+			if task.Trigger != Popup && task.Trigger != Audio && task.Trigger != WebHook {
+				return nil, fmt.Errorf("invalid trigger in line %d <%s>", i+1, fields[2])
+			}
+		}
+		if len(fields) > 3 && fields[3] != "" {
+			if _, err := utils.ParseDelay(fields[3]); err != nil {
+				return nil, fmt.Errorf("invalid offset in line %d <%s>", i+1, fields[3])
+			}
+			task.Offset = fields[3]
+		}
+
+		tasks = append(tasks, task)
+	}
+
+	if len(tasks) == 0 {
+		return nil, ErrEmptyRoutine
+	}
+
+	return tasks, nil
+}
+
+func (m TaskDBModel) InsertRoutine(author string, routine *Routine) (*Scheduler, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "schedules"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	filter := bson.M{"author": author}
+	update := bson.M{
+		"$push": bson.M{"routines": routine},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(true)
+
+	updatedSchedule := &scheduleDocument{}
+
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(updatedSchedule)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to insert a routine")
+		return nil, err
+	}
+
+	return m.loadSchedule(ctx, updatedSchedule)
+}
+
+func (m TaskDBModel) DeleteRoutine(author string, routineId string) (*Scheduler, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "schedules"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	filter := bson.M{"author": author}
+	update := bson.M{
+		"$pull": bson.M{"routines": bson.M{"id": routineId}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	updatedSchedule := &scheduleDocument{}
+
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(updatedSchedule)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to delete a routine")
+		return nil, err
+	}
+
+	return m.loadSchedule(ctx, updatedSchedule)
+}
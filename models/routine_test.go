@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRoutineTasks(t *testing.T) {
+	input := `
+Preheat oven | in 10min
+Bake | in 25min | audio | 10min
+
+Cool | in 10min | popup | 35min
+`
+	tasks, err := ParseRoutineTasks(input)
+	if err != nil {
+		t.Fatalf("Valid routine was rejected: %v", err)
+	}
+	if len(tasks) != 3 {
+		t.Fatalf("Routine task count was incorrect, got: %d, want: %d.", len(tasks), 3)
+	}
+	if tasks[0].Trigger != Popup || tasks[0].GetOffset() != 0 {
+		t.Errorf("Defaults were not applied, got: %+v", tasks[0])
+	}
+	if tasks[1].Trigger != Audio || tasks[1].GetOffset() != 10*time.Minute {
+		t.Errorf("Routine task was parsed incorrectly, got: %+v", tasks[1])
+	}
+}
+
+func TestParseRoutineTasks_Invalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"Bake",
+		"Bake | soon",
+		"Bake | in 25min | email",
+		"Bake | in 25min | audio | later",
+	}
+	for _, input := range inputs {
+		if _, err := ParseRoutineTasks(input); err == nil {
+			t.Errorf("Invalid routine was accepted <%s>", input)
+		}
+	}
+}
+
+func TestRoutine_TaskName(t *testing.T) {
+	routine := &Routine{Name: "Morning"}
+	if name := routine.TaskName(&RoutineTask{Name: "Coffee"}); name != "Morning: Coffee" {
+		t.Errorf("Task name was incorrect, got: %s", name)
+	}
+}
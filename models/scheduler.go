@@ -3,4 +3,36 @@ package models
 type Scheduler struct {
 	Author string
 	Tasks  []*Task `json:"tasks"`
+	// Routines are reusable bundles of task definitions
+	Routines []*Routine `json:"routines,omitempty" bson:"routines,omitempty"`
+}
+
+// FindTask returns the task with the given id or nil if the schedule does not contain it
+func (s *Scheduler) FindTask(id string) *Task {
+	for _, task := range s.Tasks {
+		if task.Id == id {
+			return task
+		}
+	}
+	return nil
+}
+
+// FindTaskByName returns the first task with the given name or nil if the schedule does not contain it
+func (s *Scheduler) FindTaskByName(name string) *Task {
+	for _, task := range s.Tasks {
+		if task.Name == name {
+			return task
+		}
+	}
+	return nil
+}
+
+// FindRoutine returns the routine with the given id or nil if the schedule does not contain it
+func (s *Scheduler) FindRoutine(id string) *Routine {
+	for _, routine := range s.Routines {
+		if routine.Id == id {
+			return routine
+		}
+	}
+	return nil
 }
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const SessionDuration = 30 * 24 * time.Hour
+
+// Session is a logged-in browser. Only the hash of the session token is stored, the token itself lives in the cookie.
+type Session struct {
+	Id        string    `bson:"id"`
+	UserId    string    `bson:"userId"`
+	CsrfToken string    `bson:"csrfToken"`
+	CreatedAt time.Time `bson:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+type AuthFormData struct {
+	Name            string `form:"name" validate:"required"`
+	Password        string `form:"password" validate:"required"`
+	PasswordConfirm string `form:"password-confirm"`
+}
+
+type AuthPageData struct {
+	PageData
+	Name  string
+	Error string
+	// RegistrationEnabled toggles the link between login and registration
+	RegistrationEnabled bool
+	// OIDCProvider is the name of the single sign-on provider, empty if OIDC is not configured
+	OIDCProvider string
+}
+
+// HashToken hashes a random secret token (session or API token) for storage and lookup
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// newSession is a session of SessionDuration for the plain token
+func newSession(userId string, token string, csrfToken string) *Session {
+	now := time.Now()
+	return &Session{
+		Id:        HashToken(token),
+		UserId:    userId,
+		CsrfToken: csrfToken,
+		CreatedAt: now,
+		ExpiresAt: now.Add(SessionDuration),
+	}
+}
+
+type SessionDBModel struct {
+	Client *mongo.Client
+}
+
+// EnsureIndexes creates the lookup index and lets Mongo remove expired sessions on its own
+func (m SessionDBModel) EnsureIndexes() error {
+	dbName := "SchedulerCluster"
+	collectionName := "sessions"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"id": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"expiresAt": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to create session indexes")
+	}
+	return err
+}
+
+// CreateSession stores a new session for the user and returns it together with the plain token for the cookie
+func (m SessionDBModel) CreateSession(userId string, token string, csrfToken string) (*Session, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "sessions"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	session := newSession(userId, token, csrfToken)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, session)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to insert new session")
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetSession looks up an unexpired session by the plain token of the cookie
+func (m SessionDBModel) GetSession(token string) (*Session, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "sessions"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"id": HashToken(token), "expiresAt": bson.M{"$gt": time.Now()}}
+
+	var session Session
+	err := collection.FindOne(ctx, filter).Decode(&session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (m SessionDBModel) DeleteSession(token string) error {
+	dbName := "SchedulerCluster"
+	collectionName := "sessions"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.DeleteOne(ctx, bson.M{"id": HashToken(token)})
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to delete a session")
+	}
+	return err
+}
@@ -0,0 +1,355 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const sqliteUserColumns = `id, name, COALESCE(password_hash, ''), COALESCE(oidc_subject, ''), webhook_url, created_at`
+
+func scanSqliteUser(row interface{ Scan(dest ...any) error }) (*User, error) {
+	user := &User{}
+	var createdAt int64
+	err := row.Scan(&user.Id, &user.Name, &user.PasswordHash, &user.OidcSubject, &user.WebhookUrl, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.CreatedAt = time.Unix(0, createdAt)
+	return user, nil
+}
+
+func findSqliteUser(q sqlQuerier, where string, arg any) (*User, error) {
+	return scanSqliteUser(q.QueryRow(`SELECT `+sqliteUserColumns+` FROM users WHERE `+where, arg))
+}
+
+func (s *SqliteStore) GetUserById(id string) (*User, error) {
+	return findSqliteUser(s.db, `id = ?`, id)
+}
+
+func (s *SqliteStore) GetUserByName(name string) (*User, error) {
+	return findSqliteUser(s.db, `name = ?`, name)
+}
+
+func (s *SqliteStore) GetUserByOidcSubject(subject string) (*User, error) {
+	return findSqliteUser(s.db, `oidc_subject = ?`, subject)
+}
+
+func (s *SqliteStore) InsertUser(user *User) (*User, error) {
+	err := s.inTx(func(tx *sql.Tx) error {
+		if err := checkSqliteUserFree(tx, user, ""); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO users (id, name, password_hash, oidc_subject, webhook_url, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			user.Id, user.Name, sqliteNullString(user.PasswordHash), sqliteNullString(user.OidcSubject), user.WebhookUrl, user.CreatedAt.UnixNano())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *SqliteStore) ClaimLegacyUser(claim *User) (*User, error) {
+	var claimed *User
+	err := s.inTx(func(tx *sql.Tx) error {
+		if err := checkSqliteUserFree(tx, claim, LegacyAuthor); err != nil {
+			return err
+		}
+
+		legacy, err := findSqliteUser(tx, `id = ?`, LegacyAuthor)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			_, err = tx.Exec(`INSERT INTO users (id, name, password_hash, oidc_subject, created_at) VALUES (?, ?, ?, ?, ?)`,
+				LegacyAuthor, claim.Name, sqliteNullString(claim.PasswordHash), sqliteNullString(claim.OidcSubject), time.Now().UnixNano())
+		case err != nil:
+			return err
+		case legacy.PasswordHash != "" || legacy.OidcSubject != "":
+			return ErrUserExists
+		default:
+			_, err = tx.Exec(`UPDATE users SET name = ?, password_hash = ?, oidc_subject = ? WHERE id = ?`,
+				claim.Name, sqliteNullString(claim.PasswordHash), sqliteNullString(claim.OidcSubject), LegacyAuthor)
+		}
+		if err != nil {
+			return err
+		}
+
+		claimed, err = findSqliteUser(tx, `id = ?`, LegacyAuthor)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// checkSqliteUserFree fails with ErrUserExists if another user than the one with the id has the id, name or OIDC subject
+func checkSqliteUserFree(q sqlQuerier, user *User, id string) error {
+	var taken int
+	err := q.QueryRow(`SELECT COUNT(*) FROM users WHERE id != ? AND (id = ? OR name = ? OR oidc_subject = ?)`,
+		id, user.Id, user.Name, sqliteNullString(user.OidcSubject)).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrUserExists
+	}
+	return nil
+}
+
+func (s *SqliteStore) UpdateWebhookUrl(userId string, webhookUrl string) error {
+	_, err := s.db.Exec(`UPDATE users SET webhook_url = ? WHERE id = ?`, webhookUrl, userId)
+	return err
+}
+
+func (s *SqliteStore) CreateSession(userId string, token string, csrfToken string) (*Session, error) {
+	session := newSession(userId, token, csrfToken)
+	err := s.inTx(func(tx *sql.Tx) error {
+		// expired sessions are removed on the way, like the TTL index of the sessions collection
+		if _, err := tx.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, time.Now().UnixNano()); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO sessions (id, user_id, csrf_token, created_at, expires_at) VALUES (?, ?, ?, ?, ?)`,
+			session.Id, session.UserId, session.CsrfToken, session.CreatedAt.UnixNano(), session.ExpiresAt.UnixNano())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *SqliteStore) GetSession(token string) (*Session, error) {
+	session := &Session{}
+	var createdAt, expiresAt int64
+	err := s.db.QueryRow(`SELECT id, user_id, csrf_token, created_at, expires_at FROM sessions WHERE id = ? AND expires_at > ?`,
+		HashToken(token), time.Now().UnixNano()).Scan(&session.Id, &session.UserId, &session.CsrfToken, &createdAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.CreatedAt = time.Unix(0, createdAt)
+	session.ExpiresAt = time.Unix(0, expiresAt)
+	return session, nil
+}
+
+func (s *SqliteStore) DeleteSession(token string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE id = ?`, HashToken(token))
+	return err
+}
+
+const sqliteTokenColumns = `id, user_id, name, hash, scopes, created_at, last_used_at`
+
+func scanSqliteToken(row interface{ Scan(dest ...any) error }) (*ApiToken, error) {
+	token := &ApiToken{}
+	var scopes string
+	var createdAt int64
+	var lastUsedAt sql.NullInt64
+	err := row.Scan(&token.Id, &token.UserId, &token.Name, &token.Hash, &scopes, &createdAt, &lastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &token.Scopes); err != nil {
+		return nil, err
+	}
+	token.CreatedAt = time.Unix(0, createdAt)
+	if lastUsedAt.Valid {
+		used := time.Unix(0, lastUsedAt.Int64)
+		token.LastUsedAt = &used
+	}
+	return token, nil
+}
+
+func (s *SqliteStore) CreateToken(userId string, name string, scopes []TokenScope) (*ApiToken, string, error) {
+	token, plainToken := newApiToken(userId, name, scopes)
+	encodedScopes, err := json.Marshal(token.Scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = s.db.Exec(`INSERT INTO tokens (id, user_id, name, hash, scopes, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		token.Id, token.UserId, token.Name, token.Hash, string(encodedScopes), token.CreatedAt.UnixNano())
+	if err != nil {
+		return nil, "", err
+	}
+	return token, plainToken, nil
+}
+
+// GetToken returns the token as it was before this use, like the update of TokenDBModel
+func (s *SqliteStore) GetToken(plainToken string) (*ApiToken, error) {
+	var token *ApiToken
+	err := s.inTx(func(tx *sql.Tx) error {
+		var err error
+		token, err = scanSqliteToken(tx.QueryRow(`SELECT `+sqliteTokenColumns+` FROM tokens WHERE hash = ?`, HashToken(plainToken)))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE tokens SET last_used_at = ? WHERE id = ?`, time.Now().UnixNano(), token.Id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s *SqliteStore) GetTokensByUser(userId string) ([]*ApiToken, error) {
+	rows, err := s.db.Query(`SELECT `+sqliteTokenColumns+` FROM tokens WHERE user_id = ? ORDER BY created_at`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*ApiToken{}
+	for rows.Next() {
+		token, err := scanSqliteToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *SqliteStore) DeleteToken(userId string, id string) error {
+	_, err := s.db.Exec(`DELETE FROM tokens WHERE user_id = ? AND id = ?`, userId, id)
+	return err
+}
+
+func (s *SqliteStore) InsertTeam(team *Team) (*Team, error) {
+	encoded, err := json.Marshal(team)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`INSERT INTO teams (id, name, team) VALUES (?, ?, ?)`, team.Id, team.Name, string(encoded)); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (s *SqliteStore) GetTeam(id string) (*Team, error) {
+	teams, err := querySqliteTeams(s.db, `id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(teams) == 0 {
+		return nil, ErrNotFound
+	}
+	return teams[0], nil
+}
+
+func (s *SqliteStore) GetTeamsByMember(userId string) ([]*Team, error) {
+	return querySqliteTeams(s.db, `EXISTS (SELECT 1 FROM json_each(team, '$.members') WHERE value ->> 'userId' = ?)`, userId)
+}
+
+func (s *SqliteStore) GetTeamsByInvitation(userId string) ([]*Team, error) {
+	return querySqliteTeams(s.db, `EXISTS (SELECT 1 FROM json_each(team, '$.invitations') WHERE value ->> 'userId' = ?)`, userId)
+}
+
+func (s *SqliteStore) FindInvitationTeam(userId string, invitationId string) (*Team, error) {
+	teams, err := querySqliteTeams(s.db, `EXISTS (SELECT 1 FROM json_each(team, '$.invitations')
+		WHERE value ->> 'userId' = ? AND value ->> 'id' = ?)`, userId, invitationId)
+	if err != nil {
+		return nil, err
+	}
+	if len(teams) == 0 {
+		return nil, ErrInvitationNotFound
+	}
+	return teams[0], nil
+}
+
+func querySqliteTeams(q sqlQuerier, where string, args ...any) ([]*Team, error) {
+	rows, err := q.Query(`SELECT team FROM teams WHERE `+where+` ORDER BY name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []*Team{}
+	for rows.Next() {
+		var encoded string
+		if err := rows.Scan(&encoded); err != nil {
+			return nil, err
+		}
+		team := &Team{}
+		if err := json.Unmarshal([]byte(encoded), team); err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, rows.Err()
+}
+
+func (s *SqliteStore) AddInvitation(teamId string, invitation *TeamInvitation) (*Team, error) {
+	return s.updateTeam(teamId, func(team *Team) error { return team.addInvitation(invitation) })
+}
+
+func (s *SqliteStore) AcceptInvitation(userId string, invitationId string) (*Team, error) {
+	team, err := s.FindInvitationTeam(userId, invitationId)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateTeam(team.Id, func(team *Team) error { return team.acceptInvitation(userId, invitationId) })
+}
+
+func (s *SqliteStore) DeleteInvitation(teamId string, invitationId string) (*Team, error) {
+	return s.updateTeam(teamId, func(team *Team) error {
+		team.deleteInvitation(invitationId)
+		return nil
+	})
+}
+
+func (s *SqliteStore) UpdateMemberRole(teamId string, userId string, role TeamRole) (*Team, error) {
+	return s.updateTeam(teamId, func(team *Team) error { return team.setMemberRole(userId, role) })
+}
+
+func (s *SqliteStore) RemoveMember(teamId string, userId string) (*Team, error) {
+	return s.updateTeam(teamId, func(team *Team) error {
+		team.removeMember(userId)
+		return nil
+	})
+}
+
+// updateTeam runs the change on the team in a transaction and stores the changed team
+func (s *SqliteStore) updateTeam(teamId string, change func(team *Team) error) (*Team, error) {
+	var team *Team
+	err := s.inTx(func(tx *sql.Tx) error {
+		teams, err := querySqliteTeams(tx, `id = ?`, teamId)
+		if err != nil {
+			return err
+		}
+		if len(teams) == 0 {
+			return ErrNotFound
+		}
+		team = teams[0]
+		if err := change(team); err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(team)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE teams SET name = ?, team = ? WHERE id = ?`, team.Name, string(encoded), team.Id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// sqliteNullString stores empty strings as NULL, so unique columns allow any number of them
+func sqliteNullString(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,788 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order, each exactly once. The index + 1 is the schema version,
+// so existing migrations must never change: add a new one instead.
+var sqliteMigrations = []string{
+	`CREATE TABLE schedules (
+		author TEXT PRIMARY KEY
+	);
+	CREATE TABLE tasks (
+		author           TEXT NOT NULL REFERENCES schedules (author) ON DELETE CASCADE,
+		id               TEXT NOT NULL,
+		position         INTEGER NOT NULL,
+		name             TEXT NOT NULL,
+		schedule         TEXT NOT NULL,
+		trigger_type     TEXT NOT NULL,
+		activated_time   INTEGER,
+		paused_remaining INTEGER,
+		depends_on_task  TEXT,
+		depends_on_event TEXT,
+		depends_on_delay TEXT,
+		priority         INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (author, id)
+	);
+	CREATE TABLE task_tags (
+		author   TEXT NOT NULL,
+		task_id  TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		tag      TEXT NOT NULL,
+		FOREIGN KEY (author, task_id) REFERENCES tasks (author, id) ON DELETE CASCADE
+	);
+	CREATE TABLE task_assignees (
+		author   TEXT NOT NULL,
+		task_id  TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		user_id  TEXT NOT NULL,
+		name     TEXT NOT NULL,
+		FOREIGN KEY (author, task_id) REFERENCES tasks (author, id) ON DELETE CASCADE
+	);
+	CREATE TABLE routines (
+		author   TEXT NOT NULL REFERENCES schedules (author) ON DELETE CASCADE,
+		id       TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		name     TEXT NOT NULL,
+		PRIMARY KEY (author, id)
+	);
+	CREATE TABLE routine_tasks (
+		author       TEXT NOT NULL,
+		routine_id   TEXT NOT NULL,
+		position     INTEGER NOT NULL,
+		name         TEXT NOT NULL,
+		schedule     TEXT NOT NULL,
+		trigger_type TEXT NOT NULL,
+		start_offset TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (author, routine_id) REFERENCES routines (author, id) ON DELETE CASCADE
+	);
+	CREATE TABLE occurrences (
+		id        TEXT PRIMARY KEY,
+		owner     TEXT NOT NULL,
+		task_id   TEXT NOT NULL,
+		task_name TEXT NOT NULL,
+		event     TEXT NOT NULL,
+		user_id   TEXT NOT NULL DEFAULT '',
+		at        INTEGER NOT NULL
+	);
+	CREATE INDEX task_tags_task ON task_tags (author, task_id);
+	CREATE INDEX task_assignees_task ON task_assignees (author, task_id);
+	CREATE INDEX routine_tasks_routine ON routine_tasks (author, routine_id);
+	CREATE INDEX occurrences_task ON occurrences (owner, task_id, at);`,
+	// deleted tasks are kept as JSON, they are only listed and restored as a whole
+	`CREATE TABLE trash (
+		author     TEXT NOT NULL,
+		id         TEXT NOT NULL,
+		deleted_at INTEGER NOT NULL,
+		task       TEXT NOT NULL,
+		PRIMARY KEY (author, id)
+	);
+	CREATE INDEX trash_deleted_at ON trash (deleted_at);`,
+	// the changes of an audit entry are only shown, never queried, so they are kept as JSON
+	`CREATE TABLE audit (
+		id         TEXT PRIMARY KEY,
+		owner      TEXT NOT NULL,
+		task_id    TEXT NOT NULL,
+		task_name  TEXT NOT NULL,
+		action     TEXT NOT NULL,
+		user_id    TEXT NOT NULL DEFAULT '',
+		actor_name TEXT NOT NULL DEFAULT '',
+		source     TEXT NOT NULL,
+		client     TEXT NOT NULL DEFAULT '',
+		ip         TEXT NOT NULL DEFAULT '',
+		changes    TEXT NOT NULL,
+		at         INTEGER NOT NULL
+	);
+	CREATE INDEX audit_owner ON audit (owner, at);`,
+	// the accounts, see sqlite_accounts.go. Teams are kept as JSON and searched by their members and invitations with json_each.
+	`CREATE TABLE users (
+		id            TEXT PRIMARY KEY,
+		name          TEXT NOT NULL UNIQUE,
+		password_hash TEXT,
+		oidc_subject  TEXT UNIQUE,
+		webhook_url   TEXT NOT NULL DEFAULT '',
+		created_at    INTEGER NOT NULL
+	);
+	CREATE TABLE sessions (
+		id         TEXT PRIMARY KEY,
+		user_id    TEXT NOT NULL,
+		csrf_token TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL
+	);
+	CREATE TABLE tokens (
+		id           TEXT PRIMARY KEY,
+		user_id      TEXT NOT NULL,
+		name         TEXT NOT NULL,
+		hash         TEXT NOT NULL UNIQUE,
+		scopes       TEXT NOT NULL,
+		created_at   INTEGER NOT NULL,
+		last_used_at INTEGER
+	);
+	CREATE TABLE teams (
+		id   TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		team TEXT NOT NULL
+	);
+	CREATE INDEX sessions_expires_at ON sessions (expires_at);
+	CREATE INDEX tokens_user ON tokens (user_id, created_at);`,
+}
+
+// SqliteStore is a Store in an embedded SQLite database, for small deployments without a MongoDB
+type SqliteStore struct {
+	db *sql.DB
+}
+
+// sqlQuerier is implemented by both *sql.DB and *sql.Tx
+type sqlQuerier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// NewSqliteStore opens or creates the database at path and migrates it to the latest schema
+func NewSqliteStore(path string) (*SqliteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// a single connection serializes the writes, SQLite can't write concurrently anyway
+	db.SetMaxOpenConns(1)
+
+	store := &SqliteStore{db: db}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *SqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// SchemaVersion is the number of applied migrations
+func (s *SqliteStore) SchemaVersion() (int, error) {
+	var version int
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+func (s *SqliteStore) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for index := current; index < len(sqliteMigrations); index++ {
+		version := index + 1
+		err := s.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(sqliteMigrations[index]); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now().Unix())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("sqlite migration %d: %w", version, err)
+		}
+		log.Info().Int("version", version).Msg("Applied sqlite migration")
+	}
+	return nil
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds and rolled back otherwise
+func (s *SqliteStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateSchedule runs the change in a transaction on the existing schedule and returns the changed schedule
+func (s *SqliteStore) updateSchedule(author string, change func(tx *sql.Tx) error) (*Scheduler, error) {
+	var scheduler *Scheduler
+	err := s.inTx(func(tx *sql.Tx) error {
+		if err := ensureSqliteSchedule(tx, author); err != nil {
+			return err
+		}
+		if err := change(tx); err != nil {
+			return err
+		}
+		var err error
+		scheduler, err = loadSqliteSchedule(tx, author)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scheduler, nil
+}
+
+func ensureSqliteSchedule(q sqlQuerier, author string) error {
+	_, err := q.Exec(`INSERT OR IGNORE INTO schedules (author) VALUES (?)`, author)
+	return err
+}
+
+// GetScheduleByAuthor only reads, the row of a schedule is created by its first change
+func (s *SqliteStore) GetScheduleByAuthor(author string) (*Scheduler, error) {
+	var scheduler *Scheduler
+	err := s.inTx(func(tx *sql.Tx) error {
+		var err error
+		scheduler, err = loadSqliteSchedule(tx, author)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scheduler, nil
+}
+
+func (s *SqliteStore) GetAllSchedules() ([]*Scheduler, error) {
+	schedules := []*Scheduler{}
+	err := s.inTx(func(tx *sql.Tx) error {
+		authors, err := queryStrings(tx, `SELECT author FROM schedules ORDER BY author`)
+		if err != nil {
+			return err
+		}
+		for _, author := range authors {
+			scheduler, err := loadSqliteSchedule(tx, author)
+			if err != nil {
+				return err
+			}
+			schedules = append(schedules, scheduler)
+		}
+		return nil
+	})
+	return schedules, err
+}
+
+func (s *SqliteStore) ReplaceSchedule(scheduler *Scheduler) error {
+	_, err := s.updateSchedule(scheduler.Author, func(tx *sql.Tx) error {
+		for _, query := range []string{`DELETE FROM tasks WHERE author = ?`, `DELETE FROM routines WHERE author = ?`} {
+			if _, err := tx.Exec(query, scheduler.Author); err != nil {
+				return err
+			}
+		}
+		for position, task := range scheduler.Tasks {
+			if err := insertSqliteTask(tx, scheduler.Author, position, task); err != nil {
+				return err
+			}
+		}
+		for position, routine := range scheduler.Routines {
+			if err := insertSqliteRoutine(tx, scheduler.Author, position, routine); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *SqliteStore) InsertTask(author string, task *Task) (*Scheduler, error) {
+	return s.updateSchedule(author, func(tx *sql.Tx) error {
+		position, err := nextSqlitePosition(tx, "tasks", author)
+		if err != nil {
+			return err
+		}
+		return insertSqliteTask(tx, author, position, task)
+	})
+}
+
+func (s *SqliteStore) UpdateTask(author string, task *Task) (*Scheduler, error) {
+	return s.updateSchedule(author, func(tx *sql.Tx) error {
+		var position int
+		err := tx.QueryRow(`SELECT position FROM tasks WHERE author = ? AND id = ?`, author, task.Id).Scan(&position)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTaskNotFound
+		}
+		if err != nil {
+			return err
+		}
+		// the tags and assignees go with the task row
+		if _, err := tx.Exec(`DELETE FROM tasks WHERE author = ? AND id = ?`, author, task.Id); err != nil {
+			return err
+		}
+		return insertSqliteTask(tx, author, position, task)
+	})
+}
+
+func (s *SqliteStore) UpdateTaskActivatedTime(author string, task *Task) error {
+	res, err := s.db.Exec(`UPDATE tasks SET activated_time = ? WHERE author = ? AND id = ?`,
+		sqliteTime(task.ActivatedTime), author, task.Id)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return ErrTaskNotFound
+	}
+	return err
+}
+
+func (s *SqliteStore) DeleteTasks(author string, taskIds []string) (*Scheduler, error) {
+	return s.updateSchedule(author, func(tx *sql.Tx) error {
+		scheduler, err := loadSqliteSchedule(tx, author)
+		if err != nil {
+			return err
+		}
+		deletedAt := time.Now().UnixNano()
+		for _, id := range taskIds {
+			task := scheduler.FindTask(id)
+			if task == nil {
+				continue
+			}
+			encoded, err := json.Marshal(task)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT OR REPLACE INTO trash (author, id, deleted_at, task) VALUES (?, ?, ?, ?)`,
+				author, id, deletedAt, string(encoded)); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM tasks WHERE author = ? AND id = ?`, author, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SqliteStore) GetTrash(author string) ([]*DeletedTask, error) {
+	rows, err := s.db.Query(`SELECT deleted_at, task FROM trash WHERE author = ? ORDER BY deleted_at DESC`, author)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trash := []*DeletedTask{}
+	for rows.Next() {
+		var deletedAt int64
+		var encoded string
+		if err := rows.Scan(&deletedAt, &encoded); err != nil {
+			return nil, err
+		}
+		deleted := &DeletedTask{Task: &Task{}, DeletedAt: time.Unix(0, deletedAt)}
+		if err := json.Unmarshal([]byte(encoded), deleted.Task); err != nil {
+			return nil, err
+		}
+		trash = append(trash, deleted)
+	}
+	return trash, rows.Err()
+}
+
+func (s *SqliteStore) RestoreTasks(author string, taskIds []string) (*Scheduler, error) {
+	return s.updateSchedule(author, func(tx *sql.Tx) error {
+		for _, id := range taskIds {
+			var encoded string
+			err := tx.QueryRow(`SELECT task FROM trash WHERE author = ? AND id = ?`, author, id).Scan(&encoded)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			var inUse int
+			if err := tx.QueryRow(`SELECT COUNT(*) FROM tasks WHERE author = ? AND id = ?`, author, id).Scan(&inUse); err != nil {
+				return err
+			}
+			if inUse > 0 {
+				continue
+			}
+
+			task := &Task{}
+			if err := json.Unmarshal([]byte(encoded), task); err != nil {
+				return err
+			}
+			position, err := nextSqlitePosition(tx, "tasks", author)
+			if err != nil {
+				return err
+			}
+			if err := insertSqliteTask(tx, author, position, task); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM trash WHERE author = ? AND id = ?`, author, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SqliteStore) PurgeTrash(before time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM trash WHERE deleted_at < ?`, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SqliteStore) ReplaceTrash(author string, trash []*DeletedTask) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if err := ensureSqliteSchedule(tx, author); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM trash WHERE author = ?`, author); err != nil {
+			return err
+		}
+		for _, deleted := range trash {
+			encoded, err := json.Marshal(deleted.Task)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT OR REPLACE INTO trash (author, id, deleted_at, task) VALUES (?, ?, ?, ?)`,
+				author, deleted.Task.Id, deleted.DeletedAt.UnixNano(), string(encoded)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SqliteStore) InsertRoutine(author string, routine *Routine) (*Scheduler, error) {
+	return s.updateSchedule(author, func(tx *sql.Tx) error {
+		position, err := nextSqlitePosition(tx, "routines", author)
+		if err != nil {
+			return err
+		}
+		return insertSqliteRoutine(tx, author, position, routine)
+	})
+}
+
+func (s *SqliteStore) DeleteRoutine(author string, routineId string) (*Scheduler, error) {
+	return s.updateSchedule(author, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`DELETE FROM routines WHERE author = ? AND id = ?`, author, routineId)
+		return err
+	})
+}
+
+func (s *SqliteStore) InsertOccurrence(occurrence *TaskOccurrence) error {
+	_, err := s.db.Exec(`INSERT INTO occurrences (id, owner, task_id, task_name, event, user_id, at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		occurrence.Id, occurrence.Owner, occurrence.TaskId, occurrence.TaskName, string(occurrence.Event), occurrence.UserId, occurrence.At.UnixNano())
+	return err
+}
+
+func (s *SqliteStore) GetOccurrences(owner string, taskId string, offset int, limit int) ([]*TaskOccurrence, int64, error) {
+	where := `owner = ?`
+	args := []any{owner}
+	if taskId != "" {
+		where += ` AND task_id = ?`
+		args = append(args, taskId)
+	}
+
+	var total int64
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM occurrences WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(`SELECT id, task_id, task_name, event, user_id, at FROM occurrences WHERE `+where+`
+		ORDER BY at DESC LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	occurrences := []*TaskOccurrence{}
+	for rows.Next() {
+		occurrence := &TaskOccurrence{Owner: owner}
+		var event string
+		var at int64
+		if err := rows.Scan(&occurrence.Id, &occurrence.TaskId, &occurrence.TaskName, &event, &occurrence.UserId, &at); err != nil {
+			return nil, 0, err
+		}
+		occurrence.Event = OccurrenceEvent(event)
+		occurrence.At = time.Unix(0, at)
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences, total, rows.Err()
+}
+
+func (s *SqliteStore) InsertAuditEntry(entry *AuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+	actor := entry.Actor
+	if actor == nil {
+		actor = &AuditActor{}
+	}
+	_, err = s.db.Exec(`INSERT INTO audit (id, owner, task_id, task_name, action, user_id, actor_name, source, client, ip, changes, at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.Id, entry.Owner, entry.TaskId, entry.TaskName, string(entry.Action), actor.UserId, actor.Name, actor.Source,
+		actor.Client, actor.Ip, string(changes), entry.At.UnixNano())
+	return err
+}
+
+func (s *SqliteStore) GetAuditEntries(owner string, filter *AuditFilter, offset int, limit int) ([]*AuditEntry, int64, error) {
+	where := `owner = ?`
+	args := []any{owner}
+	if filter.TaskId != "" {
+		where += ` AND task_id = ?`
+		args = append(args, filter.TaskId)
+	}
+	if filter.Action != "" {
+		where += ` AND action = ?`
+		args = append(args, string(filter.Action))
+	}
+	if filter.UserId != "" {
+		where += ` AND user_id = ?`
+		args = append(args, filter.UserId)
+	}
+	if !filter.Since.IsZero() {
+		where += ` AND at >= ?`
+		args = append(args, filter.Since.UnixNano())
+	}
+	if !filter.Until.IsZero() {
+		where += ` AND at < ?`
+		args = append(args, filter.Until.UnixNano())
+	}
+
+	var total int64
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM audit WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(`SELECT id, task_id, task_name, action, user_id, actor_name, source, client, ip, changes, at
+		FROM audit WHERE `+where+` ORDER BY at DESC LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		entry := &AuditEntry{Owner: owner, Actor: &AuditActor{}}
+		var action, changes string
+		var at int64
+		err := rows.Scan(&entry.Id, &entry.TaskId, &entry.TaskName, &action, &entry.Actor.UserId, &entry.Actor.Name,
+			&entry.Actor.Source, &entry.Actor.Client, &entry.Actor.Ip, &changes, &at)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+			return nil, 0, err
+		}
+		entry.Action = AuditAction(action)
+		entry.At = time.Unix(0, at)
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+func nextSqlitePosition(q sqlQuerier, table string, author string) (int, error) {
+	var position int
+	err := q.QueryRow(`SELECT COALESCE(MAX(position) + 1, 0) FROM `+table+` WHERE author = ?`, author).Scan(&position)
+	return position, err
+}
+
+func insertSqliteTask(q sqlQuerier, author string, position int, task *Task) error {
+	var pausedRemaining *int64
+	if task.PausedRemaining != nil {
+		remaining := int64(*task.PausedRemaining)
+		pausedRemaining = &remaining
+	}
+	var dependsOnTask, dependsOnEvent, dependsOnDelay *string
+	if task.DependsOn != nil {
+		event := string(task.DependsOn.Event)
+		dependsOnTask, dependsOnEvent, dependsOnDelay = &task.DependsOn.TaskId, &event, &task.DependsOn.Delay
+	}
+
+	_, err := q.Exec(`INSERT INTO tasks (author, id, position, name, schedule, trigger_type, activated_time, paused_remaining,
+		depends_on_task, depends_on_event, depends_on_delay, priority) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		author, task.Id, position, task.Name, task.Schedule, string(task.Trigger), sqliteTime(task.ActivatedTime), pausedRemaining,
+		dependsOnTask, dependsOnEvent, dependsOnDelay, int(task.Priority))
+	if err != nil {
+		return err
+	}
+
+	for i, tag := range task.Tags {
+		if _, err := q.Exec(`INSERT INTO task_tags (author, task_id, position, tag) VALUES (?, ?, ?, ?)`, author, task.Id, i, tag); err != nil {
+			return err
+		}
+	}
+	for i, assignee := range task.Assignees {
+		_, err := q.Exec(`INSERT INTO task_assignees (author, task_id, position, user_id, name) VALUES (?, ?, ?, ?, ?)`,
+			author, task.Id, i, assignee.UserId, assignee.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertSqliteRoutine(q sqlQuerier, author string, position int, routine *Routine) error {
+	_, err := q.Exec(`INSERT INTO routines (author, id, position, name) VALUES (?, ?, ?, ?)`, author, routine.Id, position, routine.Name)
+	if err != nil {
+		return err
+	}
+	for i, task := range routine.Tasks {
+		_, err := q.Exec(`INSERT INTO routine_tasks (author, routine_id, position, name, schedule, trigger_type, start_offset) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			author, routine.Id, i, task.Name, task.Schedule, string(task.Trigger), task.Offset)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadSqliteSchedule(q sqlQuerier, author string) (*Scheduler, error) {
+	scheduler := &Scheduler{Author: author, Tasks: []*Task{}}
+
+	rows, err := q.Query(`SELECT id, name, schedule, trigger_type, activated_time, paused_remaining,
+		depends_on_task, depends_on_event, depends_on_delay, priority FROM tasks WHERE author = ? ORDER BY position`, author)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		task := &Task{}
+		var trigger string
+		var activatedTime, pausedRemaining sql.NullInt64
+		var dependsOnTask, dependsOnEvent, dependsOnDelay sql.NullString
+		var priority int
+		err := rows.Scan(&task.Id, &task.Name, &task.Schedule, &trigger, &activatedTime, &pausedRemaining,
+			&dependsOnTask, &dependsOnEvent, &dependsOnDelay, &priority)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		task.Trigger = TaskTrigger(trigger)
+		task.Priority = TaskPriority(priority)
+		if activatedTime.Valid {
+			activated := time.Unix(0, activatedTime.Int64)
+			task.ActivatedTime = &activated
+		}
+		if pausedRemaining.Valid {
+			remaining := time.Duration(pausedRemaining.Int64)
+			task.PausedRemaining = &remaining
+		}
+		if dependsOnTask.Valid {
+			task.DependsOn = &TaskDependency{TaskId: dependsOnTask.String, Event: DependencyEvent(dependsOnEvent.String), Delay: dependsOnDelay.String}
+		}
+		scheduler.Tasks = append(scheduler.Tasks, task)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, task := range scheduler.Tasks {
+		if task.Tags, err = queryStrings(q, `SELECT tag FROM task_tags WHERE author = ? AND task_id = ? ORDER BY position`, author, task.Id); err != nil {
+			return nil, err
+		}
+		if len(task.Tags) == 0 {
+			task.Tags = nil
+		}
+		if task.Assignees, err = loadSqliteAssignees(q, author, task.Id); err != nil {
+			return nil, err
+		}
+	}
+
+	if scheduler.Routines, err = loadSqliteRoutines(q, author); err != nil {
+		return nil, err
+	}
+	return scheduler, nil
+}
+
+func loadSqliteAssignees(q sqlQuerier, author string, taskId string) ([]*TaskAssignee, error) {
+	rows, err := q.Query(`SELECT user_id, name FROM task_assignees WHERE author = ? AND task_id = ? ORDER BY position`, author, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignees []*TaskAssignee
+	for rows.Next() {
+		assignee := &TaskAssignee{}
+		if err := rows.Scan(&assignee.UserId, &assignee.Name); err != nil {
+			return nil, err
+		}
+		assignees = append(assignees, assignee)
+	}
+	return assignees, rows.Err()
+}
+
+func loadSqliteRoutines(q sqlQuerier, author string) ([]*Routine, error) {
+	rows, err := q.Query(`SELECT id, name FROM routines WHERE author = ? ORDER BY position`, author)
+	if err != nil {
+		return nil, err
+	}
+	var routines []*Routine
+	for rows.Next() {
+		routine := &Routine{Tasks: []*RoutineTask{}}
+		if err := rows.Scan(&routine.Id, &routine.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		routines = append(routines, routine)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, routine := range routines {
+		rows, err := q.Query(`SELECT name, schedule, trigger_type, start_offset FROM routine_tasks WHERE author = ? AND routine_id = ? ORDER BY position`,
+			author, routine.Id)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			task := &RoutineTask{}
+			var trigger string
+			if err := rows.Scan(&task.Name, &task.Schedule, &trigger, &task.Offset); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			task.Trigger = TaskTrigger(trigger)
+			routine.Tasks = append(routine.Tasks, task)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return routines, nil
+}
+
+func queryStrings(q sqlQuerier, query string, args ...any) ([]string, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// sqliteTime stores times as unix nanoseconds, NULL for nil
+func sqliteTime(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	nanos := t.UnixNano()
+	return &nanos
+}
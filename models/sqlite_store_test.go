@@ -0,0 +1,32 @@
+package models
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSqliteStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.db")
+	store, err := NewSqliteStore(path)
+	if err != nil {
+		t.Fatalf("NewSqliteStore failed: %v", err)
+	}
+	_, _ = store.InsertTask("author", newTestTask("Tea"))
+	store.Close()
+
+	// reopening must not apply the migrations again
+	reopened, err := NewSqliteStore(path)
+	if err != nil {
+		t.Fatalf("Reopening the sqlite store failed: %v", err)
+	}
+	defer reopened.Close()
+
+	version, err := reopened.SchemaVersion()
+	if err != nil || version != len(sqliteMigrations) {
+		t.Errorf("SchemaVersion was incorrect, got: %v %v, want: %v.", version, err, len(sqliteMigrations))
+	}
+	scheduler, _ := reopened.GetScheduleByAuthor("author")
+	if names := taskNames(scheduler); !equalNames(names, "Tea") {
+		t.Errorf("Reopened tasks were incorrect, got: %v, want: %v.", names, []string{"Tea"})
+	}
+}
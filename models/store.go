@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrTaskNotFound = errors.New("task not found")
+
+// TaskStore persists the schedules with their tasks and routines, the history of the tasks and the audit log of their changes.
+// Every implementation has to pass the conformance suite in store_test.go.
+//
+// Schedules are created on first use: GetScheduleByAuthor returns a new empty schedule for unknown authors,
+// the insert methods and ReplaceSchedule create the schedule if it doesn't exist yet.
+// Returned schedules are copies, changing them has no effect on the store.
+type TaskStore interface {
+	GetScheduleByAuthor(author string) (*Scheduler, error)
+	// GetAllSchedules returns the schedules of all users, e.g. to register their live timers on startup
+	GetAllSchedules() ([]*Scheduler, error)
+	ReplaceSchedule(scheduler *Scheduler) error
+
+	InsertTask(author string, task *Task) (*Scheduler, error)
+	// UpdateTask replaces the task with the same id, ErrTaskNotFound if there is none
+	UpdateTask(author string, task *Task) (*Scheduler, error)
+	UpdateTaskActivatedTime(author string, task *Task) error
+	// DeleteTasks moves the tasks to the trash of the schedule, unknown ids are ignored
+	DeleteTasks(author string, taskIds []string) (*Scheduler, error)
+	// GetTrash returns the deleted tasks of the schedule, the last deleted first
+	GetTrash(author string) ([]*DeletedTask, error)
+	// RestoreTasks moves tasks from the trash back to the end of the schedule. Unknown ids are ignored,
+	// deleted tasks whose id is in use again stay in the trash.
+	RestoreTasks(author string, taskIds []string) (*Scheduler, error)
+	// PurgeTrash permanently removes the tasks of all schedules deleted before the time and returns their number
+	PurgeTrash(before time.Time) (int64, error)
+	// ReplaceTrash makes the deleted tasks the whole trash of the schedule, e.g. to restore a backup
+	ReplaceTrash(author string, trash []*DeletedTask) error
+
+	InsertRoutine(author string, routine *Routine) (*Scheduler, error)
+	DeleteRoutine(author string, routineId string) (*Scheduler, error)
+
+	InsertOccurrence(occurrence *TaskOccurrence) error
+	// GetOccurrences returns a page of the history of a task, newest first, together with the total count.
+	// An empty taskId returns the history of every task of the schedule, deleted ones included.
+	GetOccurrences(owner string, taskId string, offset int, limit int) ([]*TaskOccurrence, int64, error)
+
+	InsertAuditEntry(entry *AuditEntry) error
+	// GetAuditEntries returns a page of the matching audit entries of a schedule, newest first, together with the total count
+	GetAuditEntries(owner string, filter *AuditFilter, offset int, limit int) ([]*AuditEntry, int64, error)
+}
+
+const (
+	StoreMongo  = "mongo"
+	StoreFile   = "file"
+	StoreMemory = "memory"
+	StoreSqlite = "sqlite"
+)
+
+// StoreConfig selects the Store implementation
+type StoreConfig struct {
+	// Kind is one of StoreMongo, StoreFile, StoreMemory or StoreSqlite
+	Kind string
+	// Path is the JSON file of StoreFile or the database file of StoreSqlite
+	Path string
+}
+
+// IsMongo tells if the store is the MongoStore, the only one that needs a MongoDB connection
+func (config StoreConfig) IsMongo() bool {
+	return config.Kind == StoreMongo || config.Kind == ""
+}
+
+// NewStore creates the store of the config. The mongo store is the given one, the others don't need it.
+func NewStore(config StoreConfig, mongoStore *MongoStore) (Store, error) {
+	switch config.Kind {
+	case StoreMongo, "":
+		return mongoStore, nil
+	case StoreFile:
+		return NewFileStore(config.Path)
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	case StoreSqlite:
+		return NewSqliteStore(config.Path)
+	default:
+		return nil, fmt.Errorf("unknown store <%s>", config.Kind)
+	}
+}
+
+// MongoStore is the Store on MongoDB. Schedules, their tasks, the trash, the history, the audit log and each kind of
+// account are separate collections.
+type MongoStore struct {
+	TaskDBModel
+	OccurrenceDBModel
+	AuditDBModel
+	UserDBModel
+	SessionDBModel
+	TokenDBModel
+	TeamDBModel
+}
+
+func NewMongoStore(client *mongo.Client) *MongoStore {
+	return &MongoStore{
+		TaskDBModel:       TaskDBModel{Client: client},
+		OccurrenceDBModel: OccurrenceDBModel{Client: client},
+		AuditDBModel:      AuditDBModel{Client: client},
+		UserDBModel:       UserDBModel{Client: client},
+		SessionDBModel:    SessionDBModel{Client: client},
+		TokenDBModel:      TokenDBModel{Client: client},
+		TeamDBModel:       TeamDBModel{Client: client},
+	}
+}
+
+// EnsureIndexes creates the indexes of all collections
+func (s *MongoStore) EnsureIndexes() error {
+	for _, ensure := range []func() error{
+		s.TaskDBModel.EnsureIndexes,
+		s.OccurrenceDBModel.EnsureIndexes,
+		s.AuditDBModel.EnsureIndexes,
+		s.UserDBModel.EnsureIndexes,
+		s.SessionDBModel.EnsureIndexes,
+		s.TokenDBModel.EnsureIndexes,
+		s.TeamDBModel.EnsureIndexes,
+	} {
+		if err := ensure(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
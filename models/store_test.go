@@ -0,0 +1,549 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"scheduler/utils"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// storeFactories create empty stores for the conformance suite, the mongo store only runs with TEST_MONGO_URI
+func storeFactories(t *testing.T) map[string]func(t *testing.T) Store {
+	factories := map[string]func(t *testing.T) Store{
+		StoreMemory: func(t *testing.T) Store {
+			return NewMemoryStore()
+		},
+		StoreFile: func(t *testing.T) Store {
+			store, err := NewFileStore(filepath.Join(t.TempDir(), "schedules.json"))
+			if err != nil {
+				t.Fatalf("NewFileStore failed: %v", err)
+			}
+			return store
+		},
+		StoreSqlite: func(t *testing.T) Store {
+			store, err := NewSqliteStore(filepath.Join(t.TempDir(), "schedules.db"))
+			if err != nil {
+				t.Fatalf("NewSqliteStore failed: %v", err)
+			}
+			t.Cleanup(func() { _ = store.Close() })
+			return store
+		},
+	}
+
+	if mongoUri := os.Getenv("TEST_MONGO_URI"); mongoUri != "" {
+		factories[StoreMongo] = func(t *testing.T) Store {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoUri))
+			if err != nil {
+				t.Fatalf("Connecting to MongoDB failed: %v", err)
+			}
+			t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+			return NewMongoStore(client)
+		}
+	}
+
+	return factories
+}
+
+// testAuthor returns a unique author, the mongo store shares its database with other runs
+func testAuthor(t *testing.T, store TaskStore) string {
+	author := "store-test-" + utils.Uuid()
+	if mongoStore, ok := store.(*MongoStore); ok {
+		t.Cleanup(func() {
+			db := mongoStore.TaskDBModel.Client.Database("SchedulerCluster")
+			_, _ = db.Collection("schedules").DeleteMany(context.Background(), bson.M{"author": author})
+			_, _ = db.Collection("tasks").DeleteMany(context.Background(), bson.M{"owner": author})
+			_, _ = db.Collection("occurrences").DeleteMany(context.Background(), bson.M{"owner": author})
+			_, _ = db.Collection("trash").DeleteMany(context.Background(), bson.M{"owner": author})
+			_, _ = db.Collection("audit").DeleteMany(context.Background(), bson.M{"owner": author})
+		})
+	}
+	return author
+}
+
+func newTestTask(name string) *Task {
+	return &Task{Id: utils.Uuid(), Name: name, Schedule: "in 5min", Trigger: Popup}
+}
+
+func taskNames(scheduler *Scheduler) []string {
+	names := []string{}
+	for _, task := range scheduler.Tasks {
+		names = append(names, task.Name)
+	}
+	return names
+}
+
+func equalNames(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTaskStore_Conformance(t *testing.T) {
+	for kind, newStore := range storeFactories(t) {
+		t.Run(kind, func(t *testing.T) {
+			t.Run("Schedules", func(t *testing.T) { testStoreSchedules(t, newStore(t)) })
+			t.Run("Tasks", func(t *testing.T) { testStoreTasks(t, newStore(t)) })
+			t.Run("Routines", func(t *testing.T) { testStoreRoutines(t, newStore(t)) })
+			t.Run("Fields", func(t *testing.T) { testStoreFields(t, newStore(t)) })
+			t.Run("Copies", func(t *testing.T) { testStoreCopies(t, newStore(t)) })
+			t.Run("Occurrences", func(t *testing.T) { testStoreOccurrences(t, newStore(t)) })
+			t.Run("Trash", func(t *testing.T) { testStoreTrash(t, newStore(t)) })
+			t.Run("ReplaceImport", func(t *testing.T) { testStoreReplaceImport(t, newStore(t)) })
+			t.Run("Audit", func(t *testing.T) { testStoreAudit(t, newStore(t)) })
+		})
+	}
+}
+
+func testStoreSchedules(t *testing.T, store TaskStore) {
+	author := testAuthor(t, store)
+	other := testAuthor(t, store)
+
+	scheduler, err := store.GetScheduleByAuthor(author)
+	if err != nil || scheduler == nil {
+		t.Fatalf("GetScheduleByAuthor of a new author was incorrect, got: %v %v, want: an empty schedule.", scheduler, err)
+	}
+	if scheduler.Author != author || len(scheduler.Tasks) != 0 {
+		t.Errorf("New schedule was incorrect, got: %v %v, want: %v without tasks.", scheduler.Author, len(scheduler.Tasks), author)
+	}
+
+	replacement := &Scheduler{Author: other, Tasks: []*Task{newTestTask("Tea")}}
+	if err := store.ReplaceSchedule(replacement); err != nil {
+		t.Fatalf("ReplaceSchedule of a new author failed: %v", err)
+	}
+	replacement.Tasks = []*Task{newTestTask("Coffee"), newTestTask("Cake")}
+	if err := store.ReplaceSchedule(replacement); err != nil {
+		t.Fatalf("ReplaceSchedule failed: %v", err)
+	}
+
+	scheduler, _ = store.GetScheduleByAuthor(other)
+	if names := taskNames(scheduler); !equalNames(names, "Coffee", "Cake") {
+		t.Errorf("Replaced schedule was incorrect, got: %v, want: %v.", names, []string{"Coffee", "Cake"})
+	}
+
+	schedules, err := store.GetAllSchedules()
+	if err != nil {
+		t.Fatalf("GetAllSchedules failed: %v", err)
+	}
+	found := map[string]bool{}
+	for _, scheduler := range schedules {
+		found[scheduler.Author] = true
+	}
+	// reading the schedule of the new author didn't store it
+	if found[author] || !found[other] {
+		t.Errorf("GetAllSchedules was incorrect, got: %v, want: only %v.", found, other)
+	}
+
+	scheduler, _ = store.GetScheduleByAuthor(author)
+	if len(scheduler.Tasks) != 0 {
+		t.Errorf("Schedules were not isolated, got: %v, want: no tasks.", taskNames(scheduler))
+	}
+}
+
+func testStoreTasks(t *testing.T, store TaskStore) {
+	author := testAuthor(t, store)
+
+	// inserting into an unknown schedule creates it
+	tea := newTestTask("Tea")
+	scheduler, err := store.InsertTask(author, tea)
+	if err != nil {
+		t.Fatalf("InsertTask failed: %v", err)
+	}
+	coffee := newTestTask("Coffee")
+	scheduler, _ = store.InsertTask(author, coffee)
+	if names := taskNames(scheduler); !equalNames(names, "Tea", "Coffee") {
+		t.Errorf("InsertTask was incorrect, got: %v, want: %v.", names, []string{"Tea", "Coffee"})
+	}
+
+	tea.Name = "Green tea"
+	scheduler, err = store.UpdateTask(author, tea)
+	if err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+	if names := taskNames(scheduler); !equalNames(names, "Green tea", "Coffee") {
+		t.Errorf("UpdateTask was incorrect, got: %v, want: %v.", names, []string{"Green tea", "Coffee"})
+	}
+
+	if _, err := store.UpdateTask(author, newTestTask("Missing")); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("UpdateTask of a missing task was incorrect, got: %v, want: %v.", err, ErrTaskNotFound)
+	}
+
+	// mongo keeps milliseconds
+	activated := time.Now().Truncate(time.Millisecond)
+	coffee.ActivatedTime = &activated
+	coffee.Name = "ignored"
+	if err := store.UpdateTaskActivatedTime(author, coffee); err != nil {
+		t.Fatalf("UpdateTaskActivatedTime failed: %v", err)
+	}
+	scheduler, _ = store.GetScheduleByAuthor(author)
+	stored := scheduler.FindTask(coffee.Id)
+	if stored.ActivatedTime == nil || !stored.ActivatedTime.Equal(activated) || stored.Name != "Coffee" {
+		t.Errorf("UpdateTaskActivatedTime was incorrect, got: %v %v, want: %v Coffee.", stored.ActivatedTime, stored.Name, activated)
+	}
+	if err := store.UpdateTaskActivatedTime(author, newTestTask("Missing")); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("UpdateTaskActivatedTime of a missing task was incorrect, got: %v, want: %v.", err, ErrTaskNotFound)
+	}
+
+	scheduler, err = store.DeleteTasks(author, []string{})
+	if err != nil || len(scheduler.Tasks) != 2 {
+		t.Errorf("DeleteTasks without ids was incorrect, got: %v %v, want: 2 tasks.", taskNames(scheduler), err)
+	}
+	scheduler, err = store.DeleteTasks(author, []string{tea.Id, "unknown"})
+	if err != nil {
+		t.Fatalf("DeleteTasks failed: %v", err)
+	}
+	if names := taskNames(scheduler); !equalNames(names, "Coffee") {
+		t.Errorf("DeleteTasks was incorrect, got: %v, want: %v.", names, []string{"Coffee"})
+	}
+}
+
+func testStoreTrash(t *testing.T, store TaskStore) {
+	author := testAuthor(t, store)
+
+	tea, coffee, cake := newTestTask("Tea"), newTestTask("Coffee"), newTestTask("Cake")
+	tea.Tags = []string{"kitchen"}
+	for _, task := range []*Task{tea, coffee, cake} {
+		_, _ = store.InsertTask(author, task)
+	}
+	_, _ = store.DeleteTasks(author, []string{tea.Id})
+	// mongo keeps milliseconds
+	time.Sleep(2 * time.Millisecond)
+	_, _ = store.DeleteTasks(author, []string{cake.Id})
+
+	trash, err := store.GetTrash(author)
+	if err != nil {
+		t.Fatalf("GetTrash failed: %v", err)
+	}
+	if len(trash) != 2 || trash[0].Task.Name != "Cake" || trash[1].Task.Name != "Tea" || trash[1].Task.Tags[0] != "kitchen" {
+		t.Fatalf("GetTrash was incorrect, got: %+v, want: Cake and Tea.", trash)
+	}
+	if other, _ := store.GetTrash(testAuthor(t, store)); len(other) != 0 {
+		t.Errorf("GetTrash of another schedule was incorrect, got: %v, want: none.", len(other))
+	}
+
+	scheduler, err := store.RestoreTasks(author, []string{tea.Id, "unknown"})
+	if err != nil {
+		t.Fatalf("RestoreTasks failed: %v", err)
+	}
+	if names := taskNames(scheduler); !equalNames(names, "Coffee", "Tea") {
+		t.Errorf("RestoreTasks was incorrect, got: %v, want: %v.", names, []string{"Coffee", "Tea"})
+	}
+
+	// a task that got the id of a deleted one again keeps it
+	_, _ = store.InsertTask(author, &Task{Id: cake.Id, Name: "New cake", Schedule: "in 5min", Trigger: Popup})
+	scheduler, _ = store.RestoreTasks(author, []string{cake.Id})
+	if names := taskNames(scheduler); !equalNames(names, "Coffee", "Tea", "New cake") {
+		t.Errorf("RestoreTasks of a taken id was incorrect, got: %v, want: %v.", names, []string{"Coffee", "Tea", "New cake"})
+	}
+	if trash, _ := store.GetTrash(author); len(trash) != 1 {
+		t.Errorf("Trash after restoring was incorrect, got: %v, want: 1 task.", len(trash))
+	}
+
+	purged, err := store.PurgeTrash(time.Now().Add(time.Second))
+	if err != nil || purged < 1 {
+		t.Errorf("PurgeTrash was incorrect, got: %v %v, want: at least 1.", purged, err)
+	}
+	if trash, _ := store.GetTrash(author); len(trash) != 0 {
+		t.Errorf("Trash after purging was incorrect, got: %v, want: none.", len(trash))
+	}
+
+	deletedAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	replaced := []*DeletedTask{{Task: cake, DeletedAt: deletedAt.Add(time.Minute)}, {Task: tea, DeletedAt: deletedAt}}
+	if err := store.ReplaceTrash(author, replaced); err != nil {
+		t.Fatalf("ReplaceTrash failed: %v", err)
+	}
+	trash, _ = store.GetTrash(author)
+	if len(trash) != 2 || trash[0].Task.Name != "Cake" || trash[1].Task.Name != "Tea" || !trash[1].DeletedAt.Equal(deletedAt) {
+		t.Errorf("Trash after replacing was incorrect, got: %+v, want: Cake and Tea deleted at %v.", trash, deletedAt)
+	}
+	if err := store.ReplaceTrash(author, nil); err != nil {
+		t.Fatalf("ReplaceTrash failed: %v", err)
+	}
+	if trash, _ := store.GetTrash(author); len(trash) != 0 {
+		t.Errorf("Trash after replacing with none was incorrect, got: %v, want: none.", len(trash))
+	}
+}
+
+func testStoreReplaceImport(t *testing.T, store TaskStore) {
+	author := testAuthor(t, store)
+
+	tea, coffee := newTestTask("Tea"), newTestTask("Coffee")
+	_, _ = store.InsertTask(author, tea)
+	_, _ = store.InsertTask(author, coffee)
+
+	current, _ := store.GetScheduleByAuthor(author)
+	report, planned, err := PlanImport(current, &ScheduleExport{Tasks: []*Task{tea, newTestTask("Cake")}}, ImportReplace, nil)
+	if err != nil || planned == nil {
+		t.Fatalf("PlanImport failed: %v %+v", err, report)
+	}
+	if err := ApplyReplaceImport(store, report, planned); err != nil {
+		t.Fatalf("ApplyReplaceImport failed: %v", err)
+	}
+
+	scheduler, _ := store.GetScheduleByAuthor(author)
+	if names := taskNames(scheduler); !equalNames(names, "Tea", "Cake") {
+		t.Errorf("Replaced schedule was incorrect, got: %v, want: %v.", names, []string{"Tea", "Cake"})
+	}
+	trash, _ := store.GetTrash(author)
+	if len(trash) != 1 || trash[0].Task.Id != coffee.Id {
+		t.Fatalf("Trash after replacing was incorrect, got: %+v, want: %v.", trash, coffee.Name)
+	}
+	scheduler, _ = store.RestoreTasks(author, []string{coffee.Id})
+	if names := taskNames(scheduler); !equalNames(names, "Tea", "Cake", "Coffee") {
+		t.Errorf("Restoring a replaced task was incorrect, got: %v, want: %v.", names, []string{"Tea", "Cake", "Coffee"})
+	}
+}
+
+func testStoreRoutines(t *testing.T, store TaskStore) {
+	author := testAuthor(t, store)
+
+	routine := &Routine{Id: utils.Uuid(), Name: "Morning", Tasks: []*RoutineTask{{Name: "Tea", Schedule: "in 4min", Trigger: Audio}}}
+	scheduler, err := store.InsertRoutine(author, routine)
+	if err != nil {
+		t.Fatalf("InsertRoutine failed: %v", err)
+	}
+	if len(scheduler.Routines) != 1 || scheduler.Routines[0].Name != "Morning" || len(scheduler.Routines[0].Tasks) != 1 {
+		t.Errorf("InsertRoutine was incorrect, got: %v, want: the Morning routine.", scheduler.Routines)
+	}
+
+	scheduler, err = store.DeleteRoutine(author, routine.Id)
+	if err != nil {
+		t.Fatalf("DeleteRoutine failed: %v", err)
+	}
+	if len(scheduler.Routines) != 0 {
+		t.Errorf("DeleteRoutine was incorrect, got: %v, want: no routines.", len(scheduler.Routines))
+	}
+}
+
+func testStoreFields(t *testing.T, store TaskStore) {
+	author := testAuthor(t, store)
+
+	activated := time.Now().Truncate(time.Millisecond)
+	remaining := 90 * time.Second
+	task := &Task{
+		Id:              utils.Uuid(),
+		Name:            "Tea",
+		Schedule:        "in 5min",
+		ActivatedTime:   &activated,
+		Trigger:         WebHook,
+		PausedRemaining: &remaining,
+		DependsOn:       &TaskDependency{TaskId: "kettle", Event: DependencyDone, Delay: "1min"},
+		Tags:            []string{"kitchen", "morning"},
+		Priority:        PriorityHigh,
+		Assignees:       []*TaskAssignee{{UserId: "user", Name: "User"}},
+	}
+	_, _ = store.InsertTask(author, task)
+
+	scheduler, _ := store.GetScheduleByAuthor(author)
+	stored := scheduler.FindTask(task.Id)
+	if stored == nil {
+		t.Fatalf("Inserted task was incorrect, got: %v, want: %v.", taskNames(scheduler), task.Name)
+	}
+	if stored.Name != task.Name || stored.Schedule != task.Schedule || stored.Trigger != task.Trigger || stored.Priority != task.Priority {
+		t.Errorf("Stored task was incorrect, got: %+v, want: %+v.", stored, task)
+	}
+	if stored.ActivatedTime == nil || !stored.ActivatedTime.Equal(activated) {
+		t.Errorf("Stored activated time was incorrect, got: %v, want: %v.", stored.ActivatedTime, activated)
+	}
+	if stored.PausedRemaining == nil || *stored.PausedRemaining != remaining {
+		t.Errorf("Stored paused remaining was incorrect, got: %v, want: %v.", stored.PausedRemaining, remaining)
+	}
+	if stored.DependsOn == nil || *stored.DependsOn != *task.DependsOn {
+		t.Errorf("Stored dependency was incorrect, got: %v, want: %v.", stored.DependsOn, task.DependsOn)
+	}
+	if !equalNames(stored.Tags, task.Tags...) {
+		t.Errorf("Stored tags were incorrect, got: %v, want: %v.", stored.Tags, task.Tags)
+	}
+	if len(stored.Assignees) != 1 || *stored.Assignees[0] != *task.Assignees[0] {
+		t.Errorf("Stored assignees were incorrect, got: %v, want: %v.", stored.Assignees, task.Assignees)
+	}
+}
+
+func testStoreCopies(t *testing.T, store TaskStore) {
+	author := testAuthor(t, store)
+
+	task := newTestTask("Tea")
+	scheduler, _ := store.InsertTask(author, task)
+	task.Name = "changed after insert"
+	scheduler.Tasks[0].Name = "changed result"
+
+	scheduler, _ = store.GetScheduleByAuthor(author)
+	if names := taskNames(scheduler); !equalNames(names, "Tea") {
+		t.Errorf("Store was changed through returned values, got: %v, want: %v.", names, []string{"Tea"})
+	}
+}
+
+func testStoreOccurrences(t *testing.T, store TaskStore) {
+	author := testAuthor(t, store)
+	task := newTestTask("Tea")
+
+	start := time.Now().Truncate(time.Millisecond)
+	events := []OccurrenceEvent{OccurrenceFired, OccurrenceSnoozed, OccurrenceDone}
+	for i, event := range events {
+		occurrence := NewTaskOccurrence(author, task, event, "user")
+		occurrence.At = start.Add(time.Duration(i) * time.Second)
+		if err := store.InsertOccurrence(occurrence); err != nil {
+			t.Fatalf("InsertOccurrence failed: %v", err)
+		}
+	}
+	other := NewTaskOccurrence(author, newTestTask("Coffee"), OccurrenceFired, "")
+	other.At = start.Add(-time.Second)
+	_ = store.InsertOccurrence(other)
+
+	occurrences, total, err := store.GetOccurrences(author, task.Id, 0, 2)
+	if err != nil {
+		t.Fatalf("GetOccurrences failed: %v", err)
+	}
+	if total != 3 || len(occurrences) != 2 {
+		t.Fatalf("GetOccurrences was incorrect, got: %v of %v, want: 2 of 3.", len(occurrences), total)
+	}
+	if occurrences[0].Event != OccurrenceDone || occurrences[1].Event != OccurrenceSnoozed {
+		t.Errorf("GetOccurrences order was incorrect, got: %v %v, want: newest first.", occurrences[0].Event, occurrences[1].Event)
+	}
+
+	occurrences, _, _ = store.GetOccurrences(author, task.Id, 2, 2)
+	if len(occurrences) != 1 || occurrences[0].Event != OccurrenceFired {
+		t.Errorf("GetOccurrences second page was incorrect, got: %v, want: the fired occurrence.", len(occurrences))
+	}
+
+	occurrences, total, _ = store.GetOccurrences(testAuthor(t, store), task.Id, 0, 10)
+	if total != 0 || len(occurrences) != 0 {
+		t.Errorf("GetOccurrences of another owner was incorrect, got: %v, want: 0.", total)
+	}
+
+	// without a task id the history of the whole schedule
+	occurrences, total, _ = store.GetOccurrences(author, "", 0, 10)
+	if total != 4 || len(occurrences) != 4 || occurrences[0].TaskId != task.Id || occurrences[3].TaskName != "Coffee" {
+		t.Errorf("GetOccurrences of the schedule was incorrect, got: %v of %v, want: 4, Coffee last.", len(occurrences), total)
+	}
+}
+
+func testStoreAudit(t *testing.T, store TaskStore) {
+	author := testAuthor(t, store)
+	task := newTestTask("Deploy reminder")
+	activated := *task
+	activatedTime := time.Now()
+	activated.ActivatedTime = &activatedTime
+	ann := &AuditActor{UserId: "ann", Name: "Ann", Source: AuditSourceWeb, Client: "Firefox", Ip: "10.0.0.1"}
+	bob := &AuditActor{UserId: "bob", Name: "Bob", Source: AuditSourceApi, Client: "token CI"}
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	for i, entry := range []*AuditEntry{
+		NewAuditEntry(author, ann, AuditCreate, nil, task),
+		NewAuditEntry(author, ann, AuditActivate, task, &activated),
+		NewAuditEntry(author, bob, AuditDeactivate, &activated, task),
+		NewAuditEntry(author, bob, AuditCreate, nil, newTestTask("Coffee")),
+	} {
+		entry.At = start.Add(time.Duration(i) * time.Minute)
+		if err := store.InsertAuditEntry(entry); err != nil {
+			t.Fatalf("InsertAuditEntry failed: %v", err)
+		}
+	}
+
+	entries, total, err := store.GetAuditEntries(author, &AuditFilter{TaskId: task.Id}, 0, 2)
+	if err != nil {
+		t.Fatalf("GetAuditEntries failed: %v", err)
+	}
+	if total != 3 || len(entries) != 2 || entries[0].Action != AuditDeactivate {
+		t.Fatalf("GetAuditEntries was incorrect, got: %v of %v, want: 2 of 3, newest first.", len(entries), total)
+	}
+	deactivated := entries[0]
+	if deactivated.Actor == nil || *deactivated.Actor != *bob || !deactivated.At.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Audit entry was incorrect, got: %+v, want: the deactivation by %+v.", deactivated, bob)
+	}
+	if len(deactivated.Changes) != 1 || deactivated.Changes[0].Field != "activatedTime" || deactivated.Changes[0].After != nil {
+		t.Errorf("Audit changes were incorrect, got: %+v, want: the reset activatedTime.", deactivated.Changes)
+	}
+
+	filters := []struct {
+		filter *AuditFilter
+		want   int64
+	}{
+		{&AuditFilter{}, 4},
+		{&AuditFilter{Action: AuditCreate}, 2},
+		{&AuditFilter{UserId: "bob"}, 2},
+		{&AuditFilter{Since: start.Add(time.Minute), Until: start.Add(3 * time.Minute)}, 2},
+	}
+	for _, test := range filters {
+		if _, total, _ := store.GetAuditEntries(author, test.filter, 0, 10); total != test.want {
+			t.Errorf("GetAuditEntries with %+v was incorrect, got: %v, want: %v.", test.filter, total, test.want)
+		}
+	}
+
+	if _, total, _ := store.GetAuditEntries(testAuthor(t, store), &AuditFilter{}, 0, 10); total != 0 {
+		t.Errorf("GetAuditEntries of another owner was incorrect, got: %v, want: 0.", total)
+	}
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+
+	task := newTestTask("Tea")
+	_, _ = store.InsertTask("author", task)
+	_ = store.InsertOccurrence(NewTaskOccurrence("author", task, OccurrenceFired, ""))
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Reopening the file store failed: %v", err)
+	}
+	scheduler, _ := reopened.GetScheduleByAuthor("author")
+	if names := taskNames(scheduler); !equalNames(names, "Tea") {
+		t.Errorf("Reopened tasks were incorrect, got: %v, want: %v.", names, []string{"Tea"})
+	}
+	occurrences, _, _ := reopened.GetOccurrences("author", task.Id, 0, 10)
+	if len(occurrences) != 1 || occurrences[0].Owner != "author" {
+		t.Errorf("Reopened occurrences were incorrect, got: %v, want: 1 of author.", len(occurrences))
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(path); err == nil {
+		t.Errorf("Opening a corrupt file was incorrect, got: %v, want: an error.", err)
+	}
+}
+
+func TestMemoryStore_FailedPersist(t *testing.T) {
+	store := NewMemoryStore()
+	kept, deleted := newTestTask("Tea"), newTestTask("Coffee")
+	_, _ = store.InsertTask("author", kept)
+	_, _ = store.InsertTask("author", deleted)
+	_, _ = store.DeleteTasks("author", []string{deleted.Id})
+
+	store.persist = func(data *storeData) error { return errors.New("disk full") }
+	if _, err := store.DeleteTasks("author", []string{kept.Id}); err == nil {
+		t.Fatalf("DeleteTasks with a failing persist was incorrect, got: %v, want: an error.", err)
+	}
+	if _, err := store.RestoreTasks("author", []string{deleted.Id}); err == nil {
+		t.Fatalf("RestoreTasks with a failing persist was incorrect, got: %v, want: an error.", err)
+	}
+	if _, err := store.PurgeTrash(time.Now().Add(time.Hour)); err == nil {
+		t.Fatalf("PurgeTrash with a failing persist was incorrect, got: %v, want: an error.", err)
+	}
+
+	scheduler, _ := store.GetScheduleByAuthor("author")
+	if names := taskNames(scheduler); !equalNames(names, "Tea") {
+		t.Errorf("Tasks after failed changes were incorrect, got: %v, want: %v.", names, []string{"Tea"})
+	}
+	if trash, _ := store.GetTrash("author"); len(trash) != 1 || trash[0].Task.Id != deleted.Id {
+		t.Errorf("Trash after failed changes was incorrect, got: %v, want: only %v.", len(trash), deleted.Name)
+	}
+}
@@ -3,12 +3,14 @@ package models
 import (
 	"context"
 	"errors"
+	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"github.com/rs/zerolog/log"
+	"reflect"
 	"scheduler/utils"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -21,9 +23,27 @@ const (
 )
 
 type TasksPageData struct {
+	PageData
+	Tasks  []*TaskVM
+	Filter *TaskFilter
+	// Tags are all tags in use, offered as filter suggestions
+	Tags []string
+	// Team is the team owning the shown schedule, nil for the personal schedule
+	Team *Team
+	// Teams are the teams of the user, offered as schedules to switch to
+	Teams   []*Team
+	CanEdit bool
+}
+
+type NewTaskFormPageData struct {
+	// Tasks are the possible predecessors of the new task
 	Tasks []*TaskVM
 }
 
+func (data NewTaskFormPageData) DependencyFields() DependencyFieldsData {
+	return DependencyFieldsData{Tasks: data.Tasks}
+}
+
 type TasksUpdateData struct {
 	Tasks []*TaskVM
 }
@@ -33,21 +53,32 @@ type TaskVM struct {
 	Id            string
 	Name          string
 	Active        bool
+	Paused        bool
 	Schedule      string
 	Trigger       TaskTrigger
 	IsSoon        bool
 	RemainingTime string
 	ActivatedTime string
 	TargetTime    *time.Time
+	DependsOn     *TaskDependency
+	// DependsOnName is the name of the predecessor task, resolved by GetViewTasks
+	DependsOnName string
+	Tags          []string
+	Priority      TaskPriority
+	Assignees     []*TaskAssignee
 }
 
 func (task *Task) ToTaskVM() *TaskVM {
 	viewTask := &TaskVM{
-		Id:       task.Id,
-		Name:     task.Name,
-		Active:   task.IsActive(),
-		Schedule: task.Schedule,
-		Trigger:  task.Trigger,
+		Id:        task.Id,
+		Name:      task.Name,
+		Active:    task.IsActive(),
+		Schedule:  task.Schedule,
+		Trigger:   task.Trigger,
+		DependsOn: task.DependsOn,
+		Tags:      task.Tags,
+		Priority:  task.Priority,
+		Assignees: task.Assignees,
 	}
 
 	if task.IsActive() {
@@ -59,6 +90,11 @@ func (task *Task) ToTaskVM() *TaskVM {
 		viewTask.TargetTime = &targetTime
 	}
 
+	if task.IsPaused() {
+		viewTask.Paused = true
+		viewTask.RemainingTime = task.PausedRemaining.String()
+	}
+
 	return viewTask
 }
 
@@ -68,6 +104,14 @@ type Task struct {
 	Schedule      string      `json:"schedule" bson:"schedule"`
 	ActivatedTime *time.Time  `json:"activatedTime" bson:"activatedTime"` // optional
 	Trigger       TaskTrigger `json:"trigger" bson:"trigger"`
+	// PausedRemaining is the frozen remaining duration of a paused task, nil if the task is not paused
+	PausedRemaining *time.Duration `json:"pausedRemaining,omitempty" bson:"pausedRemaining,omitempty"`
+	// DependsOn chains the task to a predecessor that activates it, nil for manually started tasks
+	DependsOn *TaskDependency `json:"dependsOn,omitempty" bson:"dependsOn,omitempty"`
+	Tags      []string        `json:"tags,omitempty" bson:"tags,omitempty"`
+	Priority  TaskPriority    `json:"priority,omitempty" bson:"priority,omitempty"`
+	// Assignees receive the alerts of a task on a team schedule, all members do if there are none
+	Assignees []*TaskAssignee `json:"assignees,omitempty" bson:"assignees,omitempty"`
 }
 
 func (task *Task) GetRemainingTime() *time.Duration {
@@ -75,6 +119,16 @@ func (task *Task) GetRemainingTime() *time.Duration {
 	return utils.CalculateRemainingTime(task.ActivatedTime, taskDuration)
 }
 
+// NextFireTime is when the timer of the task fires, nil if the task isn't running
+func (task *Task) NextFireTime() *time.Time {
+	if task.ActivatedTime == nil {
+		return nil
+	}
+	taskDuration, _ := utils.ParseDuration(task.Schedule)
+	next := task.ActivatedTime.Add(taskDuration)
+	return &next
+}
+
 func (task *Task) IsActive() bool {
 	if task.ActivatedTime == nil {
 		return false
@@ -84,25 +138,147 @@ func (task *Task) IsActive() bool {
 	return remaining.Seconds() > 0
 }
 
+func (task *Task) IsPaused() bool {
+	return task.PausedRemaining != nil
+}
+
+// Pause freezes the remaining time of an active task. It returns false if the task is not active.
+func (task *Task) Pause() bool {
+	if !task.IsActive() {
+		return false
+	}
+
+	remaining := *task.GetRemainingTime()
+	task.PausedRemaining = &remaining
+	task.ActivatedTime = nil
+
+	return true
+}
+
+// Resume continues a paused task from its frozen remaining time. The activated time is shifted into the past,
+// so that the remaining time calculation picks up where the task was paused.
+// It returns false if the task is not paused.
+func (task *Task) Resume() bool {
+	if !task.IsPaused() {
+		return false
+	}
+
+	taskDuration, _ := utils.ParseDuration(task.Schedule)
+	activatedTime := time.Now().Add(*task.PausedRemaining - taskDuration)
+	task.ActivatedTime = &activatedTime
+	task.PausedRemaining = nil
+
+	return true
+}
+
+// DefaultSnooze is how long an alert is snoozed if no duration is given
+const DefaultSnooze = 5 * time.Minute
+
+// Snooze lets the task fire again after the duration, regardless of its state.
+// Like a delayed start, the activated time is moved so that exactly the duration remains.
+func (task *Task) Snooze(duration time.Duration) {
+	taskDuration, _ := utils.ParseDuration(task.Schedule)
+	activatedTime := time.Now().Add(duration - taskDuration)
+	task.ActivatedTime = &activatedTime
+	task.PausedRemaining = nil
+}
+
 type NewTaskFormData struct {
-	Name     string      `form:"task-name" validate:"required"`
-	Schedule string      `form:"task-schedule" validate:"required"`
-	Trigger  TaskTrigger `form:"task-trigger" validate:"required"`
+	Name           string          `form:"task-name" validate:"required"`
+	Schedule       string          `form:"task-schedule" validate:"required"`
+	Trigger        TaskTrigger     `form:"task-trigger" validate:"required"`
+	Tags           string          `form:"task-tags"`
+	Priority       TaskPriority    `form:"task-priority"`
+	DependsOn      string          `form:"task-depends-on"`
+	DependsOnEvent DependencyEvent `form:"task-depends-on-event"`
+	DependsOnDelay string          `form:"task-depends-on-delay"`
+}
+
+// ToTask creates a new task with a fresh id from the form values, the dependency is set up separately
+func (formData *NewTaskFormData) ToTask() *Task {
+	return &Task{
+		Id:       utils.Uuid(),
+		Name:     formData.Name,
+		Schedule: formData.Schedule,
+		Trigger:  formData.Trigger,
+		Tags:     ParseTags(formData.Tags),
+		Priority: formData.Priority,
+	}
+}
+
+type EditTaskFormData struct {
+	Name           string          `form:"task-name" validate:"required"`
+	Schedule       string          `form:"task-schedule" validate:"required"`
+	Trigger        TaskTrigger     `form:"task-trigger" validate:"required"`
+	Tags           string          `form:"task-tags"`
+	Priority       TaskPriority    `form:"task-priority"`
+	DependsOn      string          `form:"task-depends-on"`
+	DependsOnEvent DependencyEvent `form:"task-depends-on-event"`
+	DependsOnDelay string          `form:"task-depends-on-delay"`
+	Assignees      []string        `form:"task-assignees"`
+}
+
+type EditTaskData struct {
+	Task *TaskVM
+	// Tasks are the possible predecessors of the edited task
+	Tasks []*TaskVM
+	// Members are the possible assignees, empty for the personal schedule
+	Members []*TeamMember
+	Error   string
+}
+
+// IsAssigned tells if the member is an assignee of the edited task
+func (data EditTaskData) IsAssigned(userId string) bool {
+	for _, assignee := range data.Task.Assignees {
+		if assignee.UserId == userId {
+			return true
+		}
+	}
+	return false
+}
+
+func (data EditTaskData) DependencyFields() DependencyFieldsData {
+	return DependencyFieldsData{TaskId: data.Task.Id, DependsOn: data.Task.DependsOn, Tasks: data.Tasks}
+}
+
+// DependencyFieldsData feeds the predecessor inputs shared by the new and the edit task form
+type DependencyFieldsData struct {
+	// TaskId is the edited task, which is excluded from the possible predecessors
+	TaskId    string
+	DependsOn *TaskDependency
+	Tasks     []*TaskVM
 }
 
 type ActivateTaskFormData struct {
 	TaskIds []string `form:"task-ids" validate:"required"`
 }
 
+type PauseTaskFormData struct {
+	TaskIds []string `form:"task-ids" validate:"required"`
+}
+
 type DeleteTaskFormData struct {
 	TaskIds []string `form:"task-ids" validate:"required"`
 }
 
 func GetViewTasks(tasks []*Task) []*TaskVM {
+	return getViewTasks(tasks, tasks)
+}
+
+func getViewTasks(tasks []*Task, allTasks []*Task) []*TaskVM {
 	var viewTasks []*TaskVM
 
+	taskNames := make(map[string]string, len(allTasks))
+	for _, task := range allTasks {
+		taskNames[task.Id] = task.Name
+	}
+
 	for _, task := range tasks {
-		viewTasks = append(viewTasks, task.ToTaskVM())
+		viewTask := task.ToTaskVM()
+		if task.DependsOn != nil {
+			viewTask.DependsOnName = taskNames[task.DependsOn.TaskId]
+		}
+		viewTasks = append(viewTasks, viewTask)
 	}
 
 	return viewTasks
@@ -143,164 +319,373 @@ func SortTasks(tasks []*Task) {
 }
 
 // https://github.com/mongodb-university/atlas_starter_go/blob/master/main.go
+// Schedules keep the routines of a user, the tasks are documents of their own in the tasks collection,
+// so every task is updated on its own and concurrent edits of different tasks can't overwrite each other.
 type TaskDBModel struct {
 	Client *mongo.Client
 }
 
-func (m TaskDBModel) GetScheduleByAuthor() (*Scheduler, error) {
-	author := "1337"
+// scheduleDocument is a schedule without its tasks, as stored in the schedules collection
+type scheduleDocument struct {
+	Author   string     `bson:"author"`
+	Routines []*Routine `bson:"routines,omitempty"`
+}
+
+// taskDocument is a task in the tasks collection
+type taskDocument struct {
+	Owner string `bson:"owner"`
+	// Position keeps the insertion order of the tasks of a schedule
+	Position int64 `bson:"position"`
+	// NextFireAt is when the timer of an active task fires, nil for inactive tasks
+	NextFireAt *time.Time `bson:"nextFireAt,omitempty"`
+	Task       `bson:",inline"`
+}
+
+func newTaskDocument(owner string, position int64, task *Task) *taskDocument {
+	return &taskDocument{Owner: owner, Position: position, NextFireAt: task.NextFireTime(), Task: *task}
+}
+
+// EnsureIndexes creates the indexes to find the tasks of a schedule, the next tasks to fire and the trash
+func (m TaskDBModel) EnsureIndexes() error {
 	dbName := "SchedulerCluster"
-	collectionName := "schedules"
+	collectionName := "tasks"
 	collection := m.Client.Database(dbName).Collection(collectionName)
 
-	var result Scheduler
-	filter := bson.D{{"author", author}}
-	err := collection.FindOne(context.TODO(), filter).Decode(&result)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"owner", 1}, {"id", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{"owner", 1}, {"position", 1}},
+		},
+		{
+			Keys:    bson.D{{"nextFireAt", 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	})
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			// if we dont find it in the DB, we create a new one
-			newSchedule := Scheduler{
-				Author: author,
-				Tasks:  []*Task{},
+		return err
+	}
+	return m.ensureTrashIndexes(ctx)
+}
+
+// MigrateEmbeddedTasks moves the tasks of schedules from the time tasks were embedded in their schedule
+// to the tasks collection. It's safe to run repeatedly, migrated schedules don't have a tasks field anymore.
+func (m TaskDBModel) MigrateEmbeddedTasks() (int, error) {
+	dbName := "SchedulerCluster"
+	schedules := m.Client.Database(dbName).Collection("schedules")
+	tasks := m.Client.Database(dbName).Collection("tasks")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cursor, err := schedules.Find(ctx, bson.M{"tasks": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, err
+	}
+	var embedded []*Scheduler
+	if err := cursor.All(ctx, &embedded); err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, scheduler := range embedded {
+		if len(scheduler.Tasks) > 0 {
+			writes := make([]mongo.WriteModel, 0, len(scheduler.Tasks))
+			for position, task := range scheduler.Tasks {
+				writes = append(writes, mongo.NewReplaceOneModel().
+					SetFilter(bson.M{"owner": scheduler.Author, "id": task.Id}).
+					SetReplacement(newTaskDocument(scheduler.Author, int64(position), task)).
+					SetUpsert(true))
+			}
+			if _, err := tasks.BulkWrite(ctx, writes); err != nil {
+				return migrated, err
 			}
-			m.InsertSchedule(&newSchedule)
-			log.Debug().Str("author", author).Msg("Could not find existing schedule, created new one")
-			return &newSchedule, nil
-		} else {
-			log.Error().Err(err).Msgf("Something went wrong trying to find scheduler for %s", author)
-			return nil, err
 		}
+
+		// only unset the tasks once they are copied, a failed run is simply repeated
+		if _, err := schedules.UpdateOne(ctx, bson.M{"author": scheduler.Author}, bson.M{"$unset": bson.M{"tasks": ""}}); err != nil {
+			return migrated, err
+		}
+		migrated += len(scheduler.Tasks)
+		log.Info().Str("author", scheduler.Author).Int("tasks", len(scheduler.Tasks)).Msg("Migrated embedded tasks")
+	}
+
+	return migrated, nil
+}
+
+func (m TaskDBModel) GetScheduleByAuthor(author string) (*Scheduler, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	document, err := m.findSchedule(ctx, author)
+	if err != nil {
+		log.Error().Err(err).Msgf("Something went wrong trying to find scheduler for %s", author)
+		return nil, err
 	}
-	log.Debug().Str("author", result.Author).Msg("Found existing schedule")
-	return &result, nil
+	return m.loadSchedule(ctx, document)
 }
 
-func (m TaskDBModel) InsertSchedule(schedule *Scheduler) (*Scheduler, error) {
-	log.Info().Str("author", schedule.Author).Msg("Inserting new schedule")
+// findSchedule returns the schedule document of the author without writing, an empty one if the author has none yet
+func (m TaskDBModel) findSchedule(ctx context.Context, author string) (*scheduleDocument, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "schedules"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	document := &scheduleDocument{}
+	err := collection.FindOne(ctx, bson.M{"author": author}).Decode(document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &scheduleDocument{Author: author}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
 
+// GetAllSchedules returns the schedules of all users, e.g. to register their live timers on startup
+func (m TaskDBModel) GetAllSchedules() ([]*Scheduler, error) {
 	dbName := "SchedulerCluster"
 	collectionName := "schedules"
 	collection := m.Client.Database(dbName).Collection(collectionName)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	_, err := collection.InsertOne(ctx, schedule)
+	cursor, err := collection.Find(ctx, bson.M{})
 	if err != nil {
-		log.Error().Err(err).Msg("Something went wrong trying to insert new schedule")
+		log.Error().Err(err).Msg("Something went wrong trying to find all schedules")
+		return nil, err
+	}
+
+	var documents []*scheduleDocument
+	if err := cursor.All(ctx, &documents); err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to decode all schedules")
 		return nil, err
 	}
 
-	return schedule, nil
+	tasks, err := m.findTasks(ctx, bson.M{})
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to find all tasks")
+		return nil, err
+	}
+	tasksByOwner := map[string][]*Task{}
+	for _, task := range tasks {
+		tasksByOwner[task.Owner] = append(tasksByOwner[task.Owner], &task.Task)
+	}
+
+	schedules := make([]*Scheduler, 0, len(documents))
+	for _, document := range documents {
+		scheduler := &Scheduler{Author: document.Author, Tasks: tasksByOwner[document.Author], Routines: document.Routines}
+		if scheduler.Tasks == nil {
+			scheduler.Tasks = []*Task{}
+		}
+		schedules = append(schedules, scheduler)
+	}
+	return schedules, nil
 }
 
-func (m TaskDBModel) ReplaceSchedule(scheduler *Scheduler) error {
-	author := "1337"
+// ensureSchedule returns the schedule document of the author, it's created if the author has none yet
+func (m TaskDBModel) ensureSchedule(ctx context.Context, author string) (*scheduleDocument, error) {
 	dbName := "SchedulerCluster"
 	collectionName := "schedules"
 	collection := m.Client.Database(dbName).Collection(collectionName)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	filter := bson.M{"author": author}
+	update := bson.M{"$setOnInsert": bson.M{"author": author}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(true)
+
+	document := &scheduleDocument{}
+	if err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// loadSchedule adds the tasks to a schedule document
+func (m TaskDBModel) loadSchedule(ctx context.Context, document *scheduleDocument) (*Scheduler, error) {
+	documents, err := m.findTasks(ctx, bson.M{"owner": document.Author})
+	if err != nil {
+		log.Error().Err(err).Str("author", document.Author).Msg("Something went wrong trying to find the tasks of a schedule")
+		return nil, err
+	}
+
+	scheduler := &Scheduler{Author: document.Author, Tasks: make([]*Task, 0, len(documents)), Routines: document.Routines}
+	for _, task := range documents {
+		scheduler.Tasks = append(scheduler.Tasks, &task.Task)
+	}
+	return scheduler, nil
+}
 
-	filter := bson.D{{"author", author}}
+func (m TaskDBModel) findTasks(ctx context.Context, filter bson.M) ([]*taskDocument, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "tasks"
+	collection := m.Client.Database(dbName).Collection(collectionName)
 
-	_, err := collection.ReplaceOne(ctx, filter, scheduler)
+	opts := options.Find().SetSort(bson.D{{"owner", 1}, {"position", 1}})
+	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
+		return nil, err
+	}
+
+	var documents []*taskDocument
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// ReplaceSchedule replaces the routines and the tasks of a schedule. Every task is written on its own,
+// so prefer the single task methods for changes of a few tasks.
+func (m TaskDBModel) ReplaceSchedule(scheduler *Scheduler) error {
+	author := scheduler.Author
+	dbName := "SchedulerCluster"
+	schedules := m.Client.Database(dbName).Collection("schedules")
+	tasks := m.Client.Database(dbName).Collection("tasks")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"author": author}
+	opts := options.Replace().SetUpsert(true)
+	if _, err := schedules.ReplaceOne(ctx, filter, &scheduleDocument{Author: author, Routines: scheduler.Routines}, opts); err != nil {
 		log.Error().Err(err).Msg("Something went wrong trying to update a schedule")
 		return err
 	}
 
+	taskIds := make([]string, 0, len(scheduler.Tasks))
+	writes := make([]mongo.WriteModel, 0, len(scheduler.Tasks))
+	position := time.Now().UnixNano()
+	for i, task := range scheduler.Tasks {
+		taskIds = append(taskIds, task.Id)
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"owner": author, "id": task.Id}).
+			SetReplacement(newTaskDocument(author, position+int64(i), task)).
+			SetUpsert(true))
+	}
+
+	if _, err := tasks.DeleteMany(ctx, bson.M{"owner": author, "id": bson.M{"$nin": taskIds}}); err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to remove the replaced tasks")
+		return err
+	}
+	if len(writes) > 0 {
+		if _, err := tasks.BulkWrite(ctx, writes); err != nil {
+			log.Error().Err(err).Msg("Something went wrong trying to write the tasks of a schedule")
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (m TaskDBModel) InsertTask(author string, task *Task) (*Scheduler, error) {
 	dbName := "SchedulerCluster"
-	collectionName := "schedules"
+	collectionName := "tasks"
 	collection := m.Client.Database(dbName).Collection(collectionName)
 
-	filter := bson.D{{"author", author}}
-	update := bson.D{
-		{"$push", bson.D{
-			{"tasks", task},
-		}},
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-
-	var updatedSchedule *Scheduler
+	schedule, err := m.ensureSchedule(ctx, author)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to create a schedule")
+		return nil, err
+	}
 
-	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedSchedule)
+	// the insertion time orders the tasks without a counter that concurrent inserts would have to share
+	_, err = collection.InsertOne(ctx, newTaskDocument(author, time.Now().UnixNano(), task))
 	if err != nil {
 		log.Error().Err(err).Msg("Something went wrong trying to insert a task")
 		return nil, err
 	}
 
-	return updatedSchedule, nil
+	return m.loadSchedule(ctx, schedule)
 }
 
-func (m TaskDBModel) UpdateTaskActivatedTime(task *Task) error {
+func (m TaskDBModel) UpdateTaskActivatedTime(author string, task *Task) error {
 	dbName := "SchedulerCluster"
-	collectionName := "schedules"
+	collectionName := "tasks"
 	collection := m.Client.Database(dbName).Collection(collectionName)
 
-	author := "1337"
-	filter := bson.D{
-		{"author", author},
-		{"tasks", bson.D{
-			{"$elemMatch", bson.D{{"id", task.Id}}},
-		}},
-	}
-	update := bson.D{
-		{"$set", bson.D{
-			{"tasks.$.activatedTime", task.ActivatedTime},
-		}},
+	filter := bson.M{"owner": author, "id": task.Id}
+	update := bson.M{
+		"$set": bson.M{"activatedTime": task.ActivatedTime, "nextFireAt": task.NextFireTime()},
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	res := collection.FindOneAndUpdate(ctx, filter, update)
-	if res.Err() != nil {
-		log.Error().Err(res.Err()).Msg("Something went wrong trying to update a task")
-		return res.Err()
+	res, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to update a task")
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrTaskNotFound
 	}
 	return nil
 }
 
-func (m TaskDBModel) DeleteTasks(taskIds []string) (*Scheduler, error) {
-	author := "1337"
+// UpdateTask overwrites the fields of the task with the same id in a single update and returns the updated schedule.
+// The owner and position of the stored task are kept, of concurrent updates of the same task the last one wins.
+func (m TaskDBModel) UpdateTask(author string, task *Task) (*Scheduler, error) {
 	dbName := "SchedulerCluster"
-	collectionName := "schedules"
+	collectionName := "tasks"
 	collection := m.Client.Database(dbName).Collection(collectionName)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.D{{"author", author}}
+	update, err := taskUpdate(task)
+	if err != nil {
+		return nil, err
+	}
 
-	// construct pull filters to pull items of the tasks array by ids
-	pullFilters := bson.A{}
-	for _, taskId := range taskIds {
-		pullFilters = append(pullFilters, bson.M{"id": taskId})
+	res, err := collection.UpdateOne(ctx, bson.M{"owner": author, "id": task.Id}, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to update a task")
+		return nil, err
 	}
-	pullFilter := bson.M{"$or": pullFilters}
-	update := bson.M{
-		"$pull": bson.M{"tasks": pullFilter},
+	if res.MatchedCount == 0 {
+		return nil, ErrTaskNotFound
 	}
 
-	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-
-	var updatedSchedule *Scheduler
+	return m.GetScheduleByAuthor(author)
+}
 
-	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedSchedule)
+// taskUpdate sets every field of the task and unsets the ones it leaves empty, so the stored task matches it
+// like after a replacement while the fields of the document around it stay untouched
+func taskUpdate(task *Task) (bson.M, error) {
+	encoded, err := bson.Marshal(task)
 	if err != nil {
-		log.Error().Err(err).Msg("Something went wrong trying to delete tasks")
+		return nil, err
+	}
+	set := bson.M{}
+	if err := bson.Unmarshal(encoded, &set); err != nil {
 		return nil, err
 	}
 
-	return updatedSchedule, nil
+	unset := bson.M{}
+	if nextFireAt := task.NextFireTime(); nextFireAt != nil {
+		set["nextFireAt"] = nextFireAt
+	} else {
+		unset["nextFireAt"] = ""
+	}
+	taskType := reflect.TypeOf(Task{})
+	for i := 0; i < taskType.NumField(); i++ {
+		name, _, _ := strings.Cut(taskType.Field(i).Tag.Get("bson"), ",")
+		if _, exists := set[name]; name != "" && !exists {
+			unset[name] = ""
+		}
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
 }
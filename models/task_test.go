@@ -0,0 +1,108 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTask_Pause_FreezesRemainingTime(t *testing.T) {
+	activatedTime := time.Now().Add(-10 * time.Minute)
+	task := &Task{Id: "1", Name: "Stir pot", Schedule: "in 20min", ActivatedTime: &activatedTime}
+
+	if !task.Pause() {
+		t.Fatalf("Active task could not be paused")
+	}
+	if task.IsActive() {
+		t.Errorf("Paused task is still active")
+	}
+	if remaining := task.PausedRemaining.Round(time.Minute); remaining != 10*time.Minute {
+		t.Errorf("Paused remaining time was incorrect, got: %s, want: %s.", remaining, 10*time.Minute)
+	}
+}
+
+func TestTask_Pause_Inactive(t *testing.T) {
+	task := &Task{Id: "1", Name: "Stir pot", Schedule: "in 20min"}
+
+	if task.Pause() {
+		t.Errorf("Inactive task was paused")
+	}
+}
+
+func TestTask_Resume_ContinuesFromRemainingTime(t *testing.T) {
+	remaining := 5 * time.Minute
+	task := &Task{Id: "1", Name: "Take a break", Schedule: "every 2h", PausedRemaining: &remaining}
+
+	if !task.Resume() {
+		t.Fatalf("Paused task could not be resumed")
+	}
+	if task.IsPaused() {
+		t.Errorf("Resumed task is still paused")
+	}
+	if !task.IsActive() {
+		t.Errorf("Resumed task is not active")
+	}
+	if got := task.GetRemainingTime().Round(time.Minute); got != remaining {
+		t.Errorf("Remaining time was incorrect, got: %s, want: %s.", got, remaining)
+	}
+}
+
+func TestTask_NextFireTime(t *testing.T) {
+	activatedTime := time.Now()
+	task := &Task{Id: "1", Name: "Stir pot", Schedule: "in 20min", ActivatedTime: &activatedTime}
+
+	if next := task.NextFireTime(); next == nil || !next.Equal(activatedTime.Add(20*time.Minute)) {
+		t.Errorf("NextFireTime was incorrect, got: %v, want: %v.", next, activatedTime.Add(20*time.Minute))
+	}
+
+	task.ActivatedTime = nil
+	if next := task.NextFireTime(); next != nil {
+		t.Errorf("NextFireTime of an inactive task was incorrect, got: %v, want: nil.", next)
+	}
+}
+
+func TestTaskDocument_Inline(t *testing.T) {
+	activatedTime := time.Now()
+	task := &Task{Id: "1", Name: "Stir pot", Schedule: "in 20min", ActivatedTime: &activatedTime}
+
+	data, err := bson.Marshal(newTaskDocument("author", 3, task))
+	if err != nil {
+		t.Fatalf("Marshalling a task document failed: %v", err)
+	}
+	raw := bson.Raw(data)
+	for _, key := range []string{"owner", "position", "nextFireAt", "id", "name", "activatedTime"} {
+		if _, err := raw.LookupErr(key); err != nil {
+			t.Errorf("Task document field %s was incorrect, got: %v, want: a top level field.", key, err)
+		}
+	}
+
+	decoded := &taskDocument{}
+	if err := bson.Unmarshal(data, decoded); err != nil || decoded.Task.Id != "1" || decoded.Owner != "author" {
+		t.Errorf("Decoded task document was incorrect, got: %+v %v, want: task 1 of author.", decoded, err)
+	}
+}
+
+func TestTaskUpdate(t *testing.T) {
+	activatedTime := time.Now()
+	task := &Task{Id: "1", Name: "Stir pot", Schedule: "in 20min", ActivatedTime: &activatedTime, Tags: []string{"kitchen"}}
+
+	update, err := taskUpdate(task)
+	if err != nil {
+		t.Fatalf("Building the update failed: %v", err)
+	}
+	set, unset := update["$set"].(bson.M), update["$unset"].(bson.M)
+	if set["name"] != "Stir pot" || set["nextFireAt"] == nil || set["tags"] == nil {
+		t.Errorf("Set fields were incorrect, got: %v, want: name, nextFireAt and tags.", set)
+	}
+	for _, name := range []string{"pausedRemaining", "dependsOn", "priority", "assignees"} {
+		if _, exists := unset[name]; !exists {
+			t.Errorf("Unset fields were incorrect, got: %v, want: %v.", unset, name)
+		}
+	}
+	for _, name := range []string{"position", "owner"} {
+		if _, exists := set[name]; exists {
+			t.Errorf("Update touches the %s, got: %v.", name, set)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"slices"
+	"strings"
+	"time"
+)
+
+type TeamRole string
+
+const (
+	// RoleViewer sees the team schedule and receives its alerts
+	RoleViewer TeamRole = "viewer"
+	// RoleEditor additionally creates, edits and triggers tasks and routines
+	RoleEditor TeamRole = "editor"
+	// RoleAdmin additionally invites members and changes their roles
+	RoleAdmin TeamRole = "admin"
+)
+
+var (
+	ErrTeamRole           = errors.New("unknown team role")
+	ErrLastAdmin          = errors.New("a team needs at least one admin")
+	ErrAlreadyMember      = errors.New("user is already a member of the team")
+	ErrInvitationNotFound = errors.New("invitation not found")
+)
+
+func (r TeamRole) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Includes tells if the role grants at least the permissions of the other role
+func (r TeamRole) Includes(other TeamRole) bool {
+	return r.rank() > 0 && r.rank() >= other.rank()
+}
+
+func ParseTeamRole(value string) (TeamRole, error) {
+	role := TeamRole(value)
+	if role.rank() == 0 {
+		return "", ErrTeamRole
+	}
+	return role, nil
+}
+
+// Team owns a shared schedule, its Id is stored as the Author of the Scheduler
+type Team struct {
+	Id          string            `json:"id" bson:"id"`
+	Name        string            `json:"name" bson:"name"`
+	Members     []*TeamMember     `json:"members" bson:"members"`
+	Invitations []*TeamInvitation `json:"invitations" bson:"invitations"`
+	CreatedAt   time.Time         `json:"createdAt" bson:"createdAt"`
+}
+
+type TeamMember struct {
+	UserId string   `json:"userId" bson:"userId"`
+	Name   string   `json:"name" bson:"name"`
+	Role   TeamRole `json:"role" bson:"role"`
+}
+
+// TeamInvitation is pending until the invited user accepts or declines it
+type TeamInvitation struct {
+	Id        string    `json:"id" bson:"id"`
+	UserId    string    `json:"userId" bson:"userId"`
+	Name      string    `json:"name" bson:"name"`
+	Role      TeamRole  `json:"role" bson:"role"`
+	InvitedBy string    `json:"invitedBy" bson:"invitedBy"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// Role returns the role of the user in the team, empty if the user is no member
+func (t *Team) Role(userId string) TeamRole {
+	for _, member := range t.Members {
+		if member.UserId == userId {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+// MemberIds returns the user ids of all members, they receive the alerts of the team schedule
+func (t *Team) MemberIds() []string {
+	ids := make([]string, 0, len(t.Members))
+	for _, member := range t.Members {
+		ids = append(ids, member.UserId)
+	}
+	return ids
+}
+
+// CanChangeMember tells if the member can be demoted or removed without leaving the team without an admin
+func (t *Team) CanChangeMember(userId string, newRole TeamRole) bool {
+	if t.Role(userId) != RoleAdmin || newRole == RoleAdmin {
+		return true
+	}
+	for _, member := range t.Members {
+		if member.UserId != userId && member.Role == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// hasInvitation tells if the team has the invitation of the user
+func (t *Team) hasInvitation(userId string, invitationId string) bool {
+	return slices.ContainsFunc(t.Invitations, func(invitation *TeamInvitation) bool {
+		return invitation.UserId == userId && (invitationId == "" || invitation.Id == invitationId)
+	})
+}
+
+// The changes below are the updates of TeamDBModel on a team in memory, for the stores that replace the whole team
+
+func (t *Team) addInvitation(invitation *TeamInvitation) error {
+	if t.Role(invitation.UserId) != "" || t.hasInvitation(invitation.UserId, "") {
+		return ErrAlreadyMember
+	}
+	t.Invitations = append(t.Invitations, invitation)
+	return nil
+}
+
+func (t *Team) acceptInvitation(userId string, invitationId string) error {
+	index := slices.IndexFunc(t.Invitations, func(invitation *TeamInvitation) bool {
+		return invitation.UserId == userId && invitation.Id == invitationId
+	})
+	if index < 0 || t.Role(userId) != "" {
+		return ErrInvitationNotFound
+	}
+
+	invitation := t.Invitations[index]
+	t.Invitations = slices.Delete(t.Invitations, index, index+1)
+	t.Members = append(t.Members, &TeamMember{UserId: userId, Name: invitation.Name, Role: invitation.Role})
+	return nil
+}
+
+func (t *Team) deleteInvitation(invitationId string) {
+	t.Invitations = slices.DeleteFunc(t.Invitations, func(invitation *TeamInvitation) bool { return invitation.Id == invitationId })
+}
+
+func (t *Team) setMemberRole(userId string, role TeamRole) error {
+	for _, member := range t.Members {
+		if member.UserId == userId {
+			member.Role = role
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (t *Team) removeMember(userId string) {
+	t.Members = slices.DeleteFunc(t.Members, func(member *TeamMember) bool { return member.UserId == userId })
+}
+
+// sortTeams sorts the teams by name like the queries of TeamDBModel
+func sortTeams(teams []*Team) {
+	slices.SortStableFunc(teams, func(a, b *Team) int { return strings.Compare(a.Name, b.Name) })
+}
+
+type NewTeamFormData struct {
+	Name string `form:"team-name" validate:"required"`
+}
+
+type InviteFormData struct {
+	Name string `form:"invite-name" validate:"required"`
+	Role string `form:"invite-role" validate:"required"`
+}
+
+type MemberRoleFormData struct {
+	Role string `form:"member-role" validate:"required"`
+}
+
+type TeamsPageData struct {
+	PageData
+	Teams       []*Team
+	Invitations []*TeamInvitationVM
+	Error       string
+}
+
+// TeamInvitationVM is an invitation of the current user together with the team it's for
+type TeamInvitationVM struct {
+	*TeamInvitation
+	TeamId   string
+	TeamName string
+}
+
+type TeamDBModel struct {
+	Client *mongo.Client
+}
+
+func (m TeamDBModel) EnsureIndexes() error {
+	dbName := "SchedulerCluster"
+	collectionName := "teams"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"id": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"members.userId": 1}},
+		{Keys: bson.M{"invitations.userId": 1}},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to create team indexes")
+	}
+	return err
+}
+
+func (m TeamDBModel) InsertTeam(team *Team) (*Team, error) {
+	log.Info().Str("team", team.Name).Msg("Inserting new team")
+
+	dbName := "SchedulerCluster"
+	collectionName := "teams"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, team)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to insert new team")
+		return nil, err
+	}
+
+	return team, nil
+}
+
+func (m TeamDBModel) GetTeam(id string) (*Team, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "teams"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var team Team
+	err := collection.FindOne(ctx, bson.M{"id": id}).Decode(&team)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Error().Err(err).Msg("Something went wrong trying to find a team")
+		}
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+func (m TeamDBModel) GetTeamsByMember(userId string) ([]*Team, error) {
+	return m.findTeams(bson.M{"members.userId": userId})
+}
+
+func (m TeamDBModel) GetTeamsByInvitation(userId string) ([]*Team, error) {
+	return m.findTeams(bson.M{"invitations.userId": userId})
+}
+
+func (m TeamDBModel) findTeams(filter bson.M) ([]*Team, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "teams"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"name": 1})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to find teams")
+		return nil, err
+	}
+
+	teams := []*Team{}
+	if err := cursor.All(ctx, &teams); err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to decode teams")
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// AddInvitation invites a user who is neither member nor invited yet
+func (m TeamDBModel) AddInvitation(teamId string, invitation *TeamInvitation) (*Team, error) {
+	filter := bson.M{
+		"id":                 teamId,
+		"members.userId":     bson.M{"$ne": invitation.UserId},
+		"invitations.userId": bson.M{"$ne": invitation.UserId},
+	}
+	team, err := m.updateTeam(filter, bson.M{"$push": bson.M{"invitations": invitation}})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAlreadyMember
+	}
+	return team, err
+}
+
+// AcceptInvitation turns the invitation of the user into a membership with the invited role
+func (m TeamDBModel) AcceptInvitation(userId string, invitationId string) (*Team, error) {
+	team, err := m.FindInvitationTeam(userId, invitationId)
+	if err != nil {
+		return nil, err
+	}
+
+	var invitation *TeamInvitation
+	for _, teamInvitation := range team.Invitations {
+		if teamInvitation.Id == invitationId {
+			invitation = teamInvitation
+		}
+	}
+
+	filter := bson.M{"id": team.Id, "invitations.id": invitationId, "members.userId": bson.M{"$ne": userId}}
+	update := bson.M{
+		"$pull": bson.M{"invitations": bson.M{"id": invitationId}},
+		"$push": bson.M{"members": &TeamMember{UserId: userId, Name: invitation.Name, Role: invitation.Role}},
+	}
+	team, err = m.updateTeam(filter, update)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvitationNotFound
+	}
+	return team, err
+}
+
+// DeleteInvitation removes an invitation, either declined by the invited user or revoked by an admin of the team
+func (m TeamDBModel) DeleteInvitation(teamId string, invitationId string) (*Team, error) {
+	return m.updateTeam(bson.M{"id": teamId}, bson.M{"$pull": bson.M{"invitations": bson.M{"id": invitationId}}})
+}
+
+// FindInvitationTeam returns the team the invitation of the user belongs to
+func (m TeamDBModel) FindInvitationTeam(userId string, invitationId string) (*Team, error) {
+	teams, err := m.findTeams(bson.M{"invitations": bson.M{"$elemMatch": bson.M{"id": invitationId, "userId": userId}}})
+	if err != nil {
+		return nil, err
+	}
+	if len(teams) == 0 {
+		return nil, ErrInvitationNotFound
+	}
+	return teams[0], nil
+}
+
+func (m TeamDBModel) UpdateMemberRole(teamId string, userId string, role TeamRole) (*Team, error) {
+	filter := bson.M{"id": teamId, "members.userId": userId}
+	return m.updateTeam(filter, bson.M{"$set": bson.M{"members.$.role": role}})
+}
+
+func (m TeamDBModel) RemoveMember(teamId string, userId string) (*Team, error) {
+	return m.updateTeam(bson.M{"id": teamId}, bson.M{"$pull": bson.M{"members": bson.M{"userId": userId}}})
+}
+
+func (m TeamDBModel) updateTeam(filter bson.M, update bson.M) (*Team, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "teams"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var team Team
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&team)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Error().Err(err).Msg("Something went wrong trying to update a team")
+		}
+		return nil, err
+	}
+
+	return &team, nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestTeamRole_Includes(t *testing.T) {
+	tests := []struct {
+		role  TeamRole
+		other TeamRole
+		want  bool
+	}{
+		{RoleAdmin, RoleEditor, true},
+		{RoleEditor, RoleEditor, true},
+		{RoleViewer, RoleEditor, false},
+		{"", RoleViewer, false},
+	}
+
+	for _, test := range tests {
+		if got := test.role.Includes(test.other); got != test.want {
+			t.Errorf("%s includes %s was incorrect, got: %t, want: %t.", test.role, test.other, got, test.want)
+		}
+	}
+
+	if _, err := ParseTeamRole("owner"); err != ErrTeamRole {
+		t.Errorf("Unknown role error was incorrect, got: %v, want: %v.", err, ErrTeamRole)
+	}
+}
+
+func TestTeam_CanChangeMember(t *testing.T) {
+	team := &Team{Members: []*TeamMember{
+		{UserId: "ann", Role: RoleAdmin},
+		{UserId: "bob", Role: RoleEditor},
+	}}
+
+	if team.CanChangeMember("ann", RoleEditor) {
+		t.Errorf("Demoting the last admin should not be allowed")
+	}
+	if team.CanChangeMember("ann", "") {
+		t.Errorf("Removing the last admin should not be allowed")
+	}
+	if !team.CanChangeMember("bob", "") {
+		t.Errorf("Removing an editor should be allowed")
+	}
+
+	team.Members[1].Role = RoleAdmin
+	if !team.CanChangeMember("ann", RoleViewer) {
+		t.Errorf("Demoting one of two admins should be allowed")
+	}
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"scheduler/utils"
+	"slices"
+	"time"
+)
+
+type TokenScope string
+
+const (
+	// ScopeRead allows reading tasks, routines and the event stream
+	ScopeRead TokenScope = "read"
+	// ScopeTrigger allows activating, deactivating, pausing, resuming and finishing tasks and starting routines
+	ScopeTrigger TokenScope = "trigger"
+	// ScopeManage allows creating, editing and deleting tasks and routines
+	ScopeManage TokenScope = "manage"
+)
+
+// ApiTokenPrefix marks API tokens so they are recognizable in scripts and secret scanners
+const ApiTokenPrefix = "sch_"
+
+var ErrTokenScope = errors.New("unknown token scope")
+
+// ApiToken is a long-lived personal token for scripts. Only the hash of the token is stored, it's shown once on creation.
+type ApiToken struct {
+	Id         string       `json:"id" bson:"id"`
+	UserId     string       `json:"-" bson:"userId"`
+	Name       string       `json:"name" bson:"name"`
+	Hash       string       `json:"-" bson:"hash"`
+	Scopes     []TokenScope `json:"scopes" bson:"scopes"`
+	CreatedAt  time.Time    `json:"createdAt" bson:"createdAt"`
+	LastUsedAt *time.Time   `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+}
+
+// HasScope tells if the token grants the scope. Every scope includes reading and managing includes triggering.
+func (t *ApiToken) HasScope(scope TokenScope) bool {
+	switch scope {
+	case ScopeRead:
+		return len(t.Scopes) > 0
+	case ScopeTrigger:
+		return slices.Contains(t.Scopes, ScopeTrigger) || slices.Contains(t.Scopes, ScopeManage)
+	default:
+		return slices.Contains(t.Scopes, scope)
+	}
+}
+
+// ParseTokenScopes validates the scopes of the token form, at least one is required
+func ParseTokenScopes(values []string) ([]TokenScope, error) {
+	var scopes []TokenScope
+	for _, value := range values {
+		scope := TokenScope(value)
+		switch scope {
+		case ScopeRead, ScopeTrigger, ScopeManage:
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		default:
+			return nil, ErrTokenScope
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, ErrTokenScope
+	}
+	return scopes, nil
+}
+
+type NewTokenFormData struct {
+	Name   string   `form:"token-name" validate:"required"`
+	Scopes []string `form:"token-scopes"`
+}
+
+type TokensPageData struct {
+	PageData
+	Tokens []*ApiToken
+	// NewToken is the plain token right after creation, it can't be shown again
+	NewToken string
+	Error    string
+}
+
+// newApiToken creates a random token, only its hash is kept in the returned ApiToken
+func newApiToken(userId string, name string, scopes []TokenScope) (*ApiToken, string) {
+	plainToken := ApiTokenPrefix + utils.RandomToken()
+	return &ApiToken{
+		Id:        utils.Uuid(),
+		UserId:    userId,
+		Name:      name,
+		Hash:      HashToken(plainToken),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}, plainToken
+}
+
+type TokenDBModel struct {
+	Client *mongo.Client
+}
+
+func (m TokenDBModel) EnsureIndexes() error {
+	dbName := "SchedulerCluster"
+	collectionName := "tokens"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"hash": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"userId": 1}},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to create token indexes")
+	}
+	return err
+}
+
+// CreateToken stores a new token for the user and returns it together with the plain token
+func (m TokenDBModel) CreateToken(userId string, name string, scopes []TokenScope) (*ApiToken, string, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "tokens"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	token, plainToken := newApiToken(userId, name, scopes)
+
+	_, err := collection.InsertOne(ctx, token)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to insert new token")
+		return nil, "", err
+	}
+
+	return token, plainToken, nil
+}
+
+// GetToken looks up a plain token and records its use
+func (m TokenDBModel) GetToken(plainToken string) (*ApiToken, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "tokens"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"hash": HashToken(plainToken)}
+	update := bson.M{"$set": bson.M{"lastUsedAt": time.Now()}}
+
+	var token ApiToken
+	err := collection.FindOneAndUpdate(ctx, filter, update).Decode(&token)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Error().Err(err).Msg("Something went wrong trying to find a token")
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (m TokenDBModel) GetTokensByUser(userId string) ([]*ApiToken, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "tokens"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"createdAt": 1})
+	cursor, err := collection.Find(ctx, bson.M{"userId": userId}, opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to find tokens")
+		return nil, err
+	}
+
+	tokens := []*ApiToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to decode tokens")
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// DeleteToken revokes a token, only the owning user can do so
+func (m TokenDBModel) DeleteToken(userId string, id string) error {
+	dbName := "SchedulerCluster"
+	collectionName := "tokens"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.DeleteOne(ctx, bson.M{"userId": userId, "id": id})
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to delete a token")
+	}
+	return err
+}
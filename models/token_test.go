@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestApiToken_HasScope(t *testing.T) {
+	tests := []struct {
+		scopes []TokenScope
+		scope  TokenScope
+		want   bool
+	}{
+		{[]TokenScope{ScopeRead}, ScopeRead, true},
+		{[]TokenScope{ScopeRead}, ScopeTrigger, false},
+		{[]TokenScope{ScopeTrigger}, ScopeRead, true},
+		{[]TokenScope{ScopeTrigger}, ScopeManage, false},
+		{[]TokenScope{ScopeManage}, ScopeTrigger, true},
+		{[]TokenScope{}, ScopeRead, false},
+	}
+
+	for _, test := range tests {
+		token := &ApiToken{Scopes: test.scopes}
+		if got := token.HasScope(test.scope); got != test.want {
+			t.Errorf("%v has scope %s was incorrect, got: %t, want: %t.", test.scopes, test.scope, got, test.want)
+		}
+	}
+}
+
+func TestParseTokenScopes(t *testing.T) {
+	scopes, err := ParseTokenScopes([]string{"read", "trigger", "read"})
+	if err != nil || len(scopes) != 2 {
+		t.Errorf("Scopes were incorrect, got: %v (%v), want: [read trigger].", scopes, err)
+	}
+
+	if _, err := ParseTokenScopes([]string{"admin"}); err != ErrTokenScope {
+		t.Errorf("Unknown scope error was incorrect, got: %v, want: %v.", err, ErrTokenScope)
+	}
+	if _, err := ParseTokenScopes(nil); err != ErrTokenScope {
+		t.Errorf("Missing scope error was incorrect, got: %v, want: %v.", err, ErrTokenScope)
+	}
+}
@@ -0,0 +1,230 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultTrashRetention is how long deleted tasks can be restored before they are purged
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// DeletedTask is a task in the trash of a schedule
+type DeletedTask struct {
+	Task      *Task     `json:"task"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// DeletedTaskVM is a deleted task in the trash view
+type DeletedTaskVM struct {
+	Task      *TaskVM
+	DeletedAt time.Time
+	PurgeAt   time.Time
+}
+
+type TrashPageData struct {
+	PageData
+	Tasks []*DeletedTaskVM
+	// Team is the team owning the trash, nil for the personal schedule
+	Team    *Team
+	CanEdit bool
+}
+
+// UndoToastData are the tasks a user just deleted, offered to be restored
+type UndoToastData struct {
+	Tasks    []*Task
+	Schedule string
+}
+
+// DeletedTaskResource is a deleted task in the JSON API
+type DeletedTaskResource struct {
+	*TaskResource
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+func NewDeletedTaskResource(deleted *DeletedTask) *DeletedTaskResource {
+	return &DeletedTaskResource{TaskResource: NewTaskResource(deleted.Task), DeletedAt: deleted.DeletedAt}
+}
+
+// RestoreTaskFormData are the tasks to restore from the trash, by the trash view and the undo toast
+type RestoreTaskFormData struct {
+	TaskIds []string `form:"task-ids" validate:"required"`
+}
+
+// trashDocument is a task in the trash collection, it keeps the id of the task so it can't be restored twice
+type trashDocument struct {
+	Owner     string    `bson:"owner"`
+	DeletedAt time.Time `bson:"deletedAt"`
+	Task      `bson:",inline"`
+}
+
+// ensureTrashIndexes creates the indexes to find the trash of a schedule and the tasks to purge
+func (m TaskDBModel) ensureTrashIndexes(ctx context.Context) error {
+	dbName := "SchedulerCluster"
+	collectionName := "trash"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"owner", 1}, {"id", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{"deletedAt", 1}},
+		},
+	})
+	return err
+}
+
+func (m TaskDBModel) DeleteTasks(author string, taskIds []string) (*Scheduler, error) {
+	dbName := "SchedulerCluster"
+	tasks := m.Client.Database(dbName).Collection("tasks")
+	trash := m.Client.Database(dbName).Collection("trash")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if len(taskIds) > 0 {
+		filter := bson.M{"owner": author, "id": bson.M{"$in": taskIds}}
+		documents, err := m.findTasks(ctx, filter)
+		if err != nil {
+			log.Error().Err(err).Msg("Something went wrong trying to find the tasks to delete")
+			return nil, err
+		}
+
+		// the tasks are copied before they are deleted, a failure in between leaves them in both places
+		if len(documents) > 0 {
+			deletedAt := time.Now()
+			writes := make([]mongo.WriteModel, 0, len(documents))
+			for _, document := range documents {
+				writes = append(writes, mongo.NewReplaceOneModel().
+					SetFilter(bson.M{"owner": author, "id": document.Id}).
+					SetReplacement(&trashDocument{Owner: author, DeletedAt: deletedAt, Task: document.Task}).
+					SetUpsert(true))
+			}
+			if _, err := trash.BulkWrite(ctx, writes); err != nil {
+				log.Error().Err(err).Msg("Something went wrong trying to move tasks to the trash")
+				return nil, err
+			}
+		}
+
+		if _, err := tasks.DeleteMany(ctx, filter); err != nil {
+			log.Error().Err(err).Msg("Something went wrong trying to delete tasks")
+			return nil, err
+		}
+	}
+
+	return m.GetScheduleByAuthor(author)
+}
+
+func (m TaskDBModel) GetTrash(author string) ([]*DeletedTask, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "trash"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"owner": author}, options.Find().SetSort(bson.D{{"deletedAt", -1}}))
+	if err != nil {
+		return nil, err
+	}
+	var documents []*trashDocument
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+
+	trash := make([]*DeletedTask, 0, len(documents))
+	for _, document := range documents {
+		task := document.Task
+		trash = append(trash, &DeletedTask{Task: &task, DeletedAt: document.DeletedAt})
+	}
+	return trash, nil
+}
+
+func (m TaskDBModel) RestoreTasks(author string, taskIds []string) (*Scheduler, error) {
+	dbName := "SchedulerCluster"
+	tasks := m.Client.Database(dbName).Collection("tasks")
+	trash := m.Client.Database(dbName).Collection("trash")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := m.ensureSchedule(ctx, author); err != nil {
+		return nil, err
+	}
+
+	cursor, err := trash.Find(ctx, bson.M{"owner": author, "id": bson.M{"$in": taskIds}})
+	if err != nil {
+		return nil, err
+	}
+	var documents []*trashDocument
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+
+	for _, document := range documents {
+		_, err := tasks.InsertOne(ctx, newTaskDocument(author, time.Now().UnixNano(), &document.Task))
+		if mongo.IsDuplicateKeyError(err) {
+			// the id is in use again, the task stays in the trash
+			continue
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Something went wrong trying to restore a task")
+			return nil, err
+		}
+		if _, err := trash.DeleteOne(ctx, bson.M{"owner": author, "id": document.Id}); err != nil {
+			return nil, err
+		}
+	}
+
+	return m.GetScheduleByAuthor(author)
+}
+
+func (m TaskDBModel) ReplaceTrash(author string, deleted []*DeletedTask) error {
+	dbName := "SchedulerCluster"
+	trash := m.Client.Database(dbName).Collection("trash")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := m.ensureSchedule(ctx, author); err != nil {
+		return err
+	}
+	if _, err := trash.DeleteMany(ctx, bson.M{"owner": author}); err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to clear the trash")
+		return err
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, 0, len(deleted))
+	for _, task := range deleted {
+		documents = append(documents, &trashDocument{Owner: author, DeletedAt: task.DeletedAt, Task: *task.Task})
+	}
+	if _, err := trash.InsertMany(ctx, documents); err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to fill the trash")
+		return err
+	}
+	return nil
+}
+
+func (m TaskDBModel) PurgeTrash(before time.Time) (int64, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "trash"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	res, err := collection.DeleteMany(ctx, bson.M{"deletedAt": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
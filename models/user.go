@@ -0,0 +1,241 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+	"scheduler/utils"
+	"time"
+)
+
+// LegacyAuthor is the author of the schedule created before user accounts existed
+const LegacyAuthor = "1337"
+
+// User owns a schedule, its Id is stored as the Author of the Scheduler
+type User struct {
+	Id   string `json:"id" bson:"id"`
+	Name string `json:"name" bson:"name"`
+	// PasswordHash is the bcrypt hash of the password, empty for users provisioned by a trusted proxy
+	PasswordHash string `json:"-" bson:"passwordHash,omitempty"`
+	// OidcSubject is the issuer and subject of the OpenID Connect identity, empty for other users
+	OidcSubject string `json:"-" bson:"oidcSubject,omitempty"`
+	// WebhookUrl receives the alerts of the user's tasks as JSON, empty if the user has no personal notifier
+	WebhookUrl string    `json:"-" bson:"webhookUrl,omitempty"`
+	CreatedAt  time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+var ErrUserExists = errors.New("user already exists")
+
+type UserDBModel struct {
+	Client *mongo.Client
+}
+
+// EnsureIndexes creates the unique indexes that keep concurrent first requests from creating the same user twice
+func (m UserDBModel) EnsureIndexes() error {
+	dbName := "SchedulerCluster"
+	collectionName := "users"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"id": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"name": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"oidcSubject": 1}, Options: options.Index().SetUnique(true).SetSparse(true)},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to create user indexes")
+	}
+	return err
+}
+
+func (m UserDBModel) GetUserById(id string) (*User, error) {
+	return m.findUser(bson.M{"id": id})
+}
+
+func (m UserDBModel) GetUserByName(name string) (*User, error) {
+	return m.findUser(bson.M{"name": name})
+}
+
+func (m UserDBModel) GetUserByOidcSubject(subject string) (*User, error) {
+	return m.findUser(bson.M{"oidcSubject": subject})
+}
+
+func (m UserDBModel) findUser(filter bson.M) (*User, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "users"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user User
+	err := collection.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Error().Err(err).Msg("Something went wrong trying to find a user")
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (m UserDBModel) InsertUser(user *User) (*User, error) {
+	log.Info().Str("user", user.Name).Msg("Inserting new user")
+
+	dbName := "SchedulerCluster"
+	collectionName := "users"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.InsertOne(ctx, user)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrUserExists
+		}
+		log.Error().Err(err).Msg("Something went wrong trying to insert new user")
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ClaimLegacyUser sets the fields of the claim on the legacy user, which can only be claimed once by a password or OIDC account
+func (m UserDBModel) ClaimLegacyUser(claim *User) (*User, error) {
+	dbName := "SchedulerCluster"
+	collectionName := "users"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fields := bson.M{"name": claim.Name}
+	if claim.PasswordHash != "" {
+		fields["passwordHash"] = claim.PasswordHash
+	}
+	if claim.OidcSubject != "" {
+		fields["oidcSubject"] = claim.OidcSubject
+	}
+
+	filter := bson.M{"id": LegacyAuthor, "passwordHash": bson.M{"$exists": false}, "oidcSubject": bson.M{"$exists": false}}
+	update := bson.M{
+		"$set":         fields,
+		"$setOnInsert": bson.M{"createdAt": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var user User
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrUserExists
+		}
+		log.Error().Err(err).Msg("Something went wrong trying to claim the legacy user")
+		return nil, err
+	}
+
+	log.Info().Str("user", user.Name).Msg("Claimed legacy user")
+	return &user, nil
+}
+
+// RegisterUser creates a new account with a hashed password.
+// If claimLegacy is set, the account takes over the legacy user and with it the schedule from before user accounts,
+// as long as the legacy user has no password yet.
+func RegisterUser(users UserStore, name string, password string, claimLegacy bool) (*User, error) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := users.GetUserByName(name); err == nil {
+		return nil, ErrUserExists
+	}
+
+	if claimLegacy {
+		return users.ClaimLegacyUser(&User{Name: name, PasswordHash: string(passwordHash)})
+	}
+
+	return users.InsertUser(&User{Id: utils.Uuid(), Name: name, PasswordHash: string(passwordHash), CreatedAt: time.Now()})
+}
+
+// Authenticate returns the user if the password matches its hash
+func Authenticate(users UserStore, name string, password string) (*User, error) {
+	user, err := users.GetUserByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PasswordHash == "" {
+		return nil, bcrypt.ErrMismatchedHashAndPassword
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// FindOrCreateUser returns the user with the given name, a new account without password is created on first sight.
+// It's used for users authenticated by a trusted proxy.
+func FindOrCreateUser(users UserStore, name string) (*User, error) {
+	user, err := users.GetUserByName(name)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	return users.InsertUser(&User{Id: utils.Uuid(), Name: name, CreatedAt: time.Now()})
+}
+
+// FindOrCreateOIDCUser returns the user linked to the OpenID Connect subject, a new account is created on first login.
+// A name that already belongs to another account is not taken over, the login is rejected with ErrUserExists.
+func FindOrCreateOIDCUser(users UserStore, subject string, name string, claimLegacy bool) (*User, error) {
+	user, err := users.GetUserByOidcSubject(subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if _, err := users.GetUserByName(name); err == nil {
+		return nil, ErrUserExists
+	}
+
+	if claimLegacy {
+		return users.ClaimLegacyUser(&User{Name: name, OidcSubject: subject})
+	}
+
+	return users.InsertUser(&User{Id: utils.Uuid(), Name: name, OidcSubject: subject, CreatedAt: time.Now()})
+}
+
+// UpdateWebhookUrl sets the personal webhook of the user, an empty url removes it
+func (m UserDBModel) UpdateWebhookUrl(userId string, webhookUrl string) error {
+	dbName := "SchedulerCluster"
+	collectionName := "users"
+	collection := m.Client.Database(dbName).Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"webhookUrl": webhookUrl}}
+	if webhookUrl == "" {
+		update = bson.M{"$unset": bson.M{"webhookUrl": ""}}
+	}
+
+	_, err := collection.UpdateOne(ctx, bson.M{"id": userId}, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Something went wrong trying to update the webhook of a user")
+	}
+	return err
+}
@@ -0,0 +1,4 @@
+// Package rpc holds the gRPC service definition and the code generated from it
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative scheduler.proto
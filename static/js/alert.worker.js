@@ -7,8 +7,12 @@ self.onnotificationclick = (event) => {
     if (event.action === 'snooze') {
         if (notificationData && notificationData.taskId) {
             const taskId = notificationData.taskId;
+            const headers = {'X-CSRF-Token': notificationData.csrfToken || ''};
+            if (notificationData.schedule) {
+                headers['X-Schedule'] = notificationData.schedule;
+            }
 
-            fetch(`/task/${taskId}/snooze`)
+            fetch(`/tasks/${taskId}/snooze`, {method: 'PUT', headers: headers})
                 .then(function (response) {
                     // Handle the response from the server
                 })
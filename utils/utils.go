@@ -3,6 +3,7 @@ package utils
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/rs/zerolog/log"
@@ -42,6 +43,11 @@ func ParseDuration(input string) (time.Duration, error) {
 	return time.ParseDuration(timeValue)
 }
 
+// ParseDelay parses a plain duration like "5min" or "1h30m" without a schedule command
+func ParseDelay(input string) (time.Duration, error) {
+	return time.ParseDuration(fixTimeUnit(strings.ToLower(strings.TrimSpace(input))))
+}
+
 func IsRepetitiveSchedule(schedule string) bool {
 	return strings.Contains(schedule, "every")
 }
@@ -110,6 +116,17 @@ func Uuid() (uuid string) {
 	return
 }
 
+// RandomToken returns a url safe random secret, e.g. for session or CSRF tokens
+func RandomToken() string {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create random token")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
 func RenderTemplate(template *template.Template, tmplName string, data interface{}) (string, error) {
 	var tplContent bytes.Buffer
 